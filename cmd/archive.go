@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+func runArchiveExport(dir string, from, to, segment int) error {
+	if to < from {
+		return errors.New("--to must not be less than --from")
+	}
+	ds, err := initDS()
+	if err != nil {
+		return err
+	}
+	srv, err := inject(ds)
+	if err != nil {
+		return err
+	}
+	defer srv.RedisRepository.Close()
+
+	return srv.ArchiveService.Export(dir, from, to, segment)
+}
+
+// runArchiveImport rebuilds blocks from archive files, no websocket connection is opened
+func runArchiveImport(dir string, from, to, workers int) error {
+	if to > 0 && to < from {
+		return errors.New("--to must not be less than --from")
+	}
+	ds, err := initDS()
+	if err != nil {
+		return err
+	}
+	srv, err := inject(ds)
+	if err != nil {
+		return err
+	}
+	defer srv.RedisRepository.Close()
+
+	blockNum, _ := srv.RedisRepository.GetFillBestBlockNum(context.TODO())
+	if to > blockNum {
+		blockNum = to
+	}
+	srv.SqlRepository.Migration(blockNum)
+
+	return srv.ArchiveService.Import(dir, from, to, workers)
+}
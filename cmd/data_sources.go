@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/CoolBitX-Technology/subscan/configs"
+	"github.com/CoolBitX-Technology/subscan/internal/source"
+	"github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/util"
 	"github.com/go-kratos/kratos/pkg/log"
 	"github.com/go-redis/redis/v8"
@@ -17,6 +19,7 @@ import (
 type dataSources struct {
 	DB    *gorm.DB
 	Redis *redis.Client
+	Chain model.ChainSource
 }
 
 type ormLog struct{}
@@ -25,7 +28,11 @@ func (l ormLog) Print(v ...interface{}) {
 	log.Info(strings.Repeat("%v ", len(v)), v...)
 }
 
-const retry = 10
+const (
+	retry = 10
+	// how often the rpc endpoints are probed
+	rpcWatchInterval = 10 * time.Second
+)
 
 func initDS() (*dataSources, error) {
 	log.Info("== initDS ==")
@@ -69,7 +76,12 @@ func initDS() (*dataSources, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &dataSources{DB: db, Redis: rs}, nil
+
+	chain, err := source.New()
+	if err != nil {
+		return nil, err
+	}
+	return &dataSources{DB: db, Redis: rs, Chain: chain}, nil
 }
 
 func newDb(dc configs.MysqlConf) (db *gorm.DB) {
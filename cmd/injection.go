@@ -8,20 +8,38 @@ import (
 	"github.com/CoolBitX-Technology/subscan/model"
 )
 
-func inject(d *dataSources) (error, model.CommonService, model.BlockService, model.ExtrinsicService, model.EventService, model.RuntimeService, model.PluginService, model.SubscribeService, model.RepairService, model.RedisRepository, model.SqlRepository) {
+type services struct {
+	CommonService    model.CommonService
+	BlockService     model.BlockService
+	ExtrinsicService model.ExtrinsicService
+	EventService     model.EventService
+	RuntimeService   model.RuntimeService
+	PluginService    model.PluginService
+	SubscribeService model.SubscribeService
+	RepairService    model.RepairService
+	SyncService      model.SyncService
+	ArchiveService   model.ArchiveService
+	RedecodeService  model.RedecodeService
+	RedisRepository  model.RedisRepository
+	SqlRepository    model.SqlRepository
+}
+
+func inject(d *dataSources) (*services, error) {
 	log.Println("Injecting data sources")
 	redisRepository := repository.NewRedisRepository(d.Redis)
 	sqlRepository := repository.NewSqlRepository(d.DB)
-	DbStorage := service.NewDbStorage(d.DB)
+	DbStorage := repository.NewDbStorage(d.DB)
 	done := make(chan struct{})
 
 	runtimeService := service.NewRunTimeService(&service.RuntimeConfig{
 		SqlRepository: sqlRepository,
+		ChainSource:   d.Chain,
 	})
 
 	commonService := service.NewCommonService(&service.CommonConfig{
 		RedisRepository: redisRepository,
 		SqlRepository:   sqlRepository,
+		ChainSource:     d.Chain,
 	}, runtimeService)
 
 	pluginService := service.NewPluginService(&service.PluginConfig{
@@ -33,6 +51,7 @@ func inject(d *dataSources) (error, model.CommonService, model.BlockService, mod
 	extrinsicService := service.NewExtrinsicService(&service.ExtrinsicConfig{
 		RedisRepository: redisRepository,
 		SqlRepository:   sqlRepository,
+		ChainSource:     d.Chain,
 	}, pluginService)
 
 	eventService := service.NewEventService(&service.EventConfig{
@@ -43,18 +62,49 @@ func inject(d *dataSources) (error, model.CommonService, model.BlockService, mod
 	blockService := service.NewBlockService(&service.BlockConfig{
 		RedisRepository: redisRepository,
 		SqlRepository:   sqlRepository,
-	}, runtimeService, extrinsicService, eventService, commonService)
+	}, runtimeService, extrinsicService, eventService, commonService, pluginService)
 
 	subscribeService := service.NewSubscribeService(&service.SubscribeConfig{
 		RedisRepository: redisRepository,
 		SqlRepository:   sqlRepository,
+		ChainSource:     d.Chain,
 	}, done, commonService, runtimeService, blockService)
 
 	repairService := service.NewRepairService(&service.RepairConfig{
 		RedisRepository: redisRepository,
 		SqlRepository:   sqlRepository,
 		DbStorage:       DbStorage,
+		ChainSource:     d.Chain,
 	}, done, commonService, runtimeService, blockService, pluginService)
 
-	return nil, commonService, blockService, extrinsicService, eventService, runtimeService, pluginService, subscribeService, repairService, redisRepository, sqlRepository
+	syncService := service.NewSyncService(&service.SyncConfig{
+		RedisRepository: redisRepository,
+		SqlRepository:   sqlRepository,
+	}, commonService, runtimeService, blockService)
+
+	archiveService := service.NewArchiveService(&service.ArchiveConfig{
+		RedisRepository: redisRepository,
+		SqlRepository:   sqlRepository,
+	}, commonService, runtimeService, blockService)
+
+	redecodeService := service.NewRedecodeService(&service.RedecodeConfig{
+		RedisRepository: redisRepository,
+		SqlRepository:   sqlRepository,
+	}, commonService, blockService, pluginService)
+
+	return &services{
+		CommonService:    commonService,
+		BlockService:     blockService,
+		ExtrinsicService: extrinsicService,
+		EventService:     eventService,
+		RuntimeService:   runtimeService,
+		PluginService:    pluginService,
+		SubscribeService: subscribeService,
+		RepairService:    repairService,
+		SyncService:      syncService,
+		ArchiveService:   archiveService,
+		RedecodeService:  redecodeService,
+		RedisRepository:  redisRepository,
+		SqlRepository:    sqlRepository,
+	}, nil
 }
@@ -11,9 +11,10 @@ import (
 	"time"
 
 	"github.com/CoolBitX-Technology/subscan/configs"
+	"github.com/CoolBitX-Technology/subscan/internal/rpcpool"
 	"github.com/CoolBitX-Technology/subscan/internal/script"
 	"github.com/CoolBitX-Technology/subscan/internal/server/http/handler"
-	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/CoolBitX-Technology/subscan/plugins"
 	"github.com/gin-gonic/gin"
 	"github.com/go-kratos/kratos/pkg/conf/paladin"
 	"github.com/itering/substrate-api-rpc/websocket"
@@ -24,6 +25,7 @@ import (
 func main() {
 	defer func() {
 		websocket.Close()
+		plugins.Close()
 	}()
 	if err := setupApp().Run(os.Args); err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, err)
@@ -48,6 +50,11 @@ func setupApp() *cli.App {
 		} else {
 			paladin.DefaultClient = client
 		}
+		var pc configs.PluginsConf
+		pc.MergeConf()
+		if err := plugins.Configure(&pc); err != nil {
+			return err
+		}
 		runtime.GOMAXPROCS(runtime.NumCPU())
 		return nil
 	}
@@ -68,6 +75,112 @@ func setupApp() *cli.App {
 				return nil
 			},
 		},
+		{
+			Name:  "sync",
+			Usage: "Backfill a block range, E.g sync --from 0 --to 100000 --workers 10",
+			Flags: []cli.Flag{
+				cli.IntFlag{Name: "from", Usage: "first block to sync"},
+				cli.IntFlag{Name: "to", Usage: "last block to sync"},
+				cli.IntFlag{Name: "workers", Value: 10, Usage: "concurrent decode and write workers"},
+				cli.IntFlag{Name: "batch", Value: 20, Usage: "blocks per JSON-RPC batch"},
+			},
+			Action: func(c *cli.Context) error {
+				return runSync(c.Int("from"), c.Int("to"), c.Int("workers"), c.Int("batch"))
+			},
+		},
+		{
+			Name:  "archive",
+			Usage: "Export blocks to archive files or import them without a node",
+			Subcommands: []cli.Command{
+				{
+					Name:  "export",
+					Usage: "Export finalized blocks, E.g archive export --from 0 --to 100000 --dir ./archive",
+					Flags: []cli.Flag{
+						cli.IntFlag{Name: "from", Usage: "first block to export"},
+						cli.IntFlag{Name: "to", Usage: "last block to export"},
+						cli.StringFlag{Name: "dir", Value: "./archive", Usage: "archive directory"},
+						cli.IntFlag{Name: "segment", Value: 10000, Usage: "blocks per segment file"},
+					},
+					Action: func(c *cli.Context) error {
+						return runArchiveExport(c.String("dir"), c.Int("from"), c.Int("to"), c.Int("segment"))
+					},
+				},
+				{
+					Name:  "import",
+					Usage: "Import archived blocks, E.g archive import --dir ./archive",
+					Flags: []cli.Flag{
+						cli.IntFlag{Name: "from", Usage: "first block to import"},
+						cli.IntFlag{Name: "to", Usage: "last block to import, 0 imports all segments"},
+						cli.StringFlag{Name: "dir", Value: "./archive", Usage: "archive directory"},
+						cli.IntFlag{Name: "workers", Value: 10, Usage: "concurrent decode and write workers"},
+					},
+					Action: func(c *cli.Context) error {
+						return runArchiveImport(c.String("dir"), c.Int("from"), c.Int("to"), c.Int("workers"))
+					},
+				},
+			},
+		},
+		{
+			Name:    "plugins",
+			Aliases: []string{"plugin"},
+			Usage:   "Manage plugins, E.g plugins reindex reward --from 0",
+			Subcommands: []cli.Command{
+				{
+					Name:      "new",
+					Usage:     "Scaffold a plugin, E.g plugin new staking --events 'Staking.Reward(stash:AccountId,amount:Balance)' --calls Staking.bond",
+					ArgsUsage: "<name>",
+					Flags: []cli.Flag{
+						cli.StringSliceFlag{Name: "events", Usage: "subscribed event, Module.Event with optional (name:Type,...) params"},
+						cli.StringSliceFlag{Name: "calls", Usage: "subscribed call, Module.call with optional (name:Type,...) params"},
+						cli.StringFlag{Name: "dir", Value: "./plugins", Usage: "plugins directory holding registry.go"},
+					},
+					Action: func(c *cli.Context) error {
+						return script.NewPlugin(script.PluginOption{
+							Name:   c.Args().First(),
+							Events: c.StringSlice("events"),
+							Calls:  c.StringSlice("calls"),
+							Dir:    c.String("dir"),
+						})
+					},
+				},
+				{
+					Name:      "reindex",
+					Usage:     "Replay stored blocks through one plugin while the daemon keeps following the head",
+					ArgsUsage: "<name>",
+					Flags: []cli.Flag{
+						cli.IntFlag{Name: "from", Usage: "first block to replay"},
+						cli.IntFlag{Name: "to", Usage: "last block to replay, 0 replays up to the plugin cursor"},
+					},
+					Action: func(c *cli.Context) error {
+						return runPluginsReindex(c.Args().First(), c.Int("from"), c.Int("to"))
+					},
+				},
+				{
+					Name:  "migrate",
+					Usage: "Apply the pending schema migrations of the plugins, E.g plugins migrate --dry-run",
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "dry-run", Usage: "only show the pending migrations"},
+					},
+					Action: func(c *cli.Context) error {
+						return runPluginsMigrate(c.Bool("dry-run"))
+					},
+				},
+			},
+		},
+		{
+			Name:  "redecode",
+			Usage: "Rebuild extrinsics, events and logs from stored raw blocks, E.g redecode --from 0 --to 100000",
+			Flags: []cli.Flag{
+				cli.IntFlag{Name: "from", Usage: "first block to re-decode"},
+				cli.IntFlag{Name: "to", Usage: "last block to re-decode"},
+				cli.IntFlag{Name: "workers", Value: 10, Usage: "concurrent decode and write workers"},
+				cli.BoolFlag{Name: "codec-error-only", Usage: "only blocks that failed to decode before"},
+				cli.BoolFlag{Name: "failures", Usage: "retry the blocks quarantined in decode_failures, the range is ignored"},
+			},
+			Action: func(c *cli.Context) error {
+				return runRedecode(c.Int("from"), c.Int("to"), c.Int("workers"), c.Bool("codec-error-only"), c.Bool("failures"))
+			},
+		},
 		{
 			Name:  "install",
 			Usage: "Create database and create default conf file",
@@ -81,19 +194,7 @@ func setupApp() *cli.App {
 }
 
 func run() {
-	websocket.SetEndpoint(util.WSEndPoint)
-	conn, err := websocket.Init()
-
-	for i := 0; i < retry; i++ {
-		if !conn.Conn.IsConnected() {
-			conn.Conn.Dial(util.WSEndPoint, nil)
-		} else {
-			break
-		}
-		fmt.Fprintf(os.Stderr, "websocket dial error: %+v\n", err)
-		fmt.Fprintf(os.Stderr, "%d times Retrying in %v\n", i+1, 10*time.Second)
-		time.Sleep(10 * time.Second)
-	}
+	dialNode()
 
 	ds, err := initDS()
 
@@ -101,28 +202,34 @@ func run() {
 		log.Error("Unable to initialize data sources: ", err)
 	}
 
-	err, common, block, extrinsic, event, runtime, plugin, _, _, _, _ := inject(ds)
+	svc, err := inject(ds)
 
 	if err != nil {
 		log.Error("Failure to inject data sources: ", err)
 	}
 
+	common := svc.CommonService
+	go rpcpool.Watch(rpcWatchInterval, nil, func(status []rpcpool.EndpointStatus) {
+		common.SetRPCEndpointStatus("api", status)
+	})
 	common.InitSubRuntimeLatest()
-	plugin.PluginRegister()
+	svc.PluginService.PluginRegister()
+	var hc configs.HttpConf
+	hc.MergeConf()
+
 	// gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
 	handler.NewHandler(&handler.Config{
 		R:                router,
 		CommonService:    common,
-		BlockService:     block,
-		ExtrinsicService: extrinsic,
-		EventService:     event,
-		RuntimeService:   runtime,
+		BlockService:     svc.BlockService,
+		ExtrinsicService: svc.ExtrinsicService,
+		EventService:     svc.EventService,
+		RuntimeService:   svc.RuntimeService,
+		PluginService:    svc.PluginService,
+		AdminToken:       hc.Server.AdminToken,
 	})
 
-	var hc configs.HttpConf
-	hc.MergeConf()
-
 	srv := &http.Server{
 		Addr:    hc.Server.Addr,
 		Handler: router,
@@ -154,6 +261,7 @@ func run() {
 			time.Sleep(time.Second)
 			return
 		case syscall.SIGHUP:
+			_, _ = common.ReloadTypeRegistry()
 		default:
 			return
 		}
@@ -6,12 +6,13 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/CoolBitX-Technology/subscan/internal/rpcpool"
 	"github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/util"
-	"github.com/itering/substrate-api-rpc/pkg/recws"
 	"github.com/itering/substrate-api-rpc/websocket"
 	"github.com/prometheus/common/log"
 	"github.com/sevlyar/go-daemon"
@@ -92,6 +93,9 @@ func doAction(dt []string) {
 			log.Info("daemon terminated")
 			return
 		case syscall.SIGHUP:
+			if common != nil {
+				_, _ = common.ReloadTypeRegistry()
+			}
 		default:
 			return
 		}
@@ -116,37 +120,37 @@ var (
 )
 
 func doRun(dt []string) {
-	websocket.SetEndpoint(util.WSEndPoint)
-	conn, err := websocket.Init()
-
-	for i := 0; i < retry; i++ {
-		if !conn.Conn.IsConnected() {
-			conn.Conn.Dial(util.WSEndPoint, nil)
-		} else {
-			break
-		}
-		fmt.Fprintf(os.Stderr, "websocket dial error: %+v\n", err)
-		fmt.Fprintf(os.Stderr, "%d times Retrying in %s\n", i+1, 10*time.Second)
-		time.Sleep(10 * time.Second)
+	// a replayed chain source needs no node
+	if util.ChainReplayDir == "" {
+		dialNode()
 	}
 
 	ds, err := initDS()
 	if err != nil {
 		log.Fatal("Unable to initialize data sources:", err, "\n")
 	}
-	_, common, _, _, _, _, plugin, subscribe, repair, cache, sql = inject(ds) // TODO repair service
+	srv, err := inject(ds)
+	if err != nil {
+		log.Fatal("Failure to inject data sources: ", err)
+	}
+	common, plugin, subscribe, repair, cache, sql = srv.CommonService, srv.PluginService, srv.SubscribeService, srv.RepairService, srv.RedisRepository, srv.SqlRepository
+	if util.ChainReplayDir == "" {
+		go rpcpool.Watch(rpcWatchInterval, nil, func(status []rpcpool.EndpointStatus) {
+			common.SetRPCEndpointStatus(dt[0], status)
+		})
+	}
 	blockNum, _ := cache.GetFillBestBlockNum(context.TODO())
 	sql.Migration(blockNum)
+	common.RebuildSyncCursorCache()
 	common.InitSubRuntimeLatest()
+	go watchTypeRegistry(dt[0], srv.RedecodeService)
 	plugin.PluginRegister()
 	defer cache.Close()
 LOOP:
 	for {
 		if dt[0] == "substrate" || dt[0] == "plugins" || dt[0] == "repair" {
 			interrupt := make(chan os.Signal, 1)
-			subscribeConn := &recws.RecConn{KeepAliveTimeout: 60 * time.Second, WriteTimeout: time.Second * 30, ReadTimeout: 30 * time.Second}
-			subscribeConn.Dial(util.WSEndPoint, nil)
-			log.Info("Dial to :", util.WSEndPoint)
+			subscribeConn := ds.Chain.SubscribeConn()
 			switch dt[0] {
 			case "substrate":
 				subscribe.Subscribe(subscribeConn, interrupt)
@@ -172,6 +176,46 @@ LOOP:
 	done <- struct{}{}
 }
 
+// dialNode points the request pool to the healthiest endpoint and waits for it to connect
+func dialNode() {
+	rpcpool.Probe()
+	websocket.SetEndpoint(rpcpool.Active())
+	rpcpool.OnSwitch(rpcpool.SwitchRequestPool)
+	conn, err := websocket.Init()
+
+	for i := 0; i < retry; i++ {
+		if !conn.Conn.IsConnected() {
+			conn.Conn.Dial(rpcpool.Active(), nil)
+		} else {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "websocket dial error: %+v\n", err)
+		fmt.Fprintf(os.Stderr, "%d times Retrying in %s\n", i+1, 10*time.Second)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// watchTypeRegistry follows the type registry reloads broadcast by the api server, only the substrate
+// daemon retries the quarantined blocks, one run at a time
+func watchTypeRegistry(worker string, redecode model.RedecodeService) {
+	var onRedecode func()
+	if worker == "substrate" {
+		var running int32
+		onRedecode = func() {
+			if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+				return
+			}
+			go func() {
+				defer atomic.StoreInt32(&running, 0)
+				if err := redecode.RetryDecodeFailures(1); err != nil {
+					log.Error("Retry decode failures error ", err)
+				}
+			}()
+		}
+	}
+	common.WatchTypeRegistryReload(context.Background(), onRedecode)
+}
+
 func termHandler(sig os.Signal) error {
 	log.Info("terminating...")
 	stop <- struct{}{}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// runPluginsReindex replays stored blocks through one plugin, only the database is read
+func runPluginsReindex(name string, from, to int) error {
+	if name == "" {
+		return errors.New("plugin name is required, E.g plugins reindex reward --from 0")
+	}
+	if to > 0 && to < from {
+		return errors.New("--to must not be less than --from")
+	}
+	ds, err := initDS()
+	if err != nil {
+		return err
+	}
+	srv, err := inject(ds)
+	if err != nil {
+		return err
+	}
+	defer srv.RedisRepository.Close()
+
+	srv.CommonService.InitSubRuntimeLatest()
+	srv.PluginService.PluginRegister()
+	return srv.PluginService.Reindex(name, from, to)
+}
+
+// runPluginsMigrate applies the pending schema steps of the plugins, dryRun only shows them
+func runPluginsMigrate(dryRun bool) error {
+	ds, err := initDS()
+	if err != nil {
+		return err
+	}
+	srv, err := inject(ds)
+	if err != nil {
+		return err
+	}
+	defer srv.RedisRepository.Close()
+
+	steps, err := srv.PluginService.MigratePlugins(dryRun)
+	action := "applied"
+	if dryRun {
+		action = "pending"
+	}
+	for _, step := range steps {
+		fmt.Printf("%s %s %d %s\n", action, step.Plugin, step.Version, step.Name)
+	}
+	if len(steps) == 0 && err == nil {
+		fmt.Println("plugin schemas are up to date")
+	}
+	return err
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+)
+
+// runRedecode rebuilds derived rows from the raw blocks in the database, no websocket connection is
+// opened unless a block lacks its stored validator or fees. failures retries the quarantined blocks instead
+func runRedecode(from, to, workers int, codecErrorOnly, failures bool) error {
+	if !failures && to < from {
+		return errors.New("--to must not be less than --from")
+	}
+	ds, err := initDS()
+	if err != nil {
+		return err
+	}
+	srv, err := inject(ds)
+	if err != nil {
+		return err
+	}
+	defer srv.RedisRepository.Close()
+	// re-decoded blocks roll the plugins back
+	srv.PluginService.PluginRegister()
+
+	if failures {
+		return srv.RedecodeService.RetryDecodeFailures(workers)
+	}
+	return srv.RedecodeService.Redecode(from, to, workers, codecErrorOnly)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/CoolBitX-Technology/subscan/internal/rpcpool"
+	"github.com/itering/substrate-api-rpc/pkg/recws"
+	"github.com/itering/substrate-api-rpc/websocket"
+	"github.com/prometheus/common/log"
+)
+
+func runSync(from, to, workers, batch int) error {
+	if to < from {
+		return errors.New("--to must not be less than --from")
+	}
+	rpcpool.Probe()
+	websocket.SetEndpoint(rpcpool.Active())
+
+	ds, err := initDS()
+	if err != nil {
+		return err
+	}
+	srv, err := inject(ds)
+	if err != nil {
+		return err
+	}
+	defer srv.RedisRepository.Close()
+
+	blockNum, _ := srv.RedisRepository.GetFillBestBlockNum(context.TODO())
+	if to > blockNum {
+		blockNum = to
+	}
+	srv.SqlRepository.Migration(blockNum)
+	srv.CommonService.InitSubRuntimeLatest()
+
+	// a dedicated connection, batch responses are too large for the pool read timeout
+	conn := &recws.RecConn{KeepAliveTimeout: 60 * time.Second, WriteTimeout: 30 * time.Second, ReadTimeout: 120 * time.Second}
+	conn.Dial(rpcpool.Active(), nil)
+	defer conn.Close()
+	log.Info("Dial to :", rpcpool.Active())
+
+	return srv.SyncService.Sync(conn, from, to, workers, batch)
+}
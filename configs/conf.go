@@ -1,7 +1,10 @@
 package configs
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/CoolBitX-Technology/subscan/util"
 	"github.com/go-kratos/kratos/pkg/conf/paladin"
@@ -24,8 +27,26 @@ type (
 		Server struct {
 			Addr    string
 			Timeout string
+			// token of the admin apis, they only serve local clients without one
+			AdminToken string
 		}
 	}
+	PluginsConf struct {
+		// plugins fed with blocks, every registered plugin when empty
+		Enabled []string
+		// config map of every plugin, keyed by plugin name
+		Config map[string]map[string]interface{}
+		// out-of-process plugins
+		Remote []RemotePluginConf
+	}
+	RemotePluginConf struct {
+		Name string
+		// connect a running plugin, host:port or unix:///path
+		Addr string
+		// or launch it, it serves on the address in env SUBSCAN_PLUGIN_ADDR
+		Command string
+		Args    []string
+	}
 	RedisConf struct {
 		Config struct {
 			Addr       string
@@ -59,6 +80,14 @@ func (hc *HttpConf) MergeConf() {
 	hc.mergeEnvironment()
 }
 
+// MergeConf reads plugins.toml, it is optional
+func (pc *PluginsConf) MergeConf() {
+	if err := paladin.Get("plugins.toml").UnmarshalTOML(pc); err != paladin.ErrNotExist {
+		checkErr(err)
+	}
+	pc.mergeEnvironment()
+}
+
 func (dc *MysqlConf) mergeEnvironment() {
 	dbHost := util.GetEnv("MYSQL_HOST", dc.Conf.Host)
 	dbUser := util.GetEnv("MYSQL_USER", dc.Conf.User)
@@ -77,7 +106,37 @@ func (rc *RedisConf) mergeEnvironment() {
 	rc.Config.AuthPw = util.GetEnv("REDIS_AUTH_PW", rc.Config.AuthPw)
 }
 
+// mergeEnvironment takes PLUGINS_ENABLED=transfer,bond and PLUGIN_<NAME>_<KEY>=value, a value is parsed
+// as JSON when it can be, PLUGIN_BOND_UNBONDING_BLOCKS=100800 sets unbonding_blocks of bond to a number
+func (pc *PluginsConf) mergeEnvironment() {
+	if enabled := os.Getenv("PLUGINS_ENABLED"); enabled != "" {
+		pc.Enabled = util.SplitAndTrim(strings.ToLower(enabled), ",")
+	}
+	for _, env := range os.Environ() {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[0], "PLUGIN_") {
+			continue
+		}
+		nameKey := strings.SplitN(strings.ToLower(strings.TrimPrefix(kv[0], "PLUGIN_")), "_", 2)
+		if len(nameKey) != 2 || nameKey[0] == "" || nameKey[1] == "" {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(kv[1]), &value); err != nil {
+			value = kv[1]
+		}
+		if pc.Config == nil {
+			pc.Config = make(map[string]map[string]interface{})
+		}
+		if pc.Config[nameKey[0]] == nil {
+			pc.Config[nameKey[0]] = make(map[string]interface{})
+		}
+		pc.Config[nameKey[0]][nameKey[1]] = value
+	}
+}
+
 func (hc *HttpConf) mergeEnvironment() {
 	hc.Server.Addr = util.GetEnv("HTTP_ADDR", hc.Server.Addr)
 	hc.Server.Timeout = util.GetEnv("HTTP_TIMEOUT", "10s")
+	hc.Server.AdminToken = util.GetEnv("HTTP_ADMIN_TOKEN", hc.Server.AdminToken)
 }
@@ -5,6 +5,7 @@ go 1.14
 require (
 	github.com/creack/pty v1.1.13 // indirect
 	github.com/freehere107/go-scale-codec v0.0.0-20200518091816-4e2d86b8ba16
+	github.com/garyburd/redigo v1.6.0
 	github.com/gin-gonic/gin v1.7.2
 	github.com/go-kratos/kratos v1.0.0
 	github.com/go-redis/redis/v8 v8.10.0
@@ -33,6 +34,7 @@ require (
 	golang.org/x/sys v0.0.0-20211004093028-2c5d950f24ef // indirect
 	golang.org/x/term v0.0.0-20210503060354-a79de5458b56 // indirect
 	golang.org/x/tools v0.1.3 // indirect
+	google.golang.org/grpc v1.29.1
 	gopkg.in/go-playground/assert.v1 v1.2.1
 	gopkg.in/go-playground/validator.v9 v9.31.0
 	gorm.io/gorm v1.21.12
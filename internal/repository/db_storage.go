@@ -0,0 +1,482 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/itering/substrate-api-rpc/websocket"
+	"github.com/jinzhu/gorm"
+	"github.com/prometheus/common/log"
+)
+
+// DbStorage is the model.Dao of the plugins, on mysql or on sqlite for the plugin testkit
+type DbStorage struct {
+	db     *gorm.DB
+	Prefix string
+	blocks *blockTxns
+}
+
+// blockTxns are the open transactions of the blocks being fed to the plugins, keyed by block num
+type blockTxns struct {
+	sync.Mutex
+	txns map[int]*model.GormDB
+}
+
+func NewDbStorage(d *gorm.DB) *DbStorage {
+	return &DbStorage{
+		db:     d,
+		blocks: &blockTxns{txns: make(map[int]*model.GormDB)},
+	}
+}
+
+// WithPrefix is the storage of one plugin, it shares the block transactions of d
+func (d *DbStorage) WithPrefix(prefix string) *DbStorage {
+	return &DbStorage{
+		db:     d.db,
+		Prefix: prefix,
+		blocks: d.blocks,
+	}
+}
+
+// pluginSavepoint is rolled back to when a plugin call fails inside a block transaction
+const pluginSavepoint = "plugin_call"
+
+func (d *DbStorage) SetPrefix(prefix string) {
+	d.Prefix = prefix
+}
+
+func (d *DbStorage) GetPrefix() string {
+	return d.Prefix
+}
+
+func (d *DbStorage) SpecialMetadata(spec int) string {
+	var raw model.RuntimeVersion
+	if query := d.db.Where("spec_version = ?", spec).First(&raw); query.RecordNotFound() {
+		return ""
+	}
+	return raw.RawData
+}
+
+func (d *DbStorage) getModelTableName(model interface{}) string {
+	return d.db.Unscoped().NewScope(model).TableName()
+}
+
+func (d *DbStorage) checkProtected(model interface{}) error {
+	if util.StringInSlice(d.getModelTableName(model), protectedTables) {
+		return errors.New("protected tables")
+	}
+	return nil
+}
+
+func (d *DbStorage) RPCPool() *websocket.PoolConn {
+	conn, _ := websocket.Init()
+	return conn
+}
+
+func (d *DbStorage) DbBegin() *model.GormDB {
+	txn := d.db.Begin()
+	if txn.Error != nil {
+		panic(txn.Error)
+	}
+	return &model.GormDB{DB: txn}
+}
+
+func (d *DbStorage) BlockTxn(blockNum int) *model.GormDB {
+	txn := d.OwnerTxn(blockNum)
+	if txn == nil {
+		return d.DbBegin()
+	}
+	return &model.GormDB{DB: txn.DB, Savepoint: pluginSavepoint}
+}
+
+// BeginBlock opens the transaction the plugins write block blockNum in
+func (d *DbStorage) BeginBlock(blockNum int) (*model.GormDB, error) {
+	d.blocks.Lock()
+	defer d.blocks.Unlock()
+	if _, ok := d.blocks.txns[blockNum]; ok {
+		return nil, fmt.Errorf("block %d is already being fed to the plugins", blockNum)
+	}
+	txn := d.db.Begin()
+	if txn.Error != nil {
+		return nil, txn.Error
+	}
+	d.blocks.txns[blockNum] = &model.GormDB{DB: txn}
+	return d.blocks.txns[blockNum], nil
+}
+
+// EndBlock commits the block transaction, or rolls it back when commit is false
+func (d *DbStorage) EndBlock(blockNum int, commit bool) error {
+	d.blocks.Lock()
+	txn, ok := d.blocks.txns[blockNum]
+	delete(d.blocks.txns, blockNum)
+	d.blocks.Unlock()
+	if !ok || txn.GdbDone {
+		return nil
+	}
+	txn.GdbDone = true
+	if commit {
+		return txn.Commit().Error
+	}
+	return txn.Rollback().Error
+}
+
+// OwnerTxn is the open block transaction of blockNum, nil when there is none
+func (d *DbStorage) OwnerTxn(blockNum int) *model.GormDB {
+	d.blocks.Lock()
+	defer d.blocks.Unlock()
+	return d.blocks.txns[blockNum]
+}
+
+// Savepoint marks where the writes of the next plugin call start, nil when blockNum has no block transaction
+func (d *DbStorage) Savepoint(blockNum int) (*model.GormDB, error) {
+	txn := d.OwnerTxn(blockNum)
+	if txn == nil {
+		return nil, nil
+	}
+	if err := txn.Exec("SAVEPOINT " + pluginSavepoint).Error; err != nil {
+		return nil, err
+	}
+	return &model.GormDB{DB: txn.DB, Savepoint: pluginSavepoint}, nil
+}
+
+func (d *DbStorage) DbRollback(c *model.GormDB) {
+	if c.GdbDone {
+		return
+	}
+	c.GdbDone = true
+	if c.Savepoint != "" {
+		if err := c.Exec("ROLLBACK TO SAVEPOINT " + c.Savepoint).Error; err != nil {
+			log.Error("Fatal error DbRollback", err)
+		}
+		return
+	}
+	tx := c.Rollback()
+	if err := tx.Error; err != nil && err != sql.ErrTxDone {
+		log.Error("Fatal error DbRollback", err)
+	}
+}
+
+// DbCommit of a block transaction handle is left to the block owner
+func (d *DbStorage) DbCommit(c *model.GormDB) {
+	if c.GdbDone {
+		return
+	}
+	if c.Savepoint != "" {
+		c.GdbDone = true
+		return
+	}
+	tx := c.Commit()
+	c.GdbDone = true
+	if err := tx.Error; err != nil && err != sql.ErrTxDone {
+		log.Error("Fatal error DbCommit", err)
+	}
+}
+
+func (d *DbStorage) getPluginPrefixTableName(instant interface{}) string {
+	tableName := d.getModelTableName(instant)
+
+	if util.StringInSlice(tableName, protectedTables) {
+		return tableName
+	}
+	return fmt.Sprintf("%s_%s", d.GetPrefix(), tableName)
+}
+
+func (d *DbStorage) FindBy(record interface{}, query interface{}, option *model.Option) error {
+	tx := d.db
+	switch v := query.(type) {
+	case []string:
+		for i, q := range v {
+			if i == 0 {
+				tx = tx.Where(q)
+			} else {
+				tx = tx.Or(q)
+			}
+		}
+	case map[string]interface{}:
+		tx = tx.Where(query)
+	default:
+		// handle unknown type
+	}
+
+	// plugin prefix table
+	if option != nil && option.PluginPrefix != "" {
+		tx = tx.Table(fmt.Sprintf("%s_%s", option.PluginPrefix, d.getModelTableName(record)))
+		if (option.Page > 0) && (option.PageSize > 0) {
+			tx = tx.Limit(option.PageSize).Offset((option.Page - 1) * option.PageSize)
+		}
+		if option.Order != "" {
+			tx = tx.Order(option.Order)
+		}
+	}
+
+	// rows count
+	// tx.Count(&count) TODO count should be get from account table
+
+	// pagination
+	if option != nil {
+		// default page limit 1000
+		if option.PageSize == 0 {
+			option.PageSize = 1000
+		}
+		tx = tx.Offset(option.Page * option.PageSize).Limit(option.PageSize)
+	}
+
+	tx = tx.Find(record)
+	return tx.Error
+}
+
+// isMysql tells the daemon database from the sqlite one of the plugin testkit
+func (d *DbStorage) isMysql() bool {
+	return d.db.Dialect().GetName() == "mysql"
+}
+
+// indexName is the name of an index of table, sqlite index names are unique in the database, not in the table
+func (d *DbStorage) indexName(table, name string) string {
+	if d.isMysql() {
+		return name
+	}
+	return fmt.Sprintf("%s_%s", table, name)
+}
+
+func (d *DbStorage) AutoMigration(model interface{}) error {
+	log.Info("--- AutoMigration ---", d.getPluginPrefixTableName(model))
+	if d.checkProtected(model) == nil {
+		tx := d.db.Table(d.getPluginPrefixTableName(model))
+		if d.isMysql() {
+			tx = tx.Set("gorm:table_options", "ENGINE=InnoDB")
+		}
+		return tx.AutoMigrate(model).Error
+	}
+	return nil
+}
+
+func (d *DbStorage) AddIndex(model interface{}, indexName string, columns ...string) error {
+	if d.checkProtected(model) == nil {
+		tableName := d.getPluginPrefixTableName(model)
+		tx := d.db.Table(tableName).AddIndex(d.indexName(tableName, indexName), columns...)
+		return tx.Error
+	}
+	return nil
+}
+
+func (d *DbStorage) Create(txn *model.GormDB, record interface{}) *model.GormDB {
+	if err := d.checkProtected(record); err == nil {
+		tx := txn.Table(d.getPluginPrefixTableName(record)).Create(record)
+		return &model.GormDB{DB: tx}
+	} else {
+		log.Error(err)
+		return nil
+	}
+}
+
+func (d *DbStorage) Update(txn *model.GormDB, record interface{}, query interface{}, attr map[string]interface{}) *model.GormDB {
+	if err := d.checkProtected(record); err == nil {
+		tx := txn.Table(d.getPluginPrefixTableName(record))
+		switch v := query.(type) {
+		case []string:
+			for _, q := range v {
+				tx = tx.Where(q)
+			}
+		case string:
+			tx.Where(query)
+		default:
+			// handle unknown type
+		}
+		tx.Updates(attr)
+		return &model.GormDB{DB: tx}
+	} else {
+		log.Error(err)
+		return nil
+	}
+}
+
+func (d *DbStorage) Delete(model interface{}, query interface{}) error {
+	if err := d.checkProtected(model); err == nil {
+		tx := d.db.Table(d.getPluginPrefixTableName(model)).Where(query).Delete(model)
+		return tx.Error
+	} else {
+		return err
+	}
+}
+
+func (d *DbStorage) AddUniqueIndex(model interface{}, indexName string, columns ...string) error {
+	if d.checkProtected(model) == nil {
+		tableName := d.getPluginPrefixTableName(model)
+		tx := d.db.Table(tableName).AddUniqueIndex(d.indexName(tableName, indexName), columns...)
+		return tx.Error
+	}
+	return nil
+}
+
+func (d *DbStorage) RemoveIndex(model interface{}, indexName string) error {
+	if d.checkProtected(model) == nil {
+		tableName := d.getPluginPrefixTableName(model)
+		indexName = d.indexName(tableName, indexName)
+		if !d.db.Dialect().HasIndex(tableName, indexName) {
+			return nil
+		}
+		return d.db.Dialect().RemoveIndex(tableName, indexName)
+	}
+	return nil
+}
+
+func (d *DbStorage) pluginTable(table string) (string, error) {
+	if !model.ValidIdentifier(table) {
+		return "", fmt.Errorf("invalid table name %q", table)
+	}
+	return fmt.Sprintf("%s_%s", d.GetPrefix(), table), nil
+}
+
+func (d *DbStorage) MigrateTable(table *model.Table) error {
+	if err := table.Validate(); err != nil {
+		return err
+	}
+	name, _ := d.pluginTable(table.Name)
+	log.Info("--- MigrateTable ---", name)
+	create := "CREATE TABLE IF NOT EXISTS `%s` (`id` bigint unsigned NOT NULL AUTO_INCREMENT, PRIMARY KEY (`id`)) ENGINE=InnoDB"
+	if !d.isMysql() {
+		create = "CREATE TABLE IF NOT EXISTS `%s` (`id` integer PRIMARY KEY AUTOINCREMENT)"
+	}
+	if err := d.db.Exec(fmt.Sprintf(create, name)).Error; err != nil {
+		return err
+	}
+	dialect := d.db.Dialect()
+	for _, c := range table.Columns {
+		if dialect.HasColumn(name, c.Name) {
+			continue
+		}
+		sqlType, _ := model.ColumnSQLType(c.Type)
+		if err := d.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s NULL", dialect.Quote(name), dialect.Quote(c.Name), sqlType)).Error; err != nil {
+			return err
+		}
+	}
+	for _, i := range table.Indexes {
+		tx := d.db.Table(name)
+		if i.Unique {
+			tx = tx.AddUniqueIndex(d.indexName(name, i.Name), i.Columns...)
+		} else {
+			tx = tx.AddIndex(d.indexName(name, i.Name), i.Columns...)
+		}
+		if tx.Error != nil {
+			return tx.Error
+		}
+	}
+	return nil
+}
+
+func (d *DbStorage) InsertRow(txn *model.GormDB, table string, row map[string]interface{}) error {
+	name, err := d.pluginTable(table)
+	if err != nil {
+		return err
+	}
+	if len(row) == 0 {
+		return fmt.Errorf("insert into %s without columns", table)
+	}
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		if !model.ValidIdentifier(column) {
+			return fmt.Errorf("invalid column %q", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		values[i] = row[column]
+	}
+	db := d.db
+	if txn != nil {
+		db = txn.DB
+	}
+	return db.Exec(fmt.Sprintf("INSERT INTO `%s` (`%s`) VALUES (?%s)", name, strings.Join(columns, "`, `"),
+		strings.Repeat(", ?", len(columns)-1)), values...).Error
+}
+
+func (d *DbStorage) DeleteRows(txn *model.GormDB, table string, where []model.Cond) error {
+	name, err := d.pluginTable(table)
+	if err != nil {
+		return err
+	}
+	if len(where) == 0 {
+		return fmt.Errorf("delete from %s without conditions", table)
+	}
+	clause, values, err := model.CondsSQL(where)
+	if err != nil {
+		return err
+	}
+	db := d.db
+	if txn != nil {
+		db = txn.DB
+	}
+	return db.Exec(fmt.Sprintf("DELETE FROM `%s` WHERE %s", name, clause), values...).Error
+}
+
+func (d *DbStorage) FindRows(txn *model.GormDB, table string, where []model.Cond, option *model.Option) ([]map[string]interface{}, error) {
+	name, err := d.pluginTable(table)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("SELECT * FROM `%s`", name)
+	clause, values, err := model.CondsSQL(where)
+	if err != nil {
+		return nil, err
+	}
+	if clause != "" {
+		query += " WHERE " + clause
+	}
+	if option != nil {
+		if option.Order != "" {
+			order := strings.Fields(option.Order)
+			if len(order) > 2 || !model.ValidIdentifier(order[0]) || (len(order) == 2 && order[1] != "asc" && order[1] != "desc") {
+				return nil, fmt.Errorf("invalid order %q", option.Order)
+			}
+			query += fmt.Sprintf(" ORDER BY `%s` %s", order[0], strings.Join(order[1:], ""))
+		}
+		// default page limit 1000, like FindBy
+		if option.PageSize == 0 {
+			option.PageSize = 1000
+		}
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", option.PageSize, option.Page*option.PageSize)
+	}
+
+	db := d.db
+	if txn != nil {
+		db = txn.DB
+	}
+	rows, err := db.Raw(query, values...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var list []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err = rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		list = append(list, row)
+	}
+	return list, rows.Err()
+}
@@ -22,6 +22,8 @@ var (
 	RedisFillAlreadyBlockNum   = redisKeyPrefix() + "FillAlreadyBlockNum"
 	RedisFillFinalizedBlockNum = redisKeyPrefix() + "FillFinalizedBlockNum"
 	RedisMissingBlocksSet      = redisKeyPrefix() + "missing_blocks"
+	RedisRPCEndpoints          = redisKeyPrefix() + "rpc_endpoints"
+	RedisTypeRegistryReload    = redisKeyPrefix() + "type_registry_reload"
 )
 
 func NewRedisRepository(redisClient *redis.Client) model.RedisRepository {
@@ -94,6 +96,59 @@ func (r *redisRepository) GetFillFinalizedBlockNum(c context.Context) (num int,
 	return
 }
 
+// ResetFillBlockNum overwrites both fill cursors, used to rebuild the cache from mysql
+func (r *redisRepository) ResetFillBlockNum(c context.Context, best, finalized int) (err error) {
+	if err = r.Redis.Set(c, RedisFillAlreadyBlockNum, best, 0).Err(); err != nil {
+		return
+	}
+	return r.Redis.Set(c, RedisFillFinalizedBlockNum, finalized, 0).Err()
+}
+
+// SetRPCEndpointStatus publishes the endpoint pool view of a worker for the api server
+func (r *redisRepository) SetRPCEndpointStatus(c context.Context, worker string, status interface{}) (err error) {
+	b, err := json.Marshal(status)
+	if err != nil {
+		return
+	}
+	return r.Redis.HSet(c, RedisRPCEndpoints, worker, string(b)).Err()
+}
+
+func (r *redisRepository) GetRPCEndpointStatus(c context.Context) (map[string]string, error) {
+	return r.Redis.HGetAll(c, RedisRPCEndpoints).Result()
+}
+
+// PublishTypeRegistryReload asks every worker to reload the custom type registry
+func (r *redisRepository) PublishTypeRegistryReload(c context.Context, message string) error {
+	return r.Redis.Publish(c, RedisTypeRegistryReload, message).Err()
+}
+
+// SubscribeTypeRegistryReload delivers the published reload messages until c is done
+func (r *redisRepository) SubscribeTypeRegistryReload(c context.Context) <-chan string {
+	pubSub := r.Redis.Subscribe(c, RedisTypeRegistryReload)
+	messages := make(chan string)
+	go func() {
+		defer close(messages)
+		defer pubSub.Close()
+		ch := pubSub.Channel()
+		for {
+			select {
+			case <-c.Done():
+				return
+			case m, ok := <-ch:
+				if !ok {
+					return
+				}
+				select {
+				case messages <- m.Payload:
+				case <-c.Done():
+					return
+				}
+			}
+		}
+	}()
+	return messages
+}
+
 func (r *redisRepository) SetMetadata(c context.Context, metadata map[string]interface{}) (err error) {
 	err = r.Redis.HSet(c, RedisMetadataKey, metadata).Err()
 	return
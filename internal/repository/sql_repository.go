@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/util"
@@ -47,6 +48,12 @@ func (s *sqlRepository) AddIndex(blockNum int) {
 
 	if blockNum == 0 {
 		s.DB.Model(model.RuntimeVersion{}).AddUniqueIndex("spec_version", "spec_version")
+		s.DB.Model(model.SyncCursor{}).AddUniqueIndex("worker_network_plugin", "worker", "network", "plugin")
+		s.DB.Model(model.DecodeFailure{}).AddUniqueIndex("block_num", "block_num")
+		s.DB.Model(model.DecodeFailure{}).AddIndex("spec_version", "spec_version")
+		s.DB.Model(model.PluginFailure{}).AddUniqueIndex("plugin_item", "plugin", "kind", "item_index")
+		s.DB.Model(model.PluginFailure{}).AddIndex("next_retry_at", "next_retry_at")
+		s.DB.Model(model.PluginSchemaMigration{}).AddUniqueIndex("plugin_version", "plugin", "version")
 	}
 
 	blockModel := model.ChainBlock{BlockNum: blockNum}
@@ -138,6 +145,12 @@ func (s *sqlRepository) RuntimeVersionRecent() *model.RuntimeVersion {
 	return &list
 }
 
+func (s *sqlRepository) RuntimeVersionRawList() []model.RuntimeVersion {
+	var list []model.RuntimeVersion
+	s.DB.Select("name,spec_version,raw_data").Model(model.RuntimeVersion{}).Order("spec_version ASC").Find(&list)
+	return list
+}
+
 func (s *sqlRepository) SetRuntimeData(specVersion int, modules string, rawData string) int64 {
 	query := s.DB.Model(model.RuntimeVersion{}).Where("spec_version=?", specVersion).UpdateColumn(model.RuntimeVersion{
 		Modules: modules,
@@ -185,14 +198,33 @@ func (s *sqlRepository) GetLogsByIndex(index string) *model.ChainLogJson {
 	return &Log
 }
 
-func (s *sqlRepository) DropLogsNotFinalizedData(blockNum int, finalized bool) bool {
-	var delExist bool
-	if finalized {
-		query := s.DB.Where("block_num = ?", blockNum).
-			Delete(model.ChainLog{BlockNum: blockNum})
-		delExist = query.RowsAffected > 0
+func (s *sqlRepository) DropLogsNotFinalizedData(txn *model.GormDB, blockNum int, finalized bool) error {
+	if !finalized {
+		return nil
+	}
+	return txn.Where("block_num = ?", blockNum).Delete(model.ChainLog{BlockNum: blockNum}).Error
+}
+
+// DropBlockDerivedData deletes the extrinsics, events and logs of a block in txn, returning how
+// many extrinsics, signed extrinsics and events were removed
+func (s *sqlRepository) DropBlockDerivedData(txn *model.GormDB, blockNum int) (extrinsics, signed, events int, err error) {
+	if query := txn.Model(model.ChainExtrinsic{BlockNum: blockNum}).
+		Where("block_num = ? AND is_signed = ?", blockNum, true).Count(&signed); query.Error != nil {
+		return 0, 0, 0, query.Error
+	}
+	query := txn.Where("block_num = ?", blockNum).Delete(model.ChainExtrinsic{BlockNum: blockNum})
+	if query.Error != nil {
+		return 0, 0, 0, query.Error
+	}
+	extrinsics = int(query.RowsAffected)
+	if query = txn.Where("block_num = ?", blockNum).Delete(model.ChainEvent{BlockNum: blockNum}); query.Error != nil {
+		return 0, 0, 0, query.Error
 	}
-	return delExist
+	events = int(query.RowsAffected)
+	if query = txn.Where("block_num = ?", blockNum).Delete(model.ChainLog{BlockNum: blockNum}); query.Error != nil {
+		return 0, 0, 0, query.Error
+	}
+	return extrinsics, signed, events, nil
 }
 
 func (s *sqlRepository) CreateLog(txn *model.GormDB, ce *model.ChainLog) error {
@@ -339,13 +371,11 @@ func (s *sqlRepository) extrinsicsAsDetail(c context.Context, e *model.ChainExtr
 	return &detail
 }
 
-func (s *sqlRepository) DropEventNotFinalizedData(blockNum int, finalized bool) bool {
-	var delExist bool
-	if finalized {
-		query := s.DB.Where("block_num = ?", blockNum).Delete(model.ChainEvent{BlockNum: blockNum})
-		delExist = query.RowsAffected > 0
+func (s *sqlRepository) DropEventNotFinalizedData(txn *model.GormDB, blockNum int, finalized bool) error {
+	if !finalized {
+		return nil
 	}
-	return delExist
+	return txn.Where("block_num = ?", blockNum).Delete(model.ChainEvent{BlockNum: blockNum}).Error
 }
 
 func (s *sqlRepository) CreateEvent(txn *model.GormDB, event *model.ChainEvent) *gorm.DB {
@@ -370,7 +400,7 @@ func (s *sqlRepository) DbBegin() *model.GormDB {
 	if txn.Error != nil {
 		panic(txn.Error)
 	}
-	return &model.GormDB{txn, false}
+	return &model.GormDB{DB: txn}
 }
 
 func (s *sqlRepository) DbRollback(c *model.GormDB) {
@@ -384,9 +414,8 @@ func (s *sqlRepository) DbRollback(c *model.GormDB) {
 	}
 }
 
-func (s *sqlRepository) DropExtrinsicNotFinalizedData(c context.Context, blockNum int) *gorm.DB {
-	query := s.DB.Where("block_num = ?", blockNum).Delete(model.ChainExtrinsic{BlockNum: blockNum})
-	return query
+func (s *sqlRepository) DropExtrinsicNotFinalizedData(txn *model.GormDB, blockNum int) error {
+	return txn.Where("block_num = ?", blockNum).Delete(model.ChainExtrinsic{BlockNum: blockNum}).Error
 }
 
 func (s *sqlRepository) CreateExtrinsic(c context.Context, txn *model.GormDB, extrinsic *model.ChainExtrinsic) *gorm.DB {
@@ -413,6 +442,11 @@ func (s *sqlRepository) CreateExtrinsic(c context.Context, txn *model.GormDB, ex
 	return query
 }
 
+func (s *sqlRepository) DropBlockNotFinalizedData(txn *model.GormDB, blockNum int) error {
+	return txn.Where("block_num = ? AND finalized = ?", blockNum, false).
+		Delete(model.ChainBlock{BlockNum: blockNum}).Error
+}
+
 func (s *sqlRepository) CreateBlock(txn *model.GormDB, cb *model.ChainBlock) (err error) {
 	query := txn.Create(cb)
 	if !s.DB.HasTable(model.ChainBlock{BlockNum: cb.BlockNum + model.SplitTableBlockNum}) {
@@ -437,7 +471,7 @@ func (s *sqlRepository) DbCommit(c *model.GormDB) {
 }
 
 func (s *sqlRepository) InternalTables(blockNum int) (models []interface{}) {
-	models = append(models, model.RuntimeVersion{})
+	models = append(models, model.RuntimeVersion{}, model.SyncCursor{}, model.DecodeFailure{}, model.PluginFailure{}, model.PluginSchemaMigration{})
 	for i := 0; i <= blockNum/model.SplitTableBlockNum; i++ {
 		models = append(
 			models,
@@ -454,6 +488,193 @@ func (s *sqlRepository) InternalTables(blockNum int) (models []interface{}) {
 	return models
 }
 
+// SaveSyncCursor moves a worker cursor forward, never backward, inside txn when given
+func (s *sqlRepository) SaveSyncCursor(txn *model.GormDB, cursor *model.SyncCursor) error {
+	db := s.DB
+	if txn != nil {
+		db = txn.DB
+	}
+	query := db.Exec(fmt.Sprintf("INSERT INTO %s (worker, network, plugin, block_num, finalized_block_num, updated_at) VALUES (?, ?, ?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE block_num = GREATEST(block_num, VALUES(block_num)), "+
+		"finalized_block_num = GREATEST(finalized_block_num, VALUES(finalized_block_num)), updated_at = VALUES(updated_at)",
+		model.SyncCursor{}.TableName()), cursor.Worker, util.NetworkNode, cursor.Plugin, cursor.BlockNum, cursor.FinalizedBlockNum, time.Now())
+	return query.Error
+}
+
+// AdvanceSyncCursor moves a cursor standing on the block before cursor.BlockNum to it inside txn, a cursor
+// anywhere else is left as is
+func (s *sqlRepository) AdvanceSyncCursor(txn *model.GormDB, cursor *model.SyncCursor) error {
+	query := txn.Exec(fmt.Sprintf("UPDATE %s SET block_num = GREATEST(block_num, ?), finalized_block_num = ?, updated_at = ? "+
+		"WHERE worker = ? AND network = ? AND plugin = ? AND finalized_block_num = ?", model.SyncCursor{}.TableName()),
+		cursor.BlockNum, cursor.FinalizedBlockNum, time.Now(), cursor.Worker, util.NetworkNode, cursor.Plugin, cursor.FinalizedBlockNum-1)
+	return query.Error
+}
+
+// RewindSyncCursor moves a cursor back to blockNum, a cursor already behind it is left as is
+func (s *sqlRepository) RewindSyncCursor(worker, plugin string, blockNum int) error {
+	query := s.DB.Exec(fmt.Sprintf("UPDATE %s SET block_num = LEAST(block_num, ?), finalized_block_num = LEAST(finalized_block_num, ?), updated_at = ? "+
+		"WHERE worker = ? AND network = ? AND plugin = ?", model.SyncCursor{}.TableName()),
+		blockNum, blockNum, time.Now(), worker, util.NetworkNode, plugin)
+	return query.Error
+}
+
+func (s *sqlRepository) GetSyncCursor(worker, plugin string) *model.SyncCursor {
+	var cursor model.SyncCursor
+	query := s.DB.Where("worker = ? AND network = ? AND plugin = ?", worker, util.NetworkNode, plugin).First(&cursor)
+	if query == nil || query.Error != nil || query.RecordNotFound() {
+		return nil
+	}
+	return &cursor
+}
+
+// SaveDecodeFailure records the last decode failure of a block, txn may be nil
+func (s *sqlRepository) SaveDecodeFailure(txn *model.GormDB, failure *model.DecodeFailure) error {
+	db := s.DB
+	if txn != nil {
+		db = txn.DB
+	}
+	query := db.Exec(fmt.Sprintf("INSERT INTO %s (block_num, block_hash, spec_version, stage, error, payload, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE block_hash = VALUES(block_hash), spec_version = VALUES(spec_version), stage = VALUES(stage), "+
+		"error = VALUES(error), payload = VALUES(payload), updated_at = VALUES(updated_at)",
+		model.DecodeFailure{}.TableName()), failure.BlockNum, failure.BlockHash, failure.SpecVersion, failure.Stage, failure.Error, failure.Payload, time.Now())
+	return query.Error
+}
+
+func (s *sqlRepository) DeleteDecodeFailure(txn *model.GormDB, blockNum int) error {
+	return txn.Where("block_num = ?", blockNum).Delete(model.DecodeFailure{}).Error
+}
+
+func (s *sqlRepository) GetDecodeFailureList(page, row int) ([]model.DecodeFailure, int) {
+	var (
+		failures []model.DecodeFailure
+		count    int
+	)
+	s.DB.Model(model.DecodeFailure{}).Count(&count)
+	query := s.DB.Order("block_num desc").Offset(page * row).Limit(row).Find(&failures)
+	if query == nil || query.Error != nil || query.RecordNotFound() {
+		return nil, count
+	}
+	return failures, count
+}
+
+func (s *sqlRepository) DecodeFailureBlockNums() []int {
+	var nums []int
+	s.DB.Model(model.DecodeFailure{}).Order("block_num asc").Pluck("block_num", &nums)
+	return nums
+}
+
+// SavePluginFailure records a failed plugin item in txn or, when nil, on its own, a failure of the same item
+// replaces the previous one
+func (s *sqlRepository) SavePluginFailure(txn *model.GormDB, failure *model.PluginFailure) error {
+	db := s.DB
+	if txn != nil {
+		db = txn.DB
+	}
+	now := time.Now()
+	query := db.Exec(fmt.Sprintf("INSERT INTO %s (plugin, block_num, kind, item_index, error, stack, attempts, next_retry_at, created_at, updated_at) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE error = VALUES(error), stack = VALUES(stack), "+
+		"attempts = VALUES(attempts), next_retry_at = VALUES(next_retry_at), updated_at = VALUES(updated_at)",
+		model.PluginFailure{}.TableName()), failure.Plugin, failure.BlockNum, failure.Kind, failure.ItemIndex, failure.Error, failure.Stack,
+		failure.Attempts, failure.NextRetryAt, now, now)
+	return query.Error
+}
+
+func (s *sqlRepository) GetPluginFailure(id uint) *model.PluginFailure {
+	var failure model.PluginFailure
+	query := s.DB.Where("id = ?", id).First(&failure)
+	if query == nil || query.Error != nil || query.RecordNotFound() {
+		return nil
+	}
+	return &failure
+}
+
+func (s *sqlRepository) GetPluginFailureList(page, row int, plugin string) ([]model.PluginFailure, int) {
+	var (
+		failures []model.PluginFailure
+		count    int
+	)
+	queryOrigin := s.DB.Model(model.PluginFailure{})
+	if plugin != "" {
+		queryOrigin = queryOrigin.Where("plugin = ?", plugin)
+	}
+	queryOrigin.Count(&count)
+	query := queryOrigin.Order("id desc").Offset(page * row).Limit(row).Find(&failures)
+	if query == nil || query.Error != nil || query.RecordNotFound() {
+		return nil, count
+	}
+	return failures, count
+}
+
+// DuePluginFailures lists the failures due for an automatic retry, oldest first
+func (s *sqlRepository) DuePluginFailures(maxAttempts, limit int) []model.PluginFailure {
+	var failures []model.PluginFailure
+	s.DB.Where("next_retry_at <= ? AND attempts < ?", time.Now(), maxAttempts).Order("next_retry_at asc").Limit(limit).Find(&failures)
+	return failures
+}
+
+func (s *sqlRepository) DeletePluginFailure(txn *model.GormDB, id uint) error {
+	db := s.DB
+	if txn != nil {
+		db = txn.DB
+	}
+	return db.Where("id = ?", id).Delete(model.PluginFailure{}).Error
+}
+
+// GetPluginSchemaVersion is the last migration applied to the tables of a plugin, 0 when none is
+func (s *sqlRepository) GetPluginSchemaVersion(plugin string) (int, error) {
+	if !s.DB.HasTable(model.PluginSchemaMigration{}) {
+		return 0, nil
+	}
+	var versions []int
+	query := s.DB.Model(model.PluginSchemaMigration{}).Where("plugin = ?", plugin).Order("version desc").Limit(1).Pluck("version", &versions)
+	if query.Error != nil || len(versions) == 0 {
+		return 0, query.Error
+	}
+	return versions[0], nil
+}
+
+// pluginMigrationsLockTimeout is how long, in seconds, a process waits for another one applying plugin migrations
+const pluginMigrationsLockTimeout = 3600
+
+// LockPluginMigrations holds the mysql named lock plugin_migrations on a connection of its own, so a single
+// process checks and applies the pending plugin migrations. unlock releases it
+func (s *sqlRepository) LockPluginMigrations() (unlock func(), err error) {
+	ctx := context.Background()
+	conn, err := s.DB.DB().Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var locked sql.NullInt64
+	if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", "plugin_migrations", pluginMigrationsLockTimeout).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if locked.Int64 != 1 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("plugin migrations are locked by another process for more than %ds", pluginMigrationsLockTimeout)
+	}
+	return func() {
+		if _, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", "plugin_migrations"); err != nil {
+			log.Error("Release plugin migrations lock error ", err)
+		}
+		_ = conn.Close()
+	}, nil
+}
+
+// SavePluginMigration records an applied step, the table is created first by the processes not running Migration
+func (s *sqlRepository) SavePluginMigration(migration *model.PluginSchemaMigration) error {
+	if !s.DB.HasTable(model.PluginSchemaMigration{}) {
+		if err := s.DB.Set("gorm:table_options", "ENGINE=InnoDB").AutoMigrate(model.PluginSchemaMigration{}).Error; err != nil {
+			return err
+		}
+		if err := s.DB.Model(model.PluginSchemaMigration{}).AddUniqueIndex("plugin_version", "plugin", "version").Error; err != nil {
+			return err
+		}
+	}
+	migration.AppliedAt = time.Now()
+	return s.DB.Create(migration).Error
+}
+
 func (s *sqlRepository) UpdateEventAndExtrinsic(txn *model.GormDB, block *model.ChainBlock, eventCount, extrinsicsCount, blockTimestamp int, validator string, codecError bool, finalized bool) error {
 	query := txn.Where("block_num = ?", block.BlockNum).Model(block).UpdateColumn(map[string]interface{}{
 		"event_count":      eventCount,
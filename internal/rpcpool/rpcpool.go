@@ -0,0 +1,317 @@
+package rpcpool
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/gorilla/websocket"
+	"github.com/itering/substrate-api-rpc/rpc"
+	ws "github.com/itering/substrate-api-rpc/websocket"
+)
+
+const (
+	// an endpoint is demoted when any of these is exceeded
+	MaxErrorRate = 0.5
+	MaxBlockLag  = 10
+	MaxLatency   = 5 * time.Second
+
+	probeTimeout = 5 * time.Second
+	// max connections of the substrate-api-rpc request pool
+	requestPoolSize = 25
+	// weight of the newest sample in the moving averages
+	sampleWeight = 0.2
+)
+
+// Endpoint is the health of one node as seen by probes and live traffic
+type Endpoint struct {
+	URL       string
+	Latency   time.Duration
+	ErrorRate float64
+	BestBlock uint64
+	LastError string
+	probed    bool
+}
+
+type EndpointStatus struct {
+	URL       string  `json:"url"`
+	Active    bool    `json:"active"`
+	LatencyMs int64   `json:"latency_ms"`
+	ErrorRate float64 `json:"error_rate"`
+	BestBlock uint64  `json:"best_block"`
+	Lag       uint64  `json:"lag"`
+	Score     float64 `json:"score"`
+	Demoted   string  `json:"demoted,omitempty"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+type Pool struct {
+	mu        sync.RWMutex
+	endpoints []*Endpoint
+	active    int
+	onSwitch  []func(url string)
+}
+
+func New(urls []string) *Pool {
+	p := &Pool{}
+	for _, url := range urls {
+		p.endpoints = append(p.endpoints, &Endpoint{URL: url})
+	}
+	return p
+}
+
+// Active is the endpoint subscription and request traffic should use
+func (p *Pool) Active() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.endpoints) == 0 {
+		return ""
+	}
+	return p.endpoints[p.active].URL
+}
+
+// OnSwitch registers fn to be called with the new endpoint after a failover
+func (p *Pool) OnSwitch(fn func(url string)) {
+	p.mu.Lock()
+	p.onSwitch = append(p.onSwitch, fn)
+	p.mu.Unlock()
+}
+
+// Observe records a request against url, bestBlock 0 means unknown
+func (p *Pool) Observe(url string, latency time.Duration, bestBlock uint64, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.endpoint(url)
+	if e == nil {
+		return
+	}
+	failed := 0.0
+	if err != nil {
+		failed = 1
+		e.LastError = err.Error()
+	} else {
+		if !e.probed {
+			e.Latency = latency
+		} else {
+			e.Latency = time.Duration(float64(e.Latency)*(1-sampleWeight) + float64(latency)*sampleWeight)
+		}
+		if bestBlock > 0 {
+			e.BestBlock = bestBlock
+		}
+	}
+	if !e.probed {
+		e.ErrorRate = failed
+	} else {
+		e.ErrorRate = e.ErrorRate*(1-sampleWeight) + failed*sampleWeight
+	}
+	e.probed = true
+}
+
+// Select keeps the active endpoint while it is healthy, otherwise fails over to the
+// healthy endpoint with the best score and notifies OnSwitch listeners
+func (p *Pool) Select() (url string, switched bool) {
+	p.mu.Lock()
+	if len(p.endpoints) == 0 {
+		p.mu.Unlock()
+		return "", false
+	}
+	statuses := p.statuses()
+	best := p.active
+	if statuses[p.active].Demoted != "" {
+		for i, s := range statuses {
+			if better(s, statuses[best]) {
+				best = i
+			}
+		}
+	}
+	switched = best != p.active
+	p.active = best
+	url = p.endpoints[best].URL
+	listeners := p.onSwitch
+	p.mu.Unlock()
+
+	if switched {
+		for _, fn := range listeners {
+			fn(url)
+		}
+	}
+	return url, switched
+}
+
+// Status reports every endpoint, the active one first
+func (p *Pool) Status() []EndpointStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	statuses := p.statuses()
+	sort.SliceStable(statuses, func(i, j int) bool { return statuses[i].Active && !statuses[j].Active })
+	return statuses
+}
+
+// Probe measures latency and best block of every endpoint, then re-selects the active one
+func (p *Pool) Probe() {
+	p.mu.RLock()
+	var urls []string
+	for _, e := range p.endpoints {
+		urls = append(urls, e.URL)
+	}
+	p.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, url := range urls {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			start := time.Now()
+			best, err := probe(url)
+			p.Observe(url, time.Since(start), best, err)
+		}(url)
+	}
+	wg.Wait()
+	p.Select()
+}
+
+func (p *Pool) endpoint(url string) *Endpoint {
+	for _, e := range p.endpoints {
+		if e.URL == url {
+			return e
+		}
+	}
+	return nil
+}
+
+func (p *Pool) statuses() []EndpointStatus {
+	var maxBest uint64
+	for _, e := range p.endpoints {
+		if e.BestBlock > maxBest {
+			maxBest = e.BestBlock
+		}
+	}
+	statuses := make([]EndpointStatus, len(p.endpoints))
+	for i, e := range p.endpoints {
+		s := EndpointStatus{
+			URL:       e.URL,
+			Active:    i == p.active,
+			LatencyMs: e.Latency.Milliseconds(),
+			ErrorRate: e.ErrorRate,
+			BestBlock: e.BestBlock,
+			LastError: e.LastError,
+		}
+		if e.BestBlock > 0 {
+			s.Lag = maxBest - e.BestBlock
+		}
+		s.Score = float64(s.LatencyMs) + float64(s.Lag)*1000 + s.ErrorRate*10000
+		switch {
+		case !e.probed:
+			s.Demoted = "not probed yet"
+		case e.ErrorRate > MaxErrorRate:
+			s.Demoted = fmt.Sprintf("error rate %.2f above %.2f: %s", e.ErrorRate, MaxErrorRate, e.LastError)
+		case s.Lag > MaxBlockLag:
+			s.Demoted = fmt.Sprintf("best block %d lags %d blocks behind", e.BestBlock, s.Lag)
+		case e.Latency > MaxLatency:
+			s.Demoted = fmt.Sprintf("latency %s above %s", e.Latency.Round(time.Millisecond), MaxLatency)
+		}
+		statuses[i] = s
+	}
+	return statuses
+}
+
+// better prefers healthy endpoints, then the lower score
+func better(a, b EndpointStatus) bool {
+	if (a.Demoted == "") != (b.Demoted == "") {
+		return a.Demoted == ""
+	}
+	return a.Score < b.Score
+}
+
+// probe asks url for its best header on a short lived connection
+func probe(url string) (uint64, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: probeTimeout}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	_ = conn.SetWriteDeadline(time.Now().Add(probeTimeout))
+	_ = conn.SetReadDeadline(time.Now().Add(probeTimeout))
+
+	request, _ := json.Marshal(rpc.JsonRpcParams{Id: 1, JsonRpc: "2.0", Method: "chain_getHeader", Params: []string{}})
+	if err = conn.WriteMessage(websocket.TextMessage, request); err != nil {
+		return 0, err
+	}
+	var v struct {
+		Result *rpc.ChainNewHeadResult `json:"result"`
+		Error  *rpc.Error              `json:"error"`
+	}
+	if err = conn.ReadJSON(&v); err != nil {
+		return 0, err
+	}
+	if v.Error != nil {
+		return 0, fmt.Errorf("chain_getHeader error: %s", v.Error.Message)
+	}
+	if v.Result == nil {
+		return 0, fmt.Errorf("chain_getHeader empty result")
+	}
+	head := v.Result
+	return uint64(util.StringToInt(util.HexToNumStr(head.Number))), nil
+}
+
+var defaultPool = New(util.WSEndPoints)
+
+func Active() string {
+	return defaultPool.Active()
+}
+
+func OnSwitch(fn func(url string)) {
+	defaultPool.OnSwitch(fn)
+}
+
+func Observe(url string, latency time.Duration, bestBlock uint64, err error) {
+	defaultPool.Observe(url, latency, bestBlock, err)
+}
+
+func Status() []EndpointStatus {
+	return defaultPool.Status()
+}
+
+// Probe checks every configured endpoint once, use it before the first dial
+func Probe() {
+	defaultPool.Probe()
+}
+
+// Watch probes all endpoints every interval and hands the result to report until done is closed
+func Watch(interval time.Duration, done <-chan struct{}, report func([]EndpointStatus)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		defaultPool.Probe()
+		if report != nil {
+			report(defaultPool.Status())
+		}
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
+
+// SwitchRequestPool points the substrate-api-rpc request pool to url, pooled
+// connections still dialed to the old endpoint are closed as they are taken
+func SwitchRequestPool(url string) {
+	ws.SetEndpoint(url)
+	for i := 0; i < requestPoolSize; i++ {
+		p, err := ws.Init()
+		if err != nil {
+			return
+		}
+		if p.Conn.GetURL() == url {
+			_ = p.Close()
+			return
+		}
+		p.MarkUnusable()
+		_ = p.Close()
+	}
+}
@@ -0,0 +1,63 @@
+package rpcpool
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolFailover(t *testing.T) {
+	p := New([]string{"ws://a", "ws://b", "ws://c"})
+	assert.Equal(t, "ws://a", p.Active())
+
+	var switchedTo string
+	p.OnSwitch(func(url string) { switchedTo = url })
+
+	p.Observe("ws://a", 100*time.Millisecond, 100, nil)
+	p.Observe("ws://b", 50*time.Millisecond, 100, nil)
+	p.Observe("ws://c", 10*time.Millisecond, 100, nil)
+
+	// a healthy active endpoint is kept even when another one scores better
+	url, switched := p.Select()
+	assert.Equal(t, "ws://a", url)
+	assert.False(t, switched)
+
+	// a stops answering
+	for i := 0; i < 5; i++ {
+		p.Observe("ws://a", 0, 0, errors.New("i/o timeout"))
+	}
+	// c falls behind the others
+	p.Observe("ws://b", 50*time.Millisecond, 130, nil)
+	p.Observe("ws://c", 10*time.Millisecond, 101, nil)
+
+	url, switched = p.Select()
+	assert.True(t, switched)
+	assert.Equal(t, "ws://b", url)
+	assert.Equal(t, "ws://b", switchedTo)
+
+	status := p.Status()
+	assert.Equal(t, "ws://b", status[0].URL)
+	assert.True(t, status[0].Active)
+	assert.Empty(t, status[0].Demoted)
+	for _, s := range status[1:] {
+		switch s.URL {
+		case "ws://a":
+			assert.Contains(t, s.Demoted, "error rate")
+		case "ws://c":
+			assert.Equal(t, uint64(29), s.Lag)
+			assert.Contains(t, s.Demoted, "lags 29 blocks")
+		}
+	}
+}
+
+func TestPoolAllDemoted(t *testing.T) {
+	p := New([]string{"ws://a", "ws://b"})
+	p.Observe("ws://a", 0, 0, errors.New("refused"))
+	p.Observe("ws://b", 6*time.Second, 10, nil)
+
+	// with nothing healthy the lowest score wins
+	url, _ := p.Select()
+	assert.Equal(t, "ws://b", url)
+}
@@ -15,6 +15,7 @@ func Install(conf string) {
 	_ = fileCopy(fmt.Sprintf("%s/http.toml.example", conf), fmt.Sprintf("%s/http.toml", conf))
 	_ = fileCopy(fmt.Sprintf("%s/mysql.toml.example", conf), fmt.Sprintf("%s/mysql.toml", conf))
 	_ = fileCopy(fmt.Sprintf("%s/redis.toml.example", conf), fmt.Sprintf("%s/redis.toml", conf))
+	_ = fileCopy(fmt.Sprintf("%s/plugins.toml.example", conf), fmt.Sprintf("%s/plugins.toml", conf))
 
 	func() {
 		dbHost := util.GetEnv("MYSQL_HOST", "127.0.0.1")
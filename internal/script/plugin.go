@@ -0,0 +1,266 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/CoolBitX-Technology/subscan/util"
+)
+
+// modulePath is the import path generated plugins live under
+const modulePath = "github.com/CoolBitX-Technology/subscan"
+
+// PluginOption is the plugin NewPlugin scaffolds
+type PluginOption struct {
+	// package and plugin name, E.g staking
+	Name string
+	// Module.Event or Module.call, with optional params E.g Staking.Reward(stash:AccountId,amount:Balance)
+	Events []string
+	Calls  []string
+	// plugins directory, its registry.go registers the plugin
+	Dir string
+	// leave registry.go alone
+	NoRegister bool
+}
+
+type scaffold struct {
+	Package string
+	Type    string
+	Import  string
+	Events  []*subscription
+	Calls   []*subscription
+}
+
+type subscription struct {
+	Module string
+	Name   string
+	// module-name lowercased, as matched by the plugin
+	Key    string
+	Struct string
+	Fields []*paramField
+	// quoted JSON params of the test sample
+	Sample string
+}
+
+type paramField struct {
+	Name string
+	// param name in calls and the JSON tag
+	Param   string
+	Type    string
+	GoType  string
+	Convert string
+}
+
+var (
+	pluginNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+	specRegexp       = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_]*)\.([A-Za-z][A-Za-z0-9_]*)(?:\((.*)\))?$`)
+	paramNameRegexp  = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+)
+
+// NewPlugin writes the skeleton of a plugin to <Dir>/<Name> and registers it in <Dir>/registry.go
+func NewPlugin(o PluginOption) error {
+	if !pluginNameRegexp.MatchString(o.Name) {
+		return fmt.Errorf("invalid plugin name %q, want a lower case go package name", o.Name)
+	}
+	if len(o.Events) == 0 && len(o.Calls) == 0 {
+		return errors.New("subscribe at least one event or call, E.g --events Balances.Transfer")
+	}
+	root := filepath.Join(o.Dir, o.Name)
+	if _, err := os.Stat(root); err == nil {
+		return fmt.Errorf("%s already exists", root)
+	}
+	s := &scaffold{Package: o.Name, Type: util.UpperCamel(o.Name), Import: modulePath + "/plugins/" + o.Name}
+	for _, spec := range o.Events {
+		sub, err := parseSubscription(spec, "Event")
+		if err != nil {
+			return err
+		}
+		s.Events = append(s.Events, sub)
+	}
+	for _, spec := range o.Calls {
+		sub, err := parseSubscription(spec, "Call")
+		if err != nil {
+			return err
+		}
+		s.Calls = append(s.Calls, sub)
+	}
+
+	files := map[string]*template.Template{
+		o.Name + ".go":   pluginTemplate,
+		"model/model.go": modelTemplate,
+		"model/mocks/" + s.Type + "Repository.go": mockTemplate,
+		"repository/sql_repository.go":            repositoryTemplate,
+		"service/service.go":                      serviceTemplate,
+		"service/service_test.go":                 serviceTestTemplate,
+		"http/http.go":                            httpTemplate,
+	}
+	for name, t := range files {
+		if err := writeSource(filepath.Join(root, name), t, s); err != nil {
+			_ = os.RemoveAll(root)
+			return err
+		}
+	}
+	if o.NoRegister {
+		return nil
+	}
+	return registerPlugin(filepath.Join(o.Dir, "registry.go"), s)
+}
+
+// parseSubscription parses Module.Name(param:Type,...), a param without a name is named param<i>
+func parseSubscription(spec, kind string) (*subscription, error) {
+	match := specRegexp.FindStringSubmatch(strings.TrimSpace(spec))
+	if match == nil {
+		return nil, fmt.Errorf("invalid %s %q, want Module.Name(param:Type,...)", strings.ToLower(kind), spec)
+	}
+	sub := &subscription{
+		Module: match[1],
+		Name:   match[2],
+		Key:    strings.ToLower(match[1] + "-" + match[2]),
+		Struct: util.CamelString(match[1]) + util.CamelString(match[2]) + kind,
+	}
+	names := map[string]bool{}
+	sample := make([]map[string]interface{}, 0)
+	for i, param := range util.SplitAndTrim(match[3], ",") {
+		name, typ := fmt.Sprintf("param%d", i), param
+		if nt := strings.SplitN(param, ":", 2); len(nt) == 2 {
+			name, typ = strings.TrimSpace(nt[0]), strings.TrimSpace(nt[1])
+		}
+		if !paramNameRegexp.MatchString(name) || typ == "" {
+			return nil, fmt.Errorf("invalid param %q of %s", param, spec)
+		}
+		f := &paramField{Name: util.UpperCamel(util.CamelString(name)), Param: name, Type: typ}
+		if names[f.Name] {
+			return nil, fmt.Errorf("duplicate param %s of %s", name, spec)
+		}
+		names[f.Name] = true
+		var value interface{}
+		f.GoType, f.Convert, value = paramGoType(typ)
+		sub.Fields = append(sub.Fields, f)
+		item := map[string]interface{}{"type": typ, "value": value}
+		if kind == "Call" {
+			item["name"] = name
+		}
+		sample = append(sample, item)
+	}
+	sub.Sample = strconv.Quote(util.ToString(sample))
+	return sub, nil
+}
+
+// paramGoType maps a substrate type to its go field type, the conversion of a decoded value and a sample value
+func paramGoType(typ string) (string, string, interface{}) {
+	t := strings.TrimPrefix(typ, "T::")
+	if strings.HasPrefix(t, "Compact<") && strings.HasSuffix(t, ">") {
+		t = strings.TrimSuffix(strings.TrimPrefix(t, "Compact<"), ">")
+	}
+	t = strings.TrimSuffix(t, "<T>")
+	switch t {
+	case "AccountId", "Address", "LookupSource", "MultiAddress", "Hash", "H256", "Text", "Bytes", "Vec<u8>":
+		return "string", "util.ToString(%s)", "76729e17ad31469debcb60f3ce3622f79143e442e77b58d6e2195d9ea998680d"
+	case "Balance", "BalanceOf", "u128", "u256":
+		return "decimal.Decimal", "util.DecimalFromInterface(%s)", "484253744395"
+	case "u8", "u16", "u32", "i8", "i16", "i32", "BlockNumber", "EraIndex", "SessionIndex":
+		return "int", "util.IntFromInterface(%s)", 1
+	case "u64", "i64", "Moment":
+		return "int64", "util.Int64FromInterface(%s)", 1
+	case "bool":
+		return "bool", "util.BoolFromInterface(%s)", true
+	}
+	return "interface{}", "%s", nil
+}
+
+func writeSource(path string, t *template.Template, data interface{}) error {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("format %s: %v", path, err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, src, 0644)
+}
+
+// registerPlugin imports the plugin in registry.go and appends registerNative(<name>.New()) to its init
+func registerPlugin(path string, s *scaffold) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	code := string(src)
+	imports := strings.Index(code, "import (\n")
+	init := strings.Index(code, "func init() {\n")
+	if imports < 0 || init < 0 {
+		return fmt.Errorf("%s has no import block or init to register %s in", path, s.Package)
+	}
+	end := init + strings.Index(code[init:], "\n}\n") + 1
+	code = code[:end] + fmt.Sprintf("\tregisterNative(%s.New())\n", s.Package) + code[end:]
+	// next to the other plugins, sorted below
+	after := imports + len("import (\n")
+	if i := strings.Index(code[imports:], "\t\""+modulePath+"/plugins/"); i > 0 {
+		after = imports + i
+	}
+	code = code[:after] + fmt.Sprintf("\t%q\n", s.Import) + code[after:]
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, code, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	ast.SortImports(fset, file)
+	var buf bytes.Buffer
+	if err = format.Node(&buf, fset, file); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func (s *scaffold) Modules(subs []*subscription) []string {
+	var modules []string
+	for _, sub := range subs {
+		if m := strings.ToLower(sub.Module); !util.StringInSlice(m, modules) {
+			modules = append(modules, m)
+		}
+	}
+	return modules
+}
+
+// Uses reports whether a field of the subscriptions has a go type, or a conversion calling util when typ is util
+func (s *scaffold) Uses(typ string) bool {
+	for _, sub := range append(append([]*subscription{}, s.Events...), s.Calls...) {
+		for _, f := range sub.Fields {
+			if f.GoType == typ || (typ == "util" && strings.HasPrefix(f.Convert, "util.")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// EventFields reports whether an event has params, their count is checked
+func (s *scaffold) EventFields() bool {
+	for _, sub := range s.Events {
+		if len(sub.Fields) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *paramField) Value(v string) string {
+	return fmt.Sprintf(f.Convert, v)
+}
@@ -0,0 +1,471 @@
+package script
+
+import "text/template"
+
+// templates of NewPlugin, {{tag "..."}} writes a struct tag as a raw string cannot hold a backquote
+var templateFuncs = template.FuncMap{
+	"tag": func(s string) string { return "`" + s + "`" },
+}
+
+func newTemplate(name, text string) *template.Template {
+	return template.Must(template.New(name).Funcs(templateFuncs).Parse(text))
+}
+
+var pluginTemplate = newTemplate("plugin", `package {{.Package}}
+
+import (
+	"fmt"
+	"strings"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"{{.Import}}/http"
+	"{{.Import}}/model"
+	"{{.Import}}/repository"
+	"{{.Import}}/service"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/itering/subscan-plugin/router"
+	"github.com/prometheus/common/log"
+	"github.com/shopspring/decimal"
+)
+
+var srv model.{{.Type}}Service
+
+type {{.Type}} struct {
+	d m.Dao
+}
+
+func New() *{{.Type}} {
+	return &{{.Type}}{}
+}
+
+func (a *{{.Type}}) InitDao(d m.Dao) {
+	srv = service.New(repository.NewsqlRepository(d))
+	a.d = d
+	a.Migrate()
+}
+
+func (a *{{.Type}}) InitHttp() []router.Http {
+	return nil
+}
+
+func (a *{{.Type}}) Routes() []m.PluginRoute {
+	return http.Routes(srv)
+}
+
+func (a *{{.Type}}) ProcessExtrinsic(b *m.Block, e *m.Extrinsic, events []m.Event) error {
+{{- if .Calls}}
+	if !e.Success {
+		return nil
+	}
+	var params []m.ExtrinsicParam
+	util.UnmarshalAny(&params, e.Params)
+	switch fmt.Sprintf("%s-%s", strings.ToLower(e.CallModule), strings.ToLower(e.CallModuleFunction)) {
+{{- range .Calls}}
+	case "{{.Key}}":
+		args, err := model.New{{.Struct}}(params)
+		if err != nil {
+			return err
+		}
+		return srv.Process{{.Struct}}(b, e, args)
+{{- end}}
+	}
+{{- end}}
+	return nil
+}
+
+func (a *{{.Type}}) ProcessEvent(b *m.Block, e *m.Event, fee decimal.Decimal) error {
+{{- if .Events}}
+	var params []m.EventParam
+	util.UnmarshalAny(&params, e.Params)
+	switch fmt.Sprintf("%s-%s", strings.ToLower(e.ModuleId), strings.ToLower(e.EventId)) {
+{{- range .Events}}
+	case "{{.Key}}":
+		args, err := model.New{{.Struct}}(params)
+		if err != nil {
+			return err
+		}
+		return srv.Process{{.Struct}}(b, e, args)
+{{- end}}
+	}
+{{- end}}
+	return nil
+}
+
+// Rollback drops the rows of the blocks after blockNum
+func (a *{{.Type}}) Rollback(blockNum int) error {
+	return a.d.Delete(&model.{{.Type}}{}, fmt.Sprintf("block_num > %d", blockNum))
+}
+
+func (a *{{.Type}}) {{.Type}}List(page int, row int) ([]model.{{.Type}}, error) {
+	return srv.Get{{.Type}}List(page, row)
+}
+
+// Plugins version
+func (a *{{.Type}}) Version() string {
+	return "0.1"
+}
+
+// Subscribe Extrinsic with special module
+func (a *{{.Type}}) SubscribeExtrinsic() []string {
+	return {{if .Calls}}[]string{ {{- range $i, $m := .Modules .Calls}}{{if $i}}, {{end}}"{{$m}}"{{end -}} }{{else}}nil{{end}}
+}
+
+// Subscribe Events with special module
+func (a *{{.Type}}) SubscribeEvent() []string {
+	return {{if .Events}}[]string{ {{- range $i, $m := .Modules .Events}}{{if $i}}, {{end}}"{{$m}}"{{end -}} }{{else}}nil{{end}}
+}
+
+func (a *{{.Type}}) Migrate() {
+	var e error
+	if e = a.d.AutoMigration(&model.{{.Type}}{}); e != nil {
+		log.Error(e)
+	}
+	if e = a.d.AddUniqueIndex(&model.{{.Type}}{}, "record_index", "extrinsic_index", "event_index"); e != nil {
+		log.Error(e)
+	}
+	if e = a.d.AddIndex(&model.{{.Type}}{}, "block_num", "block_num"); e != nil {
+		log.Error(e)
+	}
+}
+`)
+
+var modelTemplate = newTemplate("model", `package model
+
+import (
+{{- if .EventFields}}
+	"fmt"
+{{end}}
+	"github.com/CoolBitX-Technology/subscan/model"
+{{- if .Uses "util"}}
+	"github.com/CoolBitX-Technology/subscan/util"
+{{- end}}
+{{- if .Uses "decimal.Decimal"}}
+	"github.com/shopspring/decimal"
+{{- end}}
+)
+
+// {{.Type}} is one subscribed event or call, the params are the JSON of its param struct
+type {{.Type}} struct {
+	ID             uint   {{tag "gorm:\"primary_key\" json:\"-\""}}
+	BlockNum       int    {{tag "json:\"block_num\""}}
+	BlockTimestamp int    {{tag "json:\"block_timestamp\""}}
+	ExtrinsicIndex string {{tag "json:\"extrinsic_index\" sql:\"size:100\""}}
+	EventIndex     string {{tag "json:\"event_index\" sql:\"size:100\""}}
+	Module         string {{tag "json:\"module\" sql:\"size:100\""}}
+	Name           string {{tag "json:\"name\" sql:\"size:100\""}}
+	Params         string {{tag "json:\"params\" sql:\"type:text\""}}
+}
+{{range .Events}}
+// {{.Struct}} are the params of event {{.Module}}.{{.Name}}
+type {{.Struct}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.GoType}} {{tag (printf "json:%q" .Param)}} // {{.Type}}
+{{- end}}
+}
+
+func New{{.Struct}}(params []model.EventParam) (*{{.Struct}}, error) {
+{{- if .Fields}}
+	if len(params) < {{len .Fields}} {
+		return nil, fmt.Errorf("event {{.Module}}.{{.Name}} has %d params, want {{len .Fields}}", len(params))
+	}
+	return &{{.Struct}}{
+{{- range $i, $f := .Fields}}
+		{{$f.Name}}: {{$f.Value (printf "params[%d].Value" $i)}},
+{{- end}}
+	}, nil
+{{- else}}
+	return &{{.Struct}}{}, nil
+{{- end}}
+}
+{{end}}
+{{- range .Calls}}
+// {{.Struct}} are the params of call {{.Module}}.{{.Name}}
+type {{.Struct}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.GoType}} {{tag (printf "json:%q" .Param)}} // {{.Type}}
+{{- end}}
+}
+
+func New{{.Struct}}(params []model.ExtrinsicParam) (*{{.Struct}}, error) {
+	c := &{{.Struct}}{}
+{{- if .Fields}}
+	for _, param := range params {
+		switch param.Name {
+{{- range .Fields}}
+		case "{{.Param}}":
+			c.{{.Name}} = {{.Value "param.Value"}}
+{{- end}}
+		}
+	}
+{{- end}}
+	return c, nil
+}
+{{end}}
+type {{.Type}}Delivery interface {
+	{{.Type}}List(page int, row int) ([]{{.Type}}, error)
+}
+
+type {{.Type}}Service interface {
+{{- range .Events}}
+	Process{{.Struct}}(b *model.Block, e *model.Event, params *{{.Struct}}) error
+{{- end}}
+{{- range .Calls}}
+	Process{{.Struct}}(b *model.Block, e *model.Extrinsic, params *{{.Struct}}) error
+{{- end}}
+	Get{{.Type}}List(page, row int) ([]{{.Type}}, error)
+}
+
+type {{.Type}}Repository interface {
+	Save{{.Type}}(r *{{.Type}}) error
+	Get{{.Type}}List(page, row int) ([]{{.Type}}, error)
+}
+`)
+
+var repositoryTemplate = newTemplate("repository", `package repository
+
+import (
+	"fmt"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"{{.Import}}/model"
+)
+
+var PluginPrefix = "{{.Package}}"
+
+type sqlRepository struct {
+	DB m.Dao
+}
+
+func NewsqlRepository(db m.Dao) model.{{.Type}}Repository {
+	return &sqlRepository{
+		DB: db,
+	}
+}
+
+func (s *sqlRepository) Save{{.Type}}(r *model.{{.Type}}) error {
+	txn := s.DB.BlockTxn(r.BlockNum)
+	defer s.DB.DbRollback(txn)
+	tableName := fmt.Sprintf("%s_%s", PluginPrefix, txn.DB.Unscoped().NewScope(r).TableName())
+	if query := txn.DB.Table(tableName).Create(r); query.Error != nil {
+		return query.Error
+	}
+	s.DB.DbCommit(txn)
+	return nil
+}
+
+func (s *sqlRepository) Get{{.Type}}List(page, row int) ([]model.{{.Type}}, error) {
+	var list []model.{{.Type}}
+	opt := m.Option{PluginPrefix: PluginPrefix, Page: page, PageSize: row, Order: "id desc"}
+	err := s.DB.FindBy(&list, nil, &opt)
+	return list, err
+}
+`)
+
+var serviceTemplate = newTemplate("service", `package service
+
+import (
+{{- if .Events}}
+	"fmt"
+{{end}}
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"{{.Import}}/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+)
+
+type Service struct {
+	sql model.{{.Type}}Repository
+}
+
+func New(r model.{{.Type}}Repository) model.{{.Type}}Service {
+	return &Service{
+		sql: r,
+	}
+}
+{{range .Events}}
+func (s *Service) Process{{.Struct}}(b *m.Block, e *m.Event, params *model.{{.Struct}}) error {
+	return s.sql.Save{{$.Type}}(&model.{{$.Type}}{
+		BlockNum:       b.BlockNum,
+		BlockTimestamp: b.BlockTimestamp,
+		ExtrinsicIndex: fmt.Sprintf("%d-%d", e.BlockNum, e.ExtrinsicIdx),
+		EventIndex:     fmt.Sprintf("%d-%d", e.BlockNum, e.EventIdx),
+		Module:         e.ModuleId,
+		Name:           e.EventId,
+		Params:         util.ToString(params),
+	})
+}
+{{end}}
+{{- range .Calls}}
+func (s *Service) Process{{.Struct}}(b *m.Block, e *m.Extrinsic, params *model.{{.Struct}}) error {
+	return s.sql.Save{{$.Type}}(&model.{{$.Type}}{
+		BlockNum:       b.BlockNum,
+		BlockTimestamp: b.BlockTimestamp,
+		ExtrinsicIndex: e.ExtrinsicIndex,
+		Module:         e.CallModule,
+		Name:           e.CallModuleFunction,
+		Params:         util.ToString(params),
+	})
+}
+{{end}}
+func (s *Service) Get{{.Type}}List(page, row int) ([]model.{{.Type}}, error) {
+	return s.sql.Get{{.Type}}List(page, row)
+}
+`)
+
+var serviceTestTemplate = newTemplate("service_test", `package service_test
+
+import (
+	"testing"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"{{.Import}}/model"
+	"{{.Import}}/model/mocks"
+	"{{.Import}}/service"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var mockBlock = m.Block{
+	BlockNum:       5096104,
+	BlockTimestamp: 1621217148,
+	Hash:           "0x0dd1681b802bbb270d1cd91bd11bfeada72993241f7340d01086ddca60def9f1",
+	SpecVersion:    30,
+	Finalized:      true,
+}
+{{range .Events}}
+func TestProcess{{.Struct}}(t *testing.T) {
+	mockRepo := new(mocks.{{$.Type}}Repository)
+	mockRepo.On("Save{{$.Type}}", mock.Anything).Return(nil)
+	mockEvent := m.Event{
+		BlockNum:     mockBlock.BlockNum,
+		ExtrinsicIdx: 1,
+		ModuleId:     "{{.Module}}",
+		EventId:      "{{.Name}}",
+		Params:       []byte({{.Sample}}),
+		EventIdx:     2,
+	}
+
+	var params []m.EventParam
+	util.UnmarshalAny(&params, mockEvent.Params)
+	args, err := model.New{{.Struct}}(params)
+	assert.NoError(t, err)
+	assert.NoError(t, service.New(mockRepo).Process{{.Struct}}(&mockBlock, &mockEvent, args))
+	mockRepo.AssertCalled(t, "Save{{$.Type}}", mock.MatchedBy(func(r *model.{{$.Type}}) bool {
+		return r.EventIndex == "5096104-2" && r.ExtrinsicIndex == "5096104-1" && r.Params == util.ToString(args)
+	}))
+}
+{{end}}
+{{- range .Calls}}
+func TestProcess{{.Struct}}(t *testing.T) {
+	mockRepo := new(mocks.{{$.Type}}Repository)
+	mockRepo.On("Save{{$.Type}}", mock.Anything).Return(nil)
+	mockExtrinsic := m.Extrinsic{
+		ExtrinsicIndex:     "5096104-1",
+		CallModule:         "{{.Module}}",
+		CallModuleFunction: "{{.Name}}",
+		Params:             []byte({{.Sample}}),
+		Success:            true,
+	}
+
+	var params []m.ExtrinsicParam
+	util.UnmarshalAny(&params, mockExtrinsic.Params)
+	args, err := model.New{{.Struct}}(params)
+	assert.NoError(t, err)
+	assert.NoError(t, service.New(mockRepo).Process{{.Struct}}(&mockBlock, &mockExtrinsic, args))
+	mockRepo.AssertCalled(t, "Save{{$.Type}}", mock.MatchedBy(func(r *model.{{$.Type}}) bool {
+		return r.ExtrinsicIndex == "5096104-1" && r.Params == util.ToString(args)
+	}))
+}
+{{end}}`)
+
+var mockTemplate = newTemplate("mock", `// Code generated by mockery 2.7.5. DO NOT EDIT.
+
+package mocks
+
+import (
+	model "{{.Import}}/model"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// {{.Type}}Repository is an autogenerated mock type for the {{.Type}}Repository type
+type {{.Type}}Repository struct {
+	mock.Mock
+}
+
+// Get{{.Type}}List provides a mock function with given fields: page, row
+func (_m *{{.Type}}Repository) Get{{.Type}}List(page int, row int) ([]model.{{.Type}}, error) {
+	ret := _m.Called(page, row)
+
+	var r0 []model.{{.Type}}
+	if rf, ok := ret.Get(0).(func(int, int) []model.{{.Type}}); ok {
+		r0 = rf(page, row)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.{{.Type}})
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int, int) error); ok {
+		r1 = rf(page, row)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Save{{.Type}} provides a mock function with given fields: r
+func (_m *{{.Type}}Repository) Save{{.Type}}(r *model.{{.Type}}) error {
+	ret := _m.Called(r)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*model.{{.Type}}) error); ok {
+		r0 = rf(r)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+`)
+
+var httpTemplate = newTemplate("http", `package http
+
+import (
+	"net/http"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"{{.Import}}/model"
+)
+
+var (
+	svc model.{{.Type}}Service
+)
+
+type listParams struct {
+	Row  int {{tag "json:\"row\" form:\"row\" validate:\"min=1,max=100\""}}
+	Page int {{tag "json:\"page\" form:\"page\" validate:\"min=0\""}}
+}
+
+// Routes serves GET or POST /api/plugin/{{.Package}}/list
+func Routes(s model.{{.Type}}Service) []m.PluginRoute {
+	svc = s
+	params := func() interface{} { return new(listParams) }
+	return []m.PluginRoute{
+		{Method: http.MethodGet, Path: "list", Params: params, Handle: list},
+		{Method: http.MethodPost, Path: "list", Params: params, Handle: list},
+	}
+}
+
+func list(r *m.PluginRequest) (interface{}, error) {
+	p := r.Params.(*listParams)
+	list, err := svc.Get{{.Type}}List(p.Page, p.Row)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"list": list, "count": len(list),
+	}, nil
+}
+`)
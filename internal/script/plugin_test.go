@@ -0,0 +1,84 @@
+package script
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSubscription(t *testing.T) {
+	sub, err := parseSubscription("Staking.Reward(stash:AccountId, amount:Balance,u32)", "Event")
+	assert.NoError(t, err)
+	assert.Equal(t, "staking-reward", sub.Key)
+	assert.Equal(t, "StakingRewardEvent", sub.Struct)
+	assert.Len(t, sub.Fields, 3)
+	assert.Equal(t, "Stash", sub.Fields[0].Name)
+	assert.Equal(t, "decimal.Decimal", sub.Fields[1].GoType)
+	assert.Equal(t, "util.IntFromInterface(params[2].Value)", sub.Fields[2].Value("params[2].Value"))
+
+	sub, err = parseSubscription("Balances.transfer_keep_alive(dest:Address,value:Compact<Balance>)", "Call")
+	assert.NoError(t, err)
+	assert.Equal(t, "BalancesTransferKeepAliveCall", sub.Struct)
+	assert.Equal(t, "decimal.Decimal", sub.Fields[1].GoType)
+
+	for _, spec := range []string{"Staking", "Staking.Reward(:Balance)", "Staking.Reward(a:Balance,a:u32)"} {
+		_, err = parseSubscription(spec, "Event")
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestNewPlugin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugins")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	registry := `package plugins
+
+import (
+	"github.com/CoolBitX-Technology/subscan/plugins/bond"
+)
+
+func init() {
+	registerNative(bond.New())
+}
+`
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "registry.go"), []byte(registry), 0644))
+
+	o := PluginOption{Name: "staking", Dir: dir, Events: []string{"Staking.Reward(stash:AccountId,amount:Balance)"}, Calls: []string{"Staking.bond"}}
+	assert.NoError(t, NewPlugin(o))
+	assert.EqualError(t, NewPlugin(o), filepath.Join(dir, "staking")+" already exists")
+	assert.Error(t, NewPlugin(PluginOption{Name: "Bad-Name", Dir: dir, Calls: o.Calls}))
+	assert.Error(t, NewPlugin(PluginOption{Name: "empty", Dir: dir}))
+
+	src, err := ioutil.ReadFile(filepath.Join(dir, "registry.go"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(src), "\t\"github.com/CoolBitX-Technology/subscan/plugins/staking\"\n")
+	assert.Contains(t, string(src), "registerNative(bond.New())\n\tregisterNative(staking.New())\n}")
+	for _, f := range []string{"staking.go", "model/model.go", "model/mocks/StakingRepository.go", "repository/sql_repository.go",
+		"service/service.go", "service/service_test.go", "http/http.go"} {
+		assert.FileExists(t, filepath.Join(dir, "staking", f))
+	}
+}
+
+// TestNewPluginCompiles scaffolds into plugins/ and runs the generated test
+func TestNewPluginCompiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test on the scaffold")
+	}
+	name := "scaffoldtest"
+	dir := filepath.Join("..", "..", "plugins")
+	defer os.RemoveAll(filepath.Join(dir, name))
+	assert.NoError(t, NewPlugin(PluginOption{
+		Name:       name,
+		Dir:        dir,
+		NoRegister: true,
+		Events:     []string{"Staking.Reward(stash:AccountId,amount:Balance)", "Staking.Chilled"},
+		Calls:      []string{"Staking.bond(controller:Address,value:Compact<BalanceOf>,payee:RewardDestination)"},
+	}))
+	out, err := exec.Command("go", "test", "./"+strings.Join([]string{dir, name, "..."}, "/")).CombinedOutput()
+	assert.NoError(t, err, string(out))
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminAuth guards the admin apis, a request needs the bearer token or, without a token configured, to come
+// from the loopback address. The remote address is used as X-Forwarded-For can be set by anyone
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token != "" {
+			bearer := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"error": "invalid admin token"})
+				return
+			}
+			c.Next()
+			return
+		}
+		host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			c.AbortWithStatusJSON(http.StatusForbidden, map[string]string{"error": "admin apis only serve localhost without an admin token"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, c := range []struct {
+		name          string
+		token         string
+		remoteAddr    string
+		authorization string
+		code          int
+	}{
+		{name: "remote forwarded for localhost", remoteAddr: "10.0.0.1:5000", code: http.StatusForbidden},
+		{name: "localhost without token", remoteAddr: "127.0.0.1:5000", code: http.StatusBadRequest},
+		{name: "ipv6 localhost", remoteAddr: "[::1]:5000", code: http.StatusBadRequest},
+		{name: "missing token", token: "secret", remoteAddr: "127.0.0.1:5000", code: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", remoteAddr: "10.0.0.1:5000", authorization: "Bearer nope", code: http.StatusUnauthorized},
+		{name: "token", token: "secret", remoteAddr: "10.0.0.1:5000", authorization: "Bearer secret", code: http.StatusBadRequest},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			r := gin.New()
+			NewHandler(&Config{R: r, AdminToken: c.token})
+			// an invalid body past the guard is a bad request
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/plugins/failures/discard", strings.NewReader("{"))
+			req.RemoteAddr = c.remoteAddr
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Forwarded-For", "127.0.0.1")
+			if c.authorization != "" {
+				req.Header.Set("Authorization", c.authorization)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, c.code, w.Code)
+		})
+	}
+}
@@ -40,3 +40,17 @@ func (h *Handler) block(c *gin.Context) {
 		c.JSON(http.StatusOK, h.BlockService.GetBlockByHashJson(p.BlockHash))
 	}
 }
+
+func (h *Handler) decodeFailures(c *gin.Context) {
+	p := new(struct {
+		Row  int `json:"row" validate:"min=1,max=100"`
+		Page int `json:"page" validate:"min=0"`
+	})
+	if err := c.MustBindWith(p, binding.JSON); err != nil {
+		return
+	}
+	failures, count := h.BlockService.DecodeFailures(p.Page, p.Row)
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"failures": failures, "count": count,
+	})
+}
@@ -20,6 +20,9 @@ func (h *Handler) extrinsics(c *gin.Context) {
 		Address string `json:"address" validate:"omitempty"`
 		Module  string `json:"module" validate:"omitempty"`
 		Call    string `json:"call" validate:"omitempty"`
+		// failed with the error, E.g module Balances and name InsufficientBalance
+		ErrorModule string `json:"error_module" validate:"omitempty,alphanum"`
+		ErrorName   string `json:"error_name" validate:"omitempty,alphanum"`
 	})
 	if err := c.MustBindWith(p, binding.JSON); err != nil {
 		return
@@ -49,6 +52,19 @@ func (h *Handler) extrinsics(c *gin.Context) {
 		query = append(query, fmt.Sprintf("is_signed = 1 and account_id = '%s'", account))
 	}
 
+	if p.ErrorModule != "" || p.ErrorName != "" {
+		errorQuery, ok := h.ExtrinsicService.ExtrinsicErrorQuery(p.ErrorModule, p.ErrorName)
+		if !ok {
+			c.JSON(http.StatusBadRequest, model.R{
+				Message:     "Error filter needs the system plugin",
+				GeneratedAt: time.Now().UTC().Unix(),
+				Data:        util.ParamsError,
+			})
+			return
+		}
+		query = append(query, "success = 0", errorQuery)
+	}
+
 	extrinsics, count := h.ExtrinsicService.GetExtrinsicList(p.Page, p.Row, "desc", query...)
 
 	c.JSON(http.StatusOK, model.R{
@@ -7,7 +7,6 @@ import (
 
 	"github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/plugins"
-	b "github.com/CoolBitX-Technology/subscan/plugins/bond/model"
 	"github.com/CoolBitX-Technology/subscan/util"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -19,7 +18,7 @@ type Handler struct {
 	ExtrinsicService model.ExtrinsicService
 	EventService     model.EventService
 	RuntimeService   model.RuntimeService
-	BondService      b.BondDelivery
+	PluginService    model.PluginService
 }
 
 type Config struct {
@@ -29,7 +28,11 @@ type Config struct {
 	ExtrinsicService model.ExtrinsicService
 	EventService     model.EventService
 	RuntimeService   model.RuntimeService
-	BondService      b.BondDelivery
+	PluginService    model.PluginService
+	// Run before every plugin api, E.g auth or rate limit
+	PluginMiddleware []gin.HandlerFunc
+	// Admin apis take "Authorization: Bearer <AdminToken>", only local clients reach them without a token
+	AdminToken string
 }
 
 func NewHandler(c *Config) {
@@ -39,7 +42,7 @@ func NewHandler(c *Config) {
 		ExtrinsicService: c.ExtrinsicService,
 		EventService:     c.EventService,
 		RuntimeService:   c.RuntimeService,
-		BondService:      c.BondService,
+		PluginService:    c.PluginService,
 	}
 
 	f := c.R.Group("/")
@@ -62,38 +65,51 @@ func NewHandler(c *Config) {
 			s.POST("check_hash", h.checkSearchHash)
 			s.POST("runtime/metadata", h.runtimeMetadata)
 			s.POST("runtime/list", h.runtimeList)
-			s.POST("account/reward_slash", h.rewardlist)
 			s.POST("plugins", h.pluginList)
-			s.POST("transfers", h.transfers) // not include utility.batch event transfer records yet
-			s.POST("bond_list", h.bondlist)
 		}
 		j := g.Group("open/account")
 		{
 			j.POST("extrinsics", h.extrinsics)
 		}
-		k := g.Group("wallet")
+		a := g.Group("admin", adminAuth(c.AdminToken))
 		{
-			k.POST("bond_list", h.bondlist)
-		}
-		pluginRouter(g)
-	}
-}
-
-func pluginRouter(g *gin.RouterGroup) {
-	for name, plugin := range plugins.RegisteredPlugins {
-		for _, r := range plugin.InitHttp() {
-			g.Group("plugin").Group(name).POST(r.Router, func(context *gin.Context) {
-				_ = r.Handle(context.Writer, context.Request)
-			})
+			a.POST("decode_failures", h.decodeFailures)
+			a.POST("type_registry/reload", h.reloadTypeRegistry)
+			a.POST("plugins/failures", h.pluginFailures)
+			a.POST("plugins/failures/retry", h.retryPluginFailure)
+			a.POST("plugins/failures/discard", h.discardPluginFailure)
 		}
+		pluginRouter(g, c.PluginMiddleware...)
 	}
 }
 
 func (h *Handler) systemHealth(c *gin.Context) {
 	status := h.CommonService.DaemonHealth(c)
 	c.JSON(http.StatusOK, gin.H{
-		"status": status,
+		"status":    status,
+		"endpoints": h.CommonService.RPCEndpointStatus(),
+	})
+}
+
+// reloadTypeRegistry reloads the type registry of the api server and broadcasts the reload to the
+// daemons, redecode retries the quarantined blocks afterwards
+func (h *Handler) reloadTypeRegistry(c *gin.Context) {
+	p := new(struct {
+		Redecode bool `json:"redecode"`
 	})
+	if err := c.MustBindWith(p, binding.JSON); err != nil {
+		return
+	}
+	report, err := h.CommonService.ReloadTypeRegistry()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err = h.CommonService.BroadcastTypeRegistryReload(p.Redecode); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
 }
 
 func (h *Handler) metadata(c *gin.Context) {
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+func (h *Handler) pluginFailures(c *gin.Context) {
+	p := new(struct {
+		Row    int    `json:"row" validate:"min=1,max=100"`
+		Page   int    `json:"page" validate:"min=0"`
+		Plugin string `json:"plugin" validate:"omitempty"`
+	})
+	if err := c.MustBindWith(p, binding.JSON); err != nil {
+		return
+	}
+	failures, count := h.PluginService.PluginFailures(p.Page, p.Row, p.Plugin)
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"failures": failures, "count": count,
+	})
+}
+
+// retryPluginFailure feeds a dead-lettered item to its plugin now, regardless of its backoff
+func (h *Handler) retryPluginFailure(c *gin.Context) {
+	p := new(struct {
+		Id uint `json:"id" validate:"min=1"`
+	})
+	if err := c.MustBindWith(p, binding.JSON); err != nil {
+		return
+	}
+	if err := h.PluginService.RetryPluginFailure(p.Id); err != nil {
+		c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"id": p.Id})
+}
+
+func (h *Handler) discardPluginFailure(c *gin.Context) {
+	p := new(struct {
+		Id uint `json:"id" validate:"min=1"`
+	})
+	if err := c.MustBindWith(p, binding.JSON); err != nil {
+		return
+	}
+	if err := h.PluginService.DiscardPluginFailure(p.Id); err != nil {
+		c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"id": p.Id})
+}
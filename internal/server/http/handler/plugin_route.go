@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins"
+	"github.com/CoolBitX-Technology/subscan/util/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/common/log"
+)
+
+// pluginRouter mounts the api of every plugin under plugin/<name>, the routes of model.PluginRoutes with their
+// method and the raw InitHttp handlers as POST, all behind middleware
+func pluginRouter(g *gin.RouterGroup, middleware ...gin.HandlerFunc) {
+	names := make([]string, 0, len(plugins.RegisteredPlugins))
+	for name := range plugins.RegisteredPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	aliases := make(map[string]string)
+	for _, name := range names {
+		plugin := plugins.RegisteredPlugins[name]
+		p := g.Group("plugin", middleware...).Group(name)
+		if routes, ok := plugin.(model.PluginRoutes); ok {
+			for _, r := range routes.Routes() {
+				method := strings.ToUpper(r.Method)
+				if method == "" {
+					method = http.MethodPost
+				}
+				if method != http.MethodGet && method != http.MethodPost {
+					log.Error("Plugin ", name, " route ", r.Path, " has unsupported method ", r.Method)
+					continue
+				}
+				handle := pluginRoute(r)
+				p.Handle(method, r.Path, handle)
+				for _, alias := range r.Aliases {
+					key := method + " " + alias
+					if owner, ok := aliases[key]; ok {
+						log.Error("Plugin ", name, " route alias ", key, " is already served by ", owner)
+						continue
+					}
+					aliases[key] = name
+					g.Handle(method, alias, append(middleware, handle)...)
+				}
+			}
+		}
+		for _, r := range plugin.InitHttp() {
+			r := r
+			p.POST(r.Router, func(context *gin.Context) {
+				_ = r.Handle(context.Writer, context.Request)
+			})
+		}
+	}
+}
+
+// pluginRoute binds and validates the params of r, calls it and renders the R envelope
+func pluginRoute(r model.PluginRoute) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := &model.PluginRequest{Request: c.Request}
+		if r.Params != nil {
+			req.Params = r.Params()
+			if err := bindPluginParams(c, req.Params); err != nil {
+				renderPluginRoute(c, nil, model.NewApiError(http.StatusBadRequest, model.QueryBindingError, "%s", err))
+				return
+			}
+		}
+		data, err := r.Handle(req)
+		renderPluginRoute(c, data, err)
+	}
+}
+
+func bindPluginParams(c *gin.Context, p interface{}) error {
+	if c.Request.Method == http.MethodGet {
+		if err := c.ShouldBindQuery(p); err != nil {
+			return err
+		}
+	} else if err := c.ShouldBindJSON(p); err != nil && err != io.EOF {
+		// a POST without body keeps the defaults
+		return err
+	}
+	if len(c.Params) > 0 {
+		if err := c.ShouldBindUri(p); err != nil {
+			return err
+		}
+	}
+	return validator.Struct(p)
+}
+
+func renderPluginRoute(c *gin.Context, data interface{}, err error) {
+	if err == nil {
+		c.JSON(http.StatusOK, model.R{
+			Message:     "Success",
+			GeneratedAt: time.Now().UTC().Unix(),
+			Code:        model.Ok,
+			Data:        data,
+		})
+		return
+	}
+	e, ok := err.(*model.ApiError)
+	if !ok {
+		e = model.NewApiError(http.StatusInternalServerError, model.DataBaseError, "%s", err)
+	}
+	c.JSON(e.Status, model.R{
+		Message:     e.Message,
+		GeneratedAt: time.Now().UTC().Unix(),
+		Code:        e.Code,
+	})
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins"
+	"github.com/gin-gonic/gin"
+	"github.com/itering/subscan-plugin/router"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+type routeParams struct {
+	Row     int    `json:"row" form:"row" validate:"min=1,max=100"`
+	Address string `json:"address" uri:"address"`
+}
+
+type routePlugin struct{}
+
+func (p *routePlugin) InitDao(model.Dao) {}
+func (p *routePlugin) ProcessExtrinsic(*model.Block, *model.Extrinsic, []model.Event) error {
+	return nil
+}
+func (p *routePlugin) ProcessEvent(*model.Block, *model.Event, decimal.Decimal) error { return nil }
+func (p *routePlugin) Migrate()                                                       {}
+func (p *routePlugin) SubscribeExtrinsic() []string                                   { return nil }
+func (p *routePlugin) SubscribeEvent() []string                                       { return nil }
+func (p *routePlugin) Version() string                                                { return "0.1" }
+
+func (p *routePlugin) InitHttp() []router.Http {
+	return []router.Http{{Router: "raw", Handle: func(w http.ResponseWriter, r *http.Request) error {
+		_, err := w.Write([]byte("raw"))
+		return err
+	}}}
+}
+
+func (p *routePlugin) Routes() []model.PluginRoute {
+	params := func() interface{} { return new(routeParams) }
+	list := func(r *model.PluginRequest) (interface{}, error) {
+		p := r.Params.(*routeParams)
+		switch p.Address {
+		case "missing":
+			return nil, model.NewApiError(http.StatusNotFound, model.AddressValidateError, "Invalid address")
+		case "broken":
+			return nil, errors.New("db down")
+		}
+		return p, nil
+	}
+	return []model.PluginRoute{
+		{Method: http.MethodGet, Path: "items/:address", Params: params, Handle: list},
+		{Path: "items", Aliases: []string{"scan/items"}, Params: params, Handle: list},
+		{Method: http.MethodGet, Path: "ping", Handle: func(r *model.PluginRequest) (interface{}, error) { return "pong", nil }},
+		{Method: http.MethodDelete, Path: "items"},
+	}
+}
+
+func TestPluginRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	plugins.RegisteredPlugins["route"] = &routePlugin{}
+	defer delete(plugins.RegisteredPlugins, "route")
+
+	r := gin.New()
+	NewHandler(&Config{R: r, PluginMiddleware: []gin.HandlerFunc{func(c *gin.Context) {
+		if c.GetHeader("X-Token") != "secret" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+		}
+	}}})
+
+	for _, c := range []struct {
+		name, method, path, body string
+		status, code             int
+		data                     string
+	}{
+		{name: "get path param", method: "GET", path: "/api/plugin/route/items/alice?row=10", status: 200, code: model.Ok, data: `{"row":10,"address":"alice"}`},
+		{name: "get invalid query", method: "GET", path: "/api/plugin/route/items/alice?row=0", status: 400, code: model.QueryBindingError},
+		{name: "get bad query type", method: "GET", path: "/api/plugin/route/items/alice?row=x", status: 400, code: model.QueryBindingError},
+		{name: "post body", method: "POST", path: "/api/plugin/route/items", body: `{"row":5,"address":"bob"}`, status: 200, code: model.Ok, data: `{"row":5,"address":"bob"}`},
+		{name: "post alias", method: "POST", path: "/api/scan/items", body: `{"row":5,"address":"bob"}`, status: 200, code: model.Ok, data: `{"row":5,"address":"bob"}`},
+		{name: "post invalid body", method: "POST", path: "/api/plugin/route/items", body: `{"row":500}`, status: 400, code: model.QueryBindingError},
+		{name: "post malformed body", method: "POST", path: "/api/plugin/route/items", body: `{`, status: 400, code: model.QueryBindingError},
+		{name: "api error", method: "GET", path: "/api/plugin/route/items/missing?row=1", status: 404, code: model.AddressValidateError},
+		{name: "other error", method: "GET", path: "/api/plugin/route/items/broken?row=1", status: 500, code: model.DataBaseError},
+		{name: "no params", method: "GET", path: "/api/plugin/route/ping", status: 200, code: model.Ok, data: `"pong"`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Token", "secret")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, c.status, w.Code)
+			var res struct {
+				Code int             `json:"code"`
+				Data json.RawMessage `json:"data"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			assert.Equal(t, c.code, res.Code)
+			if c.data != "" {
+				assert.JSONEq(t, c.data, string(res.Data))
+			}
+		})
+	}
+
+	// raw InitHttp handlers stay POST, the middleware guards every plugin route
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/plugin/route/raw", nil)
+	req.Header.Set("X-Token", "secret")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "raw", w.Body.String())
+	for _, path := range []string{"/api/plugin/route/raw", "/api/scan/items"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", path, nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/plugin/route/items", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
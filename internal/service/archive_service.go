@@ -0,0 +1,361 @@
+package service
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/itering/substrate-api-rpc/metadata"
+	"github.com/prometheus/common/log"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	archiveSegmentExt = ".jsonl.gz"
+	// blocks looked up in one query while exporting or importing
+	archiveQueryBatch = 1000
+)
+
+type archiveService struct {
+	RedisRepository model.RedisRepository
+	SqlRepository   model.SqlRepository
+	CommonService   model.CommonService
+	RuntimeService  model.RuntimeService
+	BlockService    model.BlockService
+}
+
+type ArchiveConfig struct {
+	RedisRepository model.RedisRepository
+	SqlRepository   model.SqlRepository
+}
+
+func NewArchiveService(c *ArchiveConfig, cs model.CommonService, r model.RuntimeService, b model.BlockService) model.ArchiveService {
+	return &archiveService{
+		RedisRepository: c.RedisRepository,
+		SqlRepository:   c.SqlRepository,
+		CommonService:   cs,
+		RuntimeService:  r,
+		BlockService:    b,
+	}
+}
+
+// archiveSegment is a segment file holding blocks [From, To]
+type archiveSegment struct {
+	Path string
+	From int
+	To   int
+}
+
+// Export writes finalized blocks [from, to] into gzip JSONL segments of segment blocks each,
+// together with the metadata of every known spec version. Only the database is read
+func (a *archiveService) Export(dir string, from, to, segment int) error {
+	if from < 0 || to < from {
+		return fmt.Errorf("invalid archive range %d - %d", from, to)
+	}
+	if segment < 1 {
+		return fmt.Errorf("invalid segment size %d", segment)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := a.exportRuntime(dir); err != nil {
+		return err
+	}
+	for start := from; start <= to; start += segment {
+		end := start + segment - 1
+		if end > to {
+			end = to
+		}
+		path := filepath.Join(dir, archiveSegmentName(start, end))
+		if err := a.exportSegment(path, start, end); err != nil {
+			return err
+		}
+		log.Info("Archive export ", path, " ", end-start+1, " blocks")
+	}
+	return nil
+}
+
+func (a *archiveService) exportRuntime(dir string) error {
+	var runtimes []interface{}
+	for _, r := range a.SqlRepository.RuntimeVersionRawList() {
+		if r.RawData == "" {
+			continue
+		}
+		runtimes = append(runtimes, model.ArchivedRuntime{Name: r.Name, SpecVersion: r.SpecVersion, RawData: r.RawData})
+	}
+	return writeArchive(filepath.Join(dir, archiveRuntimeName()), func(emit func(interface{}) error) error {
+		for _, r := range runtimes {
+			if err := emit(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (a *archiveService) exportSegment(path string, from, to int) error {
+	return writeArchive(path, func(emit func(interface{}) error) error {
+		for num := from; num <= to; num += archiveQueryBatch {
+			var nums []int
+			for i := num; i <= to && i < num+archiveQueryBatch; i++ {
+				nums = append(nums, i)
+			}
+			blocks := a.SqlRepository.BlocksReverseByNum(nums)
+			for _, i := range nums {
+				block, ok := blocks[i]
+				if !ok || !block.Finalized || block.CodecError {
+					return fmt.Errorf("block %d is missing or not finalized, repair or sync it before exporting", i)
+				}
+				fees := make(map[string]decimal.Decimal)
+				for _, extrinsic := range a.SqlRepository.GetRawExtrinsicsByBlockNum(i) {
+					if extrinsic.ExtrinsicHash != "" {
+						fees[extrinsic.ExtrinsicIndex] = extrinsic.Fee
+					}
+				}
+				if err := emit(block.AsArchived(fees)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Import feeds the archived blocks within [from, to] through block creation without a
+// websocket connection, to 0 imports every segment. Blocks already finalized are skipped, the fill cursors
+// follow the contiguous imported prefix
+func (a *archiveService) Import(dir string, from, to, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if err := a.importRuntime(dir); err != nil {
+		return err
+	}
+
+	segments, err := archiveSegments(dir, from, to)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return fmt.Errorf("no archive segment found in %s", dir)
+	}
+
+	start := segments[0].From
+	if from > start {
+		start = from
+	}
+	var (
+		progress = newFillCheckpoint(a.RedisRepository, start)
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		count    int
+		failed   []int
+		blocks   = make(chan *model.ArchivedBlock, workers*2)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range blocks {
+				err := a.BlockService.ImportChainBlock(b)
+				mu.Lock()
+				if err != nil {
+					log.Error("Import block ", b.BlockNum, " error ", err)
+					failed = append(failed, b.BlockNum)
+				} else {
+					count++
+				}
+				mu.Unlock()
+				if err == nil {
+					progress.done(b.BlockNum)
+				}
+			}
+		}()
+	}
+
+	for _, segment := range segments {
+		log.Info("Archive import ", segment.Path)
+		if err = a.importSegment(segment.Path, from, to, blocks, progress); err != nil {
+			break
+		}
+	}
+	close(blocks)
+	wg.Wait()
+
+	log.Info("Archive import finished, ", count, " blocks imported")
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		sort.Ints(failed)
+		return fmt.Errorf("%d blocks failed, first %d, re-run the import to retry", len(failed), failed[0])
+	}
+	return nil
+}
+
+// importRuntime stores the archived metadata of spec versions the database does not have yet, the
+// latest one is registered without asking the node
+func (a *archiveService) importRuntime(dir string) error {
+	var latest model.ArchivedRuntime
+	err := readArchive(filepath.Join(dir, archiveRuntimeName()), func(line []byte) error {
+		var r model.ArchivedRuntime
+		if err := json.Unmarshal(line, &r); err != nil {
+			return err
+		}
+		if r.SpecVersion >= latest.SpecVersion && r.RawData != "" {
+			latest = r
+		}
+		if raw := a.SqlRepository.RuntimeVersionRaw(r.SpecVersion); raw != nil && raw.Raw != "" {
+			return nil
+		}
+		a.SqlRepository.CreateRuntimeVersion(r.Name, r.SpecVersion)
+		a.RuntimeService.SetRuntimeData(r.SpecVersion, metadata.RegNewMetadataType(r.SpecVersion, r.RawData), r.RawData)
+		log.Info("Archive import runtime ", r.SpecVersion)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if latest.RawData == "" {
+		return fmt.Errorf("no runtime found in %s", dir)
+	}
+	return a.CommonService.InitRuntime(latest.SpecVersion, latest.RawData)
+}
+
+// importSegment sends the blocks of a segment not finalized yet to the workers, the skipped ones count as done
+func (a *archiveService) importSegment(path string, from, to int, blocks chan<- *model.ArchivedBlock, progress *fillCheckpoint) error {
+	var pending []*model.ArchivedBlock
+	flush := func() {
+		var nums []int
+		for _, b := range pending {
+			nums = append(nums, b.BlockNum)
+		}
+		stored := a.SqlRepository.BlocksReverseByNum(nums)
+		for _, b := range pending {
+			if s, ok := stored[b.BlockNum]; ok && s.Finalized && !s.CodecError {
+				progress.done(b.BlockNum)
+				continue
+			}
+			blocks <- b
+		}
+		pending = pending[:0]
+	}
+	err := readArchive(path, func(line []byte) error {
+		var b model.ArchivedBlock
+		if err := json.Unmarshal(line, &b); err != nil {
+			return fmt.Errorf("%s decode error: %v", path, err)
+		}
+		if b.BlockNum < from || (to > 0 && b.BlockNum > to) {
+			return nil
+		}
+		if pending = append(pending, &b); len(pending) >= archiveQueryBatch {
+			flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		flush()
+	}
+	return nil
+}
+
+func archiveRuntimeName() string {
+	return fmt.Sprintf("%s-runtime%s", util.NetworkNode, archiveSegmentExt)
+}
+
+func archiveSegmentName(from, to int) string {
+	return fmt.Sprintf("%s-%010d-%010d%s", util.NetworkNode, from, to, archiveSegmentExt)
+}
+
+// archiveSegments lists the segments of the network overlapping [from, to] in block order
+func archiveSegments(dir string, from, to int) ([]archiveSegment, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []archiveSegment
+	prefix := util.NetworkNode + "-"
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, archiveSegmentExt) {
+			continue
+		}
+		var s archiveSegment
+		if n, _ := fmt.Sscanf(strings.TrimPrefix(name, prefix), "%d-%d"+archiveSegmentExt, &s.From, &s.To); n != 2 {
+			continue
+		}
+		if s.To < from || (to > 0 && s.From > to) {
+			continue
+		}
+		s.Path = filepath.Join(dir, name)
+		segments = append(segments, s)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].From < segments[j].From })
+	return segments, nil
+}
+
+// writeArchive writes the records of fill as gzip JSONL, the file only appears once it is complete
+func writeArchive(path string, fill func(emit func(interface{}) error) error) (err error) {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+	zw := gzip.NewWriter(f)
+	encoder := json.NewEncoder(zw)
+	if err = fill(encoder.Encode); err != nil {
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// readArchive calls fn with every line of a gzip JSONL file
+func readArchive(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	defer zr.Close()
+
+	scanner := bufio.NewScanner(zr)
+	// a block with many extrinsics easily exceeds the default line limit
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err = fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/CoolBitX-Technology/subscan/model"
@@ -11,8 +13,8 @@ import (
 	"github.com/itering/substrate-api-rpc"
 	"github.com/itering/substrate-api-rpc/rpc"
 	"github.com/itering/substrate-api-rpc/storage"
-	"github.com/itering/substrate-api-rpc/websocket"
 	"github.com/prometheus/common/log"
+	"github.com/shopspring/decimal"
 )
 
 type blockService struct {
@@ -22,6 +24,7 @@ type blockService struct {
 	ExtrinsicService model.ExtrinsicService
 	EventService     model.EventService
 	CommonService    model.CommonService
+	PluginService    model.PluginService
 }
 
 type BlockConfig struct {
@@ -29,7 +32,7 @@ type BlockConfig struct {
 	RedisRepository model.RedisRepository
 }
 
-func NewBlockService(c *BlockConfig, r model.RuntimeService, e model.ExtrinsicService, s model.EventService, cs model.CommonService) model.BlockService {
+func NewBlockService(c *BlockConfig, r model.RuntimeService, e model.ExtrinsicService, s model.EventService, cs model.CommonService, p model.PluginService) model.BlockService {
 	return &blockService{
 		SqlRepository:    c.SqlRepository,
 		RedisRepository:  c.RedisRepository,
@@ -37,6 +40,7 @@ func NewBlockService(c *BlockConfig, r model.RuntimeService, e model.ExtrinsicSe
 		ExtrinsicService: e,
 		EventService:     s,
 		CommonService:    cs,
+		PluginService:    p,
 	}
 }
 
@@ -115,53 +119,27 @@ func (b *blockService) BlockAsSampleJson(block *model.ChainBlock) *model.SampleB
 	return &m
 }
 
-func (b *blockService) CreateChainBlock(conn websocket.WsConn, hash string, block *rpc.Block, event string, spec int, finalized bool) (err error) {
-	var (
-		decodeExtrinsics []map[string]interface{}
-		decodeEvent      interface{}
-		logs             []storage.DecoderLog
-		validator        string
-	)
-	c := context.TODO()
-	blockNum := util.StringToInt(util.HexToNumStr(block.Header.Number))
-	metadataInstant, err := b.RuntimeService.GetMetadataInstant(spec, hash)
-	if err != nil {
-		log.Error(err)
-	}
-
-	// Extrinsic
-
-	// log.Info("block.Extrinsics: ", block.Extrinsics)
-	// log.Info("metadataInstant: ", metadataInstant)
-	decodeExtrinsics, err = substrate.DecodeExtrinsic(block.Extrinsics, metadataInstant, spec)
-	if err != nil {
-		log.Error(err)
-		return err
-	}
-
-	// event
-	if err == nil {
-		decodeEvent, err = substrate.DecodeEvent(event, metadataInstant, spec)
-		if err != nil {
-			log.Error(err)
-		}
-	}
-
-	// log
-	if err == nil {
-		logs, err = substrate.DecodeLogDigest(block.Header.Digest.Logs)
-		if err != nil {
-			log.Error(err)
-		}
-	}
+func (b *blockService) CreateChainBlock(hash string, block *rpc.Block, event string, spec int, finalized bool) (err error) {
+	return b.createChainBlock(hash, block, event, spec, finalized, nil)
+}
 
-	txn := b.SqlRepository.DbBegin()
-	defer b.SqlRepository.DbRollback(txn)
+// ImportChainBlock creates a finalized block from an archive, the validator and fees come
+// from the archive so no node is queried
+func (b *blockService) ImportChainBlock(archived *model.ArchivedBlock) error {
+	return b.createChainBlock(archived.Hash, archived.AsRpcBlock(), archived.Event, archived.SpecVersion, true,
+		&blockHint{validator: archived.Validator, fees: archived.Fees})
+}
 
-	var e []model.ChainEvent
-	util.UnmarshalAny(&e, decodeEvent)
+// blockHint is node-derived block data that is already known
+type blockHint struct {
+	validator string
+	fees      map[string]decimal.Decimal
+}
 
-	eventMap := b.ExtrinsicService.CheckoutExtrinsicEvents(e, blockNum)
+func (b *blockService) createChainBlock(hash string, block *rpc.Block, event string, spec int, finalized bool, hint *blockHint) (err error) {
+	var validator string
+	c := context.TODO()
+	blockNum := util.StringToInt(util.HexToNumStr(block.Header.Number))
 
 	cb := model.ChainBlock{
 		Hash:           hash,
@@ -175,8 +153,36 @@ func (b *blockService) CreateChainBlock(conn websocket.WsConn, hash string, bloc
 		SpecVersion:    spec,
 		Finalized:      finalized,
 	}
+	if hint != nil {
+		cb.Validator = hint.validator
+	}
+
+	decoded, err := b.decodeBlock(blockNum, hash, spec, block.Extrinsics, event, block.Header.Digest.Logs)
+	var decodeErr *model.DecodeError
+	if errors.As(err, &decodeErr) {
+		return b.quarantineBlock(&cb, decodeErr, false)
+	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	txn := b.SqlRepository.DbBegin()
+	defer b.SqlRepository.DbRollback(txn)
+
+	eventMap := b.ExtrinsicService.CheckoutExtrinsicEvents(decoded.events, blockNum)
+
+	var (
+		knownFees     map[string]decimal.Decimal
+		validatorList []string
+	)
+	if hint != nil {
+		knownFees = hint.fees
+	} else {
+		validatorList = b.CommonService.ValidatorsList(hash)
+	}
 
-	extrinsicsCount, _, extrinsicHash, extrinsicFee, err := b.ExtrinsicService.CreateExtrinsic(c, txn, &cb, block.Extrinsics, decodeExtrinsics, eventMap)
+	extrinsicsCount, _, extrinsicHash, extrinsicFee, err := b.ExtrinsicService.CreateExtrinsic(c, txn, &cb, block.Extrinsics, decoded.decodeExtrinsics, eventMap, knownFees)
 
 	// log.Info("")
 	// log.Info("=====================================")
@@ -189,89 +195,66 @@ func (b *blockService) CreateChainBlock(conn websocket.WsConn, hash string, bloc
 	if err != nil {
 		return err
 	}
-	eventCount, err := b.EventService.AddEvent(txn, &cb, e, extrinsicHash, extrinsicFee)
+	eventCount, err := b.EventService.AddEvent(txn, &cb, decoded.events, extrinsicHash, extrinsicFee)
 	if err != nil {
 		return err
 	}
-	if validator, err = b.CommonService.EmitLog(txn, blockNum, logs, finalized, b.CommonService.ValidatorsList(conn, hash)); err != nil {
+	if validator, err = b.CommonService.EmitLog(txn, blockNum, decoded.logs, finalized, validatorList); err != nil {
 		return err
 	}
+	if hint != nil {
+		validator = hint.validator
+	}
 
 	cb.Validator = validator
 	cb.CodecError = validator == "" && blockNum != 0
 	cb.ExtrinsicsCount = extrinsicsCount
 	cb.EventCount = eventCount
 
-	if err = b.SqlRepository.CreateBlock(txn, &cb); err == nil {
-		log.Info("CreateChainBlock ", blockNum, " @", time.Now())
-		b.SqlRepository.DbCommit(txn)
+	if err = b.SqlRepository.CreateBlock(txn, &cb); err != nil {
+		return err
 	}
-	return err
+	if err = b.saveSyncCursor(txn, blockNum, finalized); err != nil {
+		return err
+	}
+	log.Info("CreateChainBlock ", blockNum, " @", time.Now())
+	b.SqlRepository.DbCommit(txn)
+	return nil
 }
 
-func (b *blockService) UpdateBlockData(conn websocket.WsConn, block *model.ChainBlock, finalized bool) (err error) {
+func (b *blockService) UpdateBlockData(block *model.ChainBlock, finalized bool) (err error) {
 	c := context.TODO()
 
-	var (
-		decodeEvent      interface{}
-		encodeExtrinsics []string
-		decodeExtrinsics []map[string]interface{}
-	)
-
-	_ = json.Unmarshal([]byte(block.Extrinsics), &encodeExtrinsics)
-
-	spec := block.SpecVersion
-	metadataInstant, err := b.RuntimeService.GetMetadataInstant(spec, block.Hash)
-	if err != nil {
-		log.Error(err)
-		return
-	}
-
-	// Event
-	decodeEvent, err = substrate.DecodeEvent(block.Event, metadataInstant, spec)
-	if err != nil {
-		log.Info("ERR: Decode Event get error ", err, " @block: ", block.BlockNum)
-		return
+	decoded, err := b.decodeStoredBlock(block)
+	var decodeErr *model.DecodeError
+	if errors.As(err, &decodeErr) {
+		block.Finalized = finalized
+		return b.quarantineBlock(block, decodeErr, true)
 	}
-
-	// Extrinsic
-	decodeExtrinsics, err = substrate.DecodeExtrinsic(encodeExtrinsics, metadataInstant, spec)
 	if err != nil {
-		log.Info("ERR: Decode Extrinsic get error ", err)
+		log.Info("ERR: ", err)
 		return
 	}
 
-	// Log
-	var rawList []string
-	_ = json.Unmarshal([]byte(block.Logs), &rawList)
-	logs, err := substrate.DecodeLogDigest(rawList)
-	if err != nil {
-		log.Info("ERR: Decode Logs get error ", err)
-		return
-	}
-
-	var e []model.ChainEvent
-	util.UnmarshalAny(&e, decodeEvent)
-
-	for _, event := range e {
+	for _, event := range decoded.events {
 		log.Info("event: ", event)
 	}
-	eventMap := b.ExtrinsicService.CheckoutExtrinsicEvents(e, block.BlockNum)
+	eventMap := b.ExtrinsicService.CheckoutExtrinsicEvents(decoded.events, block.BlockNum)
 
 	txn := b.SqlRepository.DbBegin()
 	defer b.SqlRepository.DbRollback(txn)
 
-	extrinsicsCount, blockTimestamp, extrinsicHash, extrinsicFee, err := b.ExtrinsicService.CreateExtrinsic(c, txn, block, encodeExtrinsics, decodeExtrinsics, eventMap)
+	extrinsicsCount, blockTimestamp, extrinsicHash, extrinsicFee, err := b.ExtrinsicService.CreateExtrinsic(c, txn, block, decoded.extrinsics, decoded.decodeExtrinsics, eventMap, nil)
 	if err != nil {
 		return err
 	}
 
-	eventCount, err := b.EventService.AddEvent(txn, block, e, extrinsicHash, extrinsicFee)
+	eventCount, err := b.EventService.AddEvent(txn, block, decoded.events, extrinsicHash, extrinsicFee)
 	if err != nil {
 		return err
 	}
 
-	validator, err := b.CommonService.EmitLog(txn, block.BlockNum, logs, finalized, b.CommonService.ValidatorsList(conn, block.Hash))
+	validator, err := b.CommonService.EmitLog(txn, block.BlockNum, decoded.logs, finalized, b.CommonService.ValidatorsList(block.Hash))
 	if err != nil {
 		return err
 	}
@@ -286,11 +269,195 @@ func (b *blockService) UpdateBlockData(conn websocket.WsConn, block *model.Chain
 		log.Info("UpdateChainBlock ", block.BlockNum, " @", time.Now())
 		return
 	}
+	if err = b.saveSyncCursor(txn, block.BlockNum, finalized); err != nil {
+		return
+	}
+	if err = b.SqlRepository.DeleteDecodeFailure(txn, block.BlockNum); err != nil {
+		return
+	}
 
 	b.SqlRepository.DbCommit(txn)
 	return
 }
 
+// RedecodeBlock replaces the extrinsics, events and logs of a stored block with a fresh decode of
+// its raw data in one transaction. Stored fees and validator are reused, the node is only asked
+// for the ones the database does not have
+func (b *blockService) RedecodeBlock(block *model.ChainBlock) error {
+	c := context.TODO()
+
+	decoded, err := b.decodeStoredBlock(block)
+	var decodeErr *model.DecodeError
+	if errors.As(err, &decodeErr) {
+		if saveErr := b.SqlRepository.SaveDecodeFailure(nil, decodeErr.AsFailure(block.Hash)); saveErr != nil {
+			log.Error("Save decode failure error ", saveErr)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	knownFees := make(map[string]decimal.Decimal)
+	for _, extrinsic := range b.SqlRepository.GetRawExtrinsicsByBlockNum(block.BlockNum) {
+		if extrinsic.ExtrinsicHash != "" {
+			knownFees[extrinsic.ExtrinsicIndex] = extrinsic.Fee
+		}
+	}
+	var validatorList []string
+	if block.Validator == "" && block.BlockNum != 0 {
+		validatorList = b.CommonService.ValidatorsList(block.Hash)
+	}
+	eventMap := b.ExtrinsicService.CheckoutExtrinsicEvents(decoded.events, block.BlockNum)
+
+	txn := b.SqlRepository.DbBegin()
+	defer b.SqlRepository.DbRollback(txn)
+
+	droppedExtrinsics, droppedSigned, droppedEvents, err := b.SqlRepository.DropBlockDerivedData(txn, block.BlockNum)
+	if err != nil {
+		return err
+	}
+	extrinsicsCount, blockTimestamp, extrinsicHash, extrinsicFee, err := b.ExtrinsicService.CreateExtrinsic(c, txn, block, decoded.extrinsics, decoded.decodeExtrinsics, eventMap, knownFees)
+	if err != nil {
+		return err
+	}
+	eventCount, err := b.EventService.AddEvent(txn, block, decoded.events, extrinsicHash, extrinsicFee)
+	if err != nil {
+		return err
+	}
+	validator, err := b.CommonService.EmitLog(txn, block.BlockNum, decoded.logs, block.Finalized, validatorList)
+	if err != nil {
+		return err
+	}
+	if block.Validator != "" {
+		validator = block.Validator
+	}
+	codecError := validator == "" && block.BlockNum != 0
+	if err = b.SqlRepository.UpdateEventAndExtrinsic(txn, block, eventCount, extrinsicsCount, blockTimestamp, validator, codecError, block.Finalized); err != nil {
+		return err
+	}
+	if err = b.SqlRepository.DeleteDecodeFailure(txn, block.BlockNum); err != nil {
+		return err
+	}
+	b.SqlRepository.DbCommit(txn)
+
+	// the re-inserted rows were counted again while creating them
+	_ = b.RedisRepository.IncrMetadata(c, "count_extrinsic", -droppedExtrinsics)
+	_ = b.RedisRepository.IncrMetadata(c, "count_signed_extrinsic", -droppedSigned)
+	_ = b.RedisRepository.IncrMetadata(c, "count_event", -droppedEvents)
+	if codecError {
+		return fmt.Errorf("block %d validator not found", block.BlockNum)
+	}
+	return nil
+}
+
+// decodedBlock is the raw data of a stored block decoded with the metadata of its spec
+type decodedBlock struct {
+	extrinsics       []string
+	decodeExtrinsics []map[string]interface{}
+	events           []model.ChainEvent
+	logs             []storage.DecoderLog
+}
+
+func (b *blockService) decodeStoredBlock(block *model.ChainBlock) (*decodedBlock, error) {
+	var extrinsics, logs []string
+	_ = json.Unmarshal([]byte(block.Extrinsics), &extrinsics)
+	_ = json.Unmarshal([]byte(block.Logs), &logs)
+	return b.decodeBlock(block.BlockNum, block.Hash, block.SpecVersion, extrinsics, block.Event, logs)
+}
+
+// decodeBlock decodes the raw block with the metadata of spec, a stage failing to decode is a *model.DecodeError
+func (b *blockService) decodeBlock(blockNum int, hash string, spec int, extrinsics []string, event string, logs []string) (*decodedBlock, error) {
+	metadataInstant, err := b.RuntimeService.GetMetadataInstant(spec, hash)
+	if err != nil {
+		return nil, err
+	}
+	typeRegistryLock.RLock()
+	defer typeRegistryLock.RUnlock()
+
+	d := decodedBlock{extrinsics: extrinsics}
+	if d.decodeExtrinsics, err = substrate.DecodeExtrinsic(extrinsics, metadataInstant, spec); err != nil {
+		return nil, &model.DecodeError{BlockNum: blockNum, Spec: spec, Stage: model.DecodeStageExtrinsic, Payload: util.ToString(extrinsics), Err: err}
+	}
+	decodeEvent, err := substrate.DecodeEvent(event, metadataInstant, spec)
+	if err != nil {
+		return nil, &model.DecodeError{BlockNum: blockNum, Spec: spec, Stage: model.DecodeStageEvent, Payload: event, Err: err}
+	}
+	util.UnmarshalAny(&d.events, decodeEvent)
+	if d.logs, err = substrate.DecodeLogDigest(logs); err != nil {
+		return nil, &model.DecodeError{BlockNum: blockNum, Spec: spec, Stage: model.DecodeStageLog, Payload: util.ToString(logs), Err: err}
+	}
+	return &d, nil
+}
+
+// quarantineBlock keeps a block that can not be decoded with codec_error set and without derived
+// rows, the failure is recorded in decode_failures until redecode succeeds
+func (b *blockService) quarantineBlock(block *model.ChainBlock, decodeErr *model.DecodeError, stored bool) (err error) {
+	log.Warn("Quarantine block ", block.BlockNum, ": ", decodeErr)
+	txn := b.SqlRepository.DbBegin()
+	defer b.SqlRepository.DbRollback(txn)
+
+	block.CodecError = true
+	if stored {
+		err = b.SqlRepository.UpdateEventAndExtrinsic(txn, block, block.EventCount, block.ExtrinsicsCount, block.BlockTimestamp, block.Validator, true, block.Finalized)
+	} else {
+		err = b.SqlRepository.CreateBlock(txn, block)
+	}
+	if err != nil {
+		return err
+	}
+	if err = b.SqlRepository.SaveDecodeFailure(txn, decodeErr.AsFailure(block.Hash)); err != nil {
+		return err
+	}
+	if err = b.saveSyncCursor(txn, block.BlockNum, block.Finalized); err != nil {
+		return err
+	}
+	b.SqlRepository.DbCommit(txn)
+	return nil
+}
+
+func (b *blockService) DecodeFailures(page, row int) ([]model.DecodeFailure, int) {
+	return b.SqlRepository.GetDecodeFailureList(page, row)
+}
+
+// saveSyncCursor advances the substrate cursor in the block transaction
+func (b *blockService) saveSyncCursor(txn *model.GormDB, blockNum int, finalized bool) error {
+	cursor := model.SyncCursor{Worker: model.SyncCursorSubstrate, BlockNum: blockNum}
+	if finalized {
+		cursor.FinalizedBlockNum = blockNum
+	}
+	return b.SqlRepository.SaveSyncCursor(txn, &cursor)
+}
+
+// RollbackBlock drops an orphaned, not yet finalized block together with its
+// extrinsics, events and logs so the canonical block can be indexed in its place.
+// The plugins are only fed finalized blocks, they never saw it
+func (b *blockService) RollbackBlock(blockNum int) error {
+	block := b.SqlRepository.GetBlockByNum(blockNum)
+	if block == nil {
+		return nil
+	}
+	if block.Finalized {
+		return fmt.Errorf("block %d is finalized, refuse to rollback", blockNum)
+	}
+	txn := b.SqlRepository.DbBegin()
+	defer b.SqlRepository.DbRollback(txn)
+	if err := b.SqlRepository.DropExtrinsicNotFinalizedData(txn, blockNum); err != nil {
+		return err
+	}
+	if err := b.SqlRepository.DropEventNotFinalizedData(txn, blockNum, true); err != nil {
+		return err
+	}
+	if err := b.SqlRepository.DropLogsNotFinalizedData(txn, blockNum, true); err != nil {
+		return err
+	}
+	if err := b.SqlRepository.DropBlockNotFinalizedData(txn, blockNum); err != nil {
+		return err
+	}
+	b.SqlRepository.DbCommit(txn)
+	log.Warn("RollbackBlock ", blockNum, " hash: ", block.Hash)
+	return nil
+}
+
 func (b *blockService) GetBlockByHashJson(hash string) *model.ChainBlockJson {
 	c := context.TODO()
 	blockNum, _ := b.RedisRepository.GetBestBlockNum(c)
@@ -2,12 +2,14 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
 	"sync"
 
+	"github.com/CoolBitX-Technology/subscan/internal/rpcpool"
 	"github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/util"
 	"github.com/golang/protobuf/ptypes/empty"
@@ -19,17 +21,23 @@ import (
 	"github.com/prometheus/common/log"
 )
 
-var onceToken sync.Once
+var (
+	onceToken sync.Once
+	// typeRegistryLock keeps decoding off the custom type registry while it is reloaded
+	typeRegistryLock sync.RWMutex
+)
 
 type commonService struct {
 	RedisRepository model.RedisRepository
 	SqlRepository   model.SqlRepository
 	RunTimeService  model.RuntimeService
+	ChainSource     model.ChainSource
 }
 
 type CommonConfig struct {
 	RedisRepository model.RedisRepository
 	SqlRepository   model.SqlRepository
+	ChainSource     model.ChainSource
 }
 
 func NewCommonService(c *CommonConfig, r model.RuntimeService) model.CommonService {
@@ -37,6 +45,7 @@ func NewCommonService(c *CommonConfig, r model.RuntimeService) model.CommonServi
 		RedisRepository: c.RedisRepository,
 		SqlRepository:   c.SqlRepository,
 		RunTimeService:  r,
+		ChainSource:     c.ChainSource,
 	}
 }
 
@@ -58,9 +67,8 @@ func (s *commonService) InitSubRuntimeLatest() {
 	// reg network custom type
 	defer func() {
 		go s.unknownToken()
-		if c, err := s.ReadTypeRegistry(); err == nil {
-			substrate.RegCustomTypes(c)
-			if unknown := metadata.Decoder.CheckRegistry(); len(unknown) > 0 {
+		if err := s.regCustomTypes(); err == nil {
+			if unknown := checkTypeRegistry(); len(unknown) > 0 {
 				log.Warn("Found unknown type ", strings.Join(unknown, ", "))
 			}
 		} else {
@@ -84,6 +92,109 @@ func (s *commonService) InitSubRuntimeLatest() {
 	panic("Can not find chain metadata, please check network")
 }
 
+// InitRuntime registers raw as the latest metadata and the custom types of the registry file without
+// asking the node, E.g for the runtimes of an archive
+func (s *commonService) InitRuntime(spec int, raw string) error {
+	metadata.Latest(&metadata.RuntimeRaw{Spec: spec, Raw: raw})
+	if err := s.regCustomTypes(); err != nil {
+		return err
+	}
+	if unknown := checkTypeRegistry(); len(unknown) > 0 {
+		log.Warn("Found unknown type ", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func (s *commonService) regCustomTypes() (err error) {
+	c, err := s.ReadTypeRegistry()
+	if err != nil {
+		return err
+	}
+	typeRegistryLock.Lock()
+	defer typeRegistryLock.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid type registry: %v", r)
+		}
+	}()
+	substrate.RegCustomTypes(c)
+	return nil
+}
+
+// checkTypeRegistry lists the types of the latest metadata without a decoder
+func checkTypeRegistry() []string {
+	if metadata.Decoder == nil {
+		return nil
+	}
+	typeRegistryLock.RLock()
+	defer typeRegistryLock.RUnlock()
+	return metadata.Decoder.CheckRegistry()
+}
+
+// ReloadTypeRegistry registers the custom types of the registry file again without touching the
+// subscriptions, blocks decoding meanwhile wait for it
+func (s *commonService) ReloadTypeRegistry() (*model.TypeRegistryReport, error) {
+	before := checkTypeRegistry()
+	if err := s.regCustomTypes(); err != nil {
+		log.Error("Reload type registry error ", err)
+		return nil, err
+	}
+	report := model.TypeRegistryReport{Unknown: checkTypeRegistry()}
+	for _, t := range before {
+		if !util.StringInSlice(t, report.Unknown) {
+			report.Resolved = append(report.Resolved, t)
+		}
+	}
+	log.Info("Type registry reloaded, resolved: ", strings.Join(report.Resolved, ", "), " unknown: ", strings.Join(report.Unknown, ", "))
+	return &report, nil
+}
+
+// BroadcastTypeRegistryReload asks the running daemons to reload the type registry, with redecode the
+// substrate daemon retries the quarantined blocks afterwards
+func (s *commonService) BroadcastTypeRegistryReload(redecode bool) error {
+	message := model.TypeRegistryReload
+	if redecode {
+		message = model.TypeRegistryReloadRedecode
+	}
+	return s.RedisRepository.PublishTypeRegistryReload(context.TODO(), message)
+}
+
+// WatchTypeRegistryReload reloads the type registry on every broadcast until c is done, redecode
+// runs after a reload that asked for it
+func (s *commonService) WatchTypeRegistryReload(c context.Context, redecode func()) {
+	for message := range s.RedisRepository.SubscribeTypeRegistryReload(c) {
+		if _, err := s.ReloadTypeRegistry(); err != nil {
+			continue
+		}
+		if message == model.TypeRegistryReloadRedecode && redecode != nil {
+			redecode()
+		}
+	}
+}
+
+// RebuildSyncCursorCache refills the redis fill cursors from the sync_cursor table,
+// on the first start after upgrade the table is seeded from redis instead
+func (s *commonService) RebuildSyncCursorCache() {
+	c := context.TODO()
+	if cursor := s.SqlRepository.GetSyncCursor(model.SyncCursorSubstrate, ""); cursor != nil {
+		if err := s.RedisRepository.ResetFillBlockNum(c, cursor.BlockNum, cursor.FinalizedBlockNum); err != nil {
+			log.Error("Rebuild substrate cursor cache error ", err)
+		}
+	} else {
+		best, _ := s.RedisRepository.GetFillBestBlockNum(c)
+		final, _ := s.RedisRepository.GetFillFinalizedBlockNum(c)
+		if best > 0 || final > 0 {
+			_ = s.SqlRepository.SaveSyncCursor(nil, &model.SyncCursor{Worker: model.SyncCursorSubstrate, BlockNum: best, FinalizedBlockNum: final})
+		}
+	}
+
+	if cursor := s.SqlRepository.GetSyncCursor(model.SyncCursorPlugins, ""); cursor != nil {
+		_ = s.RedisRepository.SetMetadata(c, map[string]interface{}{"plugins:finalized_blockNum": cursor.FinalizedBlockNum})
+	} else if num, _ := s.RedisRepository.GetFinalizedBlockNumForPlugin(c); num > 0 {
+		_ = s.SqlRepository.SaveSyncCursor(nil, &model.SyncCursor{Worker: model.SyncCursorPlugins, BlockNum: int(num), FinalizedBlockNum: int(num)})
+	}
+}
+
 func (s *commonService) Close() {
 	s.RedisRepository.Close()
 	s.SqlRepository.Close()
@@ -108,8 +219,30 @@ func (s *commonService) SetHeartBeat(action string) {
 	_ = s.RedisRepository.SetHeartBeatNow(ctx, action)
 }
 
+func (s *commonService) SetRPCEndpointStatus(worker string, status interface{}) {
+	if err := s.RedisRepository.SetRPCEndpointStatus(context.TODO(), worker, status); err != nil {
+		log.Error("SetRPCEndpointStatus error ", err)
+	}
+}
+
+// RPCEndpointStatus is the endpoint pool view published by every worker
+func (s *commonService) RPCEndpointStatus() map[string]interface{} {
+	status := make(map[string]interface{})
+	raw, err := s.RedisRepository.GetRPCEndpointStatus(context.TODO())
+	if err != nil {
+		return status
+	}
+	for worker, value := range raw {
+		var endpoints interface{}
+		if json.Unmarshal([]byte(value), &endpoints) == nil {
+			status[worker] = endpoints
+		}
+	}
+	return status
+}
+
 func (s *commonService) unknownToken() {
-	websocket.SetEndpoint(util.WSEndPoint)
+	websocket.SetEndpoint(rpcpool.Active())
 	onceToken.Do(func() {
 		if p, _ := rpc.GetSystemProperties(nil); p != nil {
 			util.AddressType = util.IntToString(p.Ss58Format)
@@ -122,8 +255,8 @@ func (s *commonService) Ping(ctx context.Context, e *empty.Empty) (*empty.Empty,
 	return &empty.Empty{}, s.RedisRepository.Ping(ctx)
 }
 
-func (s *commonService) ValidatorsList(conn websocket.WsConn, hash string) (validatorList []string) {
-	validatorsRaw, _ := rpc.ReadStorage(conn, "Session", "Validators", hash)
+func (s *commonService) ValidatorsList(hash string) (validatorList []string) {
+	validatorsRaw, _ := s.ChainSource.ReadStorage("Session", "Validators", hash)
 	for _, addr := range validatorsRaw.ToStringSlice() {
 		validatorList = append(validatorList, util.TrimHex(addr))
 	}
@@ -131,7 +264,9 @@ func (s *commonService) ValidatorsList(conn websocket.WsConn, hash string) (vali
 }
 
 func (s *commonService) EmitLog(txn *model.GormDB, blockNum int, l []storage.DecoderLog, finalized bool, validatorList []string) (validator string, err error) {
-	s.SqlRepository.DropLogsNotFinalizedData(blockNum, finalized)
+	if err = s.SqlRepository.DropLogsNotFinalizedData(txn, blockNum, finalized); err != nil {
+		return "", err
+	}
 	for index, logData := range l {
 		dataStr := util.ToString(logData.Value)
 
@@ -4,13 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"strings"
 
 	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins"
 	"github.com/CoolBitX-Technology/subscan/util"
-	"github.com/itering/substrate-api-rpc/rpc"
-	"github.com/itering/substrate-api-rpc/websocket"
 	"github.com/shopspring/decimal"
 )
 
@@ -18,11 +16,13 @@ type extrinsicService struct {
 	SqlRepository   model.SqlRepository
 	RedisRepository model.RedisRepository
 	PluginService   model.PluginService
+	ChainSource     model.ChainSource
 }
 
 type ExtrinsicConfig struct {
 	SqlRepository   model.SqlRepository
 	RedisRepository model.RedisRepository
+	ChainSource     model.ChainSource
 }
 
 func NewExtrinsicService(c *ExtrinsicConfig, p model.PluginService) model.ExtrinsicService {
@@ -30,13 +30,44 @@ func NewExtrinsicService(c *ExtrinsicConfig, p model.PluginService) model.Extrin
 		SqlRepository:   c.SqlRepository,
 		RedisRepository: c.RedisRepository,
 		PluginService:   p,
+		ChainSource:     c.ChainSource,
 	}
 }
 
 func (e *extrinsicService) GetExtrinsicDetailByHash(hash string) *model.ExtrinsicDetail {
 	c := context.TODO()
 	blockNum, _ := e.RedisRepository.GetFillFinalizedBlockNum(c)
-	return e.SqlRepository.GetExtrinsicsDetailByHash(c, hash, blockNum)
+	return withExtrinsicError(e.SqlRepository.GetExtrinsicsDetailByHash(c, hash, blockNum))
+}
+
+// extrinsicErrors is the enabled plugin decoding the extrinsic errors, nil when there is none
+func extrinsicErrors() model.PluginExtrinsicErrors {
+	for _, name := range registeredNames() {
+		if p, ok := plugins.RegisteredPlugins[name].(model.PluginExtrinsicErrors); ok {
+			return p
+		}
+	}
+	return nil
+}
+
+func withExtrinsicError(detail *model.ExtrinsicDetail) *model.ExtrinsicDetail {
+	if detail == nil || detail.Success {
+		return detail
+	}
+	if p := extrinsicErrors(); p != nil {
+		detail.Error = p.ExtrinsicError(detail.ExtrinsicIndex)
+	}
+	return detail
+}
+
+// ExtrinsicErrorQuery is the where clause of the extrinsics failed with the error, false when no plugin
+// decodes the errors
+func (e *extrinsicService) ExtrinsicErrorQuery(module, name string) (string, bool) {
+	p := extrinsicErrors()
+	if p == nil {
+		return "", false
+	}
+	return p.ExtrinsicErrorQuery(module, name), true
 }
 
 func (e *extrinsicService) GetExtrinsicList(page, row int, order string, query ...string) ([]*model.ChainExtrinsicJson, int) {
@@ -72,6 +103,7 @@ func (s *extrinsicService) CreateExtrinsic(c context.Context,
 	encodeExtrinsics []string,
 	decodeExtrinsics []map[string]interface{},
 	eventMap map[string][]model.ChainEvent,
+	knownFees map[string]decimal.Decimal,
 ) (int, int, map[string]string, map[string]decimal.Decimal, error) {
 	var (
 		blockTimestamp int
@@ -99,9 +131,11 @@ func (s *extrinsicService) CreateExtrinsic(c context.Context,
 		extrinsic.BlockTimestamp = blockTimestamp
 
 		if extrinsic.ExtrinsicHash != "" {
-			fee, err := s.GetExtrinsicFee(nil, encodeExtrinsics[index], block.Hash)
-			if err != nil {
-				return 0, 0, nil, nil, err
+			fee, ok := knownFees[extrinsic.ExtrinsicIndex]
+			if !ok {
+				if fee, err = s.GetExtrinsicFee(encodeExtrinsics[index], block.Hash); err != nil {
+					return 0, 0, nil, nil, err
+				}
 			}
 			extrinsic.Fee = fee
 			extrinsicFee[extrinsic.ExtrinsicIndex] = fee
@@ -156,28 +190,8 @@ func (s *extrinsicService) getTimestamp(extrinsic *model.ChainExtrinsic) (blockT
 	return
 }
 
-func (s *extrinsicService) getPaymentQueryInfo(p websocket.WsConn, encodedExtrinsic string, blockHash string) (error, *rpc.PaymentQueryInfo) {
-	v := &rpc.JsonRpcResult{}
-	r := rpc.Param{Id: rand.Intn(10000), Method: "payment_queryInfo", Params: []string{encodedExtrinsic, blockHash}}
-	r.JsonRpc = "2.0"
-	b, _ := json.Marshal(r)
-	err := websocket.SendWsRequest(p, v, b)
-	return err, v.ToPaymentQueryInfo()
-}
-
-func (s *extrinsicService) GetExtrinsicFee(p websocket.WsConn, encodedExtrinsic string, blockHash string) (fee decimal.Decimal, err error) {
-	var paymentInfo *rpc.PaymentQueryInfo
-	// for i := 0; i < retry; i++ {
-	err, paymentInfo = s.getPaymentQueryInfo(p, encodedExtrinsic, blockHash)
-
-	// if err == nil {
-	// 	break
-	// }
-
-	// fmt.Fprintf(os.Stderr, "Request error: %+v\n", err)
-	// fmt.Fprintf(os.Stderr, "%d times Retrying in %v\n", i+1, 5*time.Second)
-	// time.Sleep(5 * time.Second)
-	// }
+func (s *extrinsicService) GetExtrinsicFee(encodedExtrinsic string, blockHash string) (fee decimal.Decimal, err error) {
+	paymentInfo, err := s.ChainSource.GetPaymentQueryInfo(encodedExtrinsic, blockHash)
 	if paymentInfo != nil {
 		return paymentInfo.PartialFee, nil
 	}
@@ -186,7 +200,7 @@ func (s *extrinsicService) GetExtrinsicFee(p websocket.WsConn, encodedExtrinsic
 
 func (s *extrinsicService) GetExtrinsicByIndex(index string) *model.ExtrinsicDetail {
 	c := context.TODO()
-	return s.SqlRepository.GetExtrinsicsDetailByIndex(c, index)
+	return withExtrinsicError(s.SqlRepository.GetExtrinsicsDetailByIndex(c, index))
 }
 
 func (s *extrinsicService) GetExtrinsicByHash(hash string) *model.ChainExtrinsic {
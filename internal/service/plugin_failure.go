@@ -0,0 +1,163 @@
+package service
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/prometheus/common/log"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	pluginRetryInterval    = 30 * time.Second
+	pluginRetryMaxInterval = time.Hour
+	// failures past this many automatic retries wait for a retry or discard through the admin api
+	pluginRetryMaxAttempts = 10
+	pluginRetryBatch       = 100
+)
+
+// callPlugin runs one plugin call, a panic is returned as an error together with its stack
+func callPlugin(fn func() error) (stack string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("plugin panic: %v", r)
+			stack = string(debug.Stack())
+		}
+	}()
+	return "", fn()
+}
+
+// recordPluginFailure dead-letters an item a plugin failed to process within the block transaction, the
+// first retry is due after pluginRetryInterval
+func (p *pluginService) recordPluginFailure(name string, blockNum int, kind, index string, err error, stack string) error {
+	log.Error("Plugin ", name, " failed ", kind, " ", index, ": ", err)
+	return p.SqlRepository.SavePluginFailure(p.DbStorage.OwnerTxn(blockNum), &model.PluginFailure{
+		Plugin:      name,
+		BlockNum:    blockNum,
+		Kind:        kind,
+		ItemIndex:   index,
+		Error:       err.Error(),
+		Stack:       stack,
+		NextRetryAt: time.Now().Add(pluginRetryInterval),
+	})
+}
+
+func (p *pluginService) PluginFailures(page, row int, plugin string) ([]model.PluginFailure, int) {
+	return p.SqlRepository.GetPluginFailureList(page, row, plugin)
+}
+
+// RetryPluginFailure feeds a dead-lettered item to its plugin again, it leaves the table on success
+// and is scheduled for the next retry with backoff otherwise
+func (p *pluginService) RetryPluginFailure(id uint) error {
+	failure := p.SqlRepository.GetPluginFailure(id)
+	if failure == nil {
+		return fmt.Errorf("plugin failure %d not found", id)
+	}
+	var stack string
+	err := p.inBlockTxn(failure.BlockNum, func(txn *model.GormDB) (err error) {
+		if stack, err = p.processPluginFailure(failure); err != nil {
+			return err
+		}
+		return p.SqlRepository.DeletePluginFailure(txn, failure.ID)
+	})
+	if err == nil {
+		log.Info("Plugin ", failure.Plugin, " retried ", failure.Kind, " ", failure.ItemIndex)
+		return nil
+	}
+	failure.Attempts++
+	failure.Error = err.Error()
+	failure.Stack = stack
+	failure.NextRetryAt = time.Now().Add(util.Backoff(failure.Attempts, pluginRetryInterval, pluginRetryMaxInterval))
+	if saveErr := p.SqlRepository.SavePluginFailure(nil, failure); saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+func (p *pluginService) DiscardPluginFailure(id uint) error {
+	if p.SqlRepository.GetPluginFailure(id) == nil {
+		return fmt.Errorf("plugin failure %d not found", id)
+	}
+	return p.SqlRepository.DeletePluginFailure(nil, id)
+}
+
+// retryPluginFailures retries the due failures every pluginRetryInterval until done is closed
+func (p *pluginService) retryPluginFailures(done chan struct{}) {
+	ticker := time.NewTicker(pluginRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, failure := range p.SqlRepository.DuePluginFailures(pluginRetryMaxAttempts, pluginRetryBatch) {
+				_ = p.RetryPluginFailure(failure.ID)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// processPluginFailure loads the stored extrinsic or event of a failure and feeds it to the plugin alone, it
+// runs within the transaction of the block
+func (p *pluginService) processPluginFailure(failure *model.PluginFailure) (string, error) {
+	plugin, ok := plugins.RegisteredPlugins[failure.Plugin]
+	if !ok {
+		return "", fmt.Errorf("plugin %s is not registered", failure.Plugin)
+	}
+	block := p.SqlRepository.GetBlockByNum(failure.BlockNum)
+	if block == nil {
+		return "", fmt.Errorf("block %d not found", failure.BlockNum)
+	}
+	switch failure.Kind {
+	case model.PluginFailureExtrinsic:
+		for _, extrinsic := range p.SqlRepository.GetRawExtrinsicsByBlockNum(failure.BlockNum) {
+			if extrinsic.ExtrinsicIndex != failure.ItemIndex {
+				continue
+			}
+			var pEvents []model.Event
+			for _, event := range p.SqlRepository.GetRawEventByBlockNum(failure.BlockNum) {
+				if fmt.Sprintf("%d-%d", event.BlockNum, event.ExtrinsicIdx) == extrinsic.ExtrinsicIndex {
+					pEvents = append(pEvents, *event.AsPlugin())
+				}
+			}
+			return p.retryPlugin(failure.BlockNum, func() error {
+				return plugin.ProcessExtrinsic(block.AsPlugin(), extrinsic.AsPlugin(), pEvents)
+			})
+		}
+	case model.PluginFailureEvent:
+		for _, event := range p.SqlRepository.GetRawEventByBlockNum(failure.BlockNum) {
+			if pluginEventIndex(&event) != failure.ItemIndex {
+				continue
+			}
+			fee := decimal.Zero
+			for _, extrinsic := range p.SqlRepository.GetRawExtrinsicsByBlockNum(failure.BlockNum) {
+				if extrinsic.ExtrinsicIndex == event.EventIndex && extrinsic.ExtrinsicHash != "" {
+					fee = extrinsic.Fee
+				}
+			}
+			return p.retryPlugin(failure.BlockNum, func() error {
+				return plugin.ProcessEvent(block.AsPlugin(), event.AsPlugin(), fee)
+			})
+		}
+	}
+	return "", fmt.Errorf("%s %s not found", failure.Kind, failure.ItemIndex)
+}
+
+// retryPlugin calls the plugin like the block feeding does, a duplicate entry means the item was stored already
+func (p *pluginService) retryPlugin(blockNum int, fn func() error) (string, error) {
+	stack, err := p.runPlugin(blockNum, fn)
+	if err != nil && strings.Contains(err.Error(), "Duplicate entry") {
+		return "", nil
+	}
+	return stack, err
+}
+
+// pluginEventIndex identifies an event within the chain, EventIndex is shared by the events of an extrinsic
+func pluginEventIndex(event *model.ChainEvent) string {
+	return fmt.Sprintf("%d-%d", event.BlockNum, event.EventIdx)
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/CoolBitX-Technology/subscan/internal/repository"
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins"
+	"github.com/prometheus/common/log"
+)
+
+// registerPlugins inits the registered plugins on their storage, brings their schema to the version of their
+// code and subscribes them. A plugin whose schema is newer than its code, or whose step fails, is disabled.
+// The versions are read under the plugin migrations lock, a process starting with another one applying the
+// same steps waits for it and finds them applied
+func registerPlugins(d *repository.DbStorage, sql model.SqlRepository) {
+	log.Info("--- PluginRegister ---")
+	unlock, lockErr := sql.LockPluginMigrations()
+	if lockErr == nil {
+		defer unlock()
+	}
+	for _, name := range registeredNames() {
+		plugin := plugins.RegisteredPlugins[name]
+		log.Info("name: ", name)
+		pending, err := pendingMigrations(sql, name, plugin)
+		if err == nil && len(pending) > 0 && lockErr != nil {
+			err = lockErr
+		}
+		if err != nil {
+			log.Error("Plugin ", name, " is not started: ", err)
+			plugins.Disable(name)
+			continue
+		}
+		// every plugin owns its prefix, TODO add network prefix
+		storage := d.WithPrefix(name)
+		plugin.InitDao(storage)
+		if err = applyMigrations(sql, storage, name, pending); err != nil {
+			log.Error("Plugin ", name, " is not started: ", err)
+			plugins.Disable(name)
+			continue
+		}
+		for _, moduleId := range plugin.SubscribeExtrinsic() {
+			subscribeExtrinsic[moduleId] = append(subscribeExtrinsic[moduleId], name)
+		}
+		for _, moduleId := range plugin.SubscribeEvent() {
+			subscribeEvent[moduleId] = append(subscribeEvent[moduleId], name)
+		}
+	}
+}
+
+// MigratePlugins applies the pending schema steps of every registered plugin under the plugin migrations lock,
+// dryRun only lists them
+func (p *pluginService) MigratePlugins(dryRun bool) ([]model.PluginSchemaMigration, error) {
+	var steps []model.PluginSchemaMigration
+	if !dryRun {
+		unlock, err := p.SqlRepository.LockPluginMigrations()
+		if err != nil {
+			return steps, err
+		}
+		defer unlock()
+	}
+	for _, name := range registeredNames() {
+		plugin := plugins.RegisteredPlugins[name]
+		pending, err := pendingMigrations(p.SqlRepository, name, plugin)
+		if err != nil {
+			return steps, err
+		}
+		if dryRun || len(pending) == 0 {
+			for _, step := range pending {
+				steps = append(steps, model.PluginSchemaMigration{Plugin: name, Version: step.Version, Name: step.Name})
+			}
+			continue
+		}
+		storage := p.DbStorage.WithPrefix(name)
+		plugin.InitDao(storage)
+		for _, step := range pending {
+			if err = applyMigrations(p.SqlRepository, storage, name, []model.PluginMigration{step}); err != nil {
+				return steps, err
+			}
+			steps = append(steps, model.PluginSchemaMigration{Plugin: name, Version: step.Version, Name: step.Name})
+		}
+	}
+	return steps, nil
+}
+
+func registeredNames() []string {
+	names := make([]string, 0, len(plugins.RegisteredPlugins))
+	for name := range plugins.RegisteredPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pendingMigrations are the steps of a plugin implementing model.PluginMigrations not applied yet
+func pendingMigrations(sql model.SqlRepository, name string, plugin model.Plugin) ([]model.PluginMigration, error) {
+	m, ok := plugin.(model.PluginMigrations)
+	if !ok {
+		return nil, nil
+	}
+	applied, err := sql.GetPluginSchemaVersion(name)
+	if err != nil {
+		return nil, err
+	}
+	return model.PendingMigrations(name, m.Migrations(), applied)
+}
+
+// applyMigrations runs the steps in order, each is recorded once it succeeded
+func applyMigrations(sql model.SqlRepository, d model.Dao, name string, steps []model.PluginMigration) error {
+	for _, step := range steps {
+		log.Info("Migrate plugin ", name, " to version ", step.Version, ": ", step.Name)
+		if err := step.Up(d); err != nil {
+			return fmt.Errorf("plugin %s migration %d %s: %v", name, step.Version, step.Name, err)
+		}
+		if err := sql.SavePluginMigration(&model.PluginSchemaMigration{Plugin: name, Version: step.Version, Name: step.Name}); err != nil {
+			return fmt.Errorf("plugin %s migration %d %s is applied but not recorded: %v", name, step.Version, step.Name, err)
+		}
+	}
+	return nil
+}
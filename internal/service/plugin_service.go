@@ -7,11 +7,14 @@ import (
 	"math/rand"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/CoolBitX-Technology/subscan/internal/repository"
+	"github.com/CoolBitX-Technology/subscan/internal/rpcpool"
 	"github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/plugins"
 	"github.com/CoolBitX-Technology/subscan/util"
@@ -24,25 +27,14 @@ import (
 )
 
 var (
-	subscribeExtrinsic = make(map[string][]plugins.PluginFactory)
-	subscribeEvent     = make(map[string][]plugins.PluginFactory)
+	// plugin names by the module they subscribe to
+	subscribeExtrinsic = make(map[string][]string)
+	subscribeEvent     = make(map[string][]string)
 )
 
 // registered storage
 func (p *pluginService) PluginRegister() {
-	log.Info("--- PluginRegister ---")
-	for name, plugin := range plugins.RegisteredPlugins {
-		log.Info("name: ", name)
-		db := p.DbStorage
-		db.Prefix = name // TODO add network prefix
-		plugin.InitDao(db)
-		for _, moduleId := range plugin.SubscribeExtrinsic() {
-			subscribeExtrinsic[moduleId] = append(subscribeExtrinsic[moduleId], plugin)
-		}
-		for _, moduleId := range plugin.SubscribeEvent() {
-			subscribeEvent[moduleId] = append(subscribeEvent[moduleId], plugin)
-		}
-	}
+	registerPlugins(p.DbStorage, p.SqlRepository)
 }
 
 // TODO get plugin by name method
@@ -54,13 +46,13 @@ type pluginService struct {
 	RedisRepository model.RedisRepository
 	SqlRepository   model.SqlRepository
 	CommonService   model.CommonService
-	DbStorage       *DbStorage
+	DbStorage       *repository.DbStorage
 }
 
 type PluginConfig struct {
 	RedisRepository model.RedisRepository
 	SqlRepository   model.SqlRepository
-	DbStorage       *DbStorage
+	DbStorage       *repository.DbStorage
 }
 
 func NewPluginService(c *PluginConfig, cs model.CommonService) model.PluginService {
@@ -111,15 +103,8 @@ func (p *pluginService) Subscribe(conn ws.WsConn, interrupt chan os.Signal) {
 		}
 	}()
 
-	if err = conn.WriteMessage(websocket.TextMessage, rpc.ChainGetRuntimeVersion(runtimeVersion)); err != nil {
-		log.Info("write: ", err)
-	}
-	if err = conn.WriteMessage(websocket.TextMessage, rpc.ChainSubscribeNewHead(newHeader)); err != nil {
-		log.Info("write: ", err)
-	}
-	if err = conn.WriteMessage(websocket.TextMessage, rpc.ChainSubscribeFinalizedHeads(finalizeHeader)); err != nil {
-		log.Info("write: ", err)
-	}
+	endpoint := rpcpool.Active()
+	subscribeTopics(conn)
 
 	ticker := time.NewTicker(time.Second * 3)
 	defer ticker.Stop()
@@ -129,8 +114,14 @@ func (p *pluginService) Subscribe(conn ws.WsConn, interrupt chan os.Signal) {
 		case <-done:
 			return
 		case <-ticker.C:
+			if active := rpcpool.Active(); active != endpoint {
+				log.Warn("--- Switch Plugins WebSocket Connection to ", active, " ---")
+				endpoint = switchEndpoint(conn, active)
+				continue
+			}
 			if err := conn.WriteMessage(websocket.TextMessage, rpc.SystemHealth(rand.Intn(100)+finalizeHeader)); err != nil {
 				log.Info("SystemHealth get error: ", err)
+				rpcpool.Observe(endpoint, 0, 0, err)
 				if !conn.IsConnected() {
 					log.Info("--- SetUp Plugins WebSocket Connection ---")
 					conn.CloseAndReconnect()
@@ -169,22 +160,37 @@ func (p *pluginService) Parser(message []byte) (err error) {
 	return
 }
 
+// pluginBlock is a block to feed to the plugins whose cursor, as of when it was queued, is behind it
+type pluginBlock struct {
+	blockNum int
+	cursors  map[string]int
+}
+
 func (p *pluginService) PluginsFetchBlock() {
 	log.Info("--- PluginsFetchBlock ---")
-	var wg sync.WaitGroup
-	var lastNum uint64
-	ctx := context.TODO()
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		fed map[int]bool
+		ctx = context.TODO()
+	)
+	go p.retryPluginFailures(p.done)
 
 	pool, _ := ants.NewPoolWithFunc(10, func(i interface{}) {
-		blockNum := i.(model.BlockFinalized)
-		func(bf model.BlockFinalized) {
-			if err := p.fillPluginData(bf.BlockNum, bf.Finalized); err != nil {
+		func(pb pluginBlock) {
+			ok, err := p.fillPluginData(pb.blockNum, pb.cursors)
+			if err != nil {
 				log.Error("fill-in block data cause error ", err)
 			} else {
+				if ok {
+					mu.Lock()
+					fed[pb.blockNum] = true
+					mu.Unlock()
+				}
 				c := fmt.Sprintf("%s:heartBeat:%s", util.NetworkNode, "plugins")
 				p.CommonService.SetHeartBeat(c)
 			}
-		}(blockNum)
+		}(i.(pluginBlock))
 		wg.Done()
 	}, ants.WithOptions(ants.Options{PanicHandler: func(c interface{}) {}}))
 
@@ -197,46 +203,288 @@ func (p *pluginService) PluginsFetchBlock() {
 				time.Sleep(BlockTime * time.Second)
 				return
 			}
-			if lastNum, err = p.RedisRepository.GetFinalizedBlockNumForPlugin(ctx); err != nil {
-				log.Warn(err)
-			}
+			// reloaded every batch, a plugin rollback rewinds the stored cursors
+			cursors := p.loadPluginCursors()
+			snapshot := cursors.snapshot()
+			lastNum := cursors.min()
 			log.Info("plugins sync lastNum: ", lastNum)
 			startBlock := lastNum + 1
 			if lastNum == 0 {
 				startBlock = lastNum
 			}
-			for i := int(startBlock); i <= int(final-FinalizedWaitingBlockCountForPlugin); i++ {
-				wg.Add(1)
-				if err := pool.Invoke(model.BlockFinalized{BlockNum: i, Finalized: true}); err != nil {
-					log.Error("Invoke fillPluginData error: ", err)
+			fed = make(map[int]bool)
+			end := final - FinalizedWaitingBlockCountForPlugin
+			concurrent, inOrder := splitInOrder(snapshot)
+			if len(concurrent) > 0 {
+				for i := startBlock; i <= end; i++ {
+					wg.Add(1)
+					if err := pool.Invoke(pluginBlock{blockNum: i, cursors: concurrent}); err != nil {
+						wg.Done()
+						log.Error("Invoke fillPluginData error: ", err)
+					}
+				}
+				wg.Wait()
+			}
+			advanced := make(map[string]int, len(snapshot))
+			for name, blockNum := range concurrent {
+				advanced[name] = blockNum
+				for advanced[name] < end && fed[advanced[name]+1] {
+					advanced[name]++
 				}
 			}
-			wg.Wait()
+			if len(inOrder) > 0 {
+				last := p.fillPluginDataInOrder(startBlock, end, inOrder)
+				for name, blockNum := range inOrder {
+					advanced[name] = blockNum
+					if last > blockNum {
+						advanced[name] = last
+					}
+				}
+			}
+			p.advancePluginCursors(snapshot, advanced)
 		case <-p.done:
 			return
 		}
 	}
 }
 
-func (p *pluginService) fillPluginData(blockNum int, finalized bool) (err error) {
-	var block *model.ChainBlock
-	var extrinsics []model.ChainExtrinsic
-	var events []model.ChainEvent
+// pluginCursors is the last block fed to every registered plugin, keyed by plugin name
+type pluginCursors struct {
+	sync.Mutex
+	blockNums map[string]int
+}
+
+func (c *pluginCursors) snapshot() map[string]int {
+	c.Lock()
+	defer c.Unlock()
+	blockNums := make(map[string]int, len(c.blockNums))
+	for name, num := range c.blockNums {
+		blockNums[name] = num
+	}
+	return blockNums
+}
+
+func (c *pluginCursors) min() int {
+	c.Lock()
+	defer c.Unlock()
+	min := -1
+	for _, num := range c.blockNums {
+		if min == -1 || num < min {
+			min = num
+		}
+	}
+	if min < 0 {
+		return 0
+	}
+	return min
+}
+
+// splitInOrder separates the cursors of the plugins fed blocks concurrently from those of the plugins
+// implementing model.PluginInOrder
+func splitInOrder(cursors map[string]int) (concurrent, inOrder map[string]int) {
+	concurrent, inOrder = make(map[string]int), make(map[string]int)
+	for name, blockNum := range cursors {
+		if pluginInOrder(name) {
+			inOrder[name] = blockNum
+		} else {
+			concurrent[name] = blockNum
+		}
+	}
+	return concurrent, inOrder
+}
+
+// pluginInOrder tells whether a plugin implements model.PluginInOrder, it cannot skip a failed item
+func pluginInOrder(name string) bool {
+	p, ok := plugins.RegisteredPlugins[name].(model.PluginInOrder)
+	return ok && p.InOrder()
+}
+
+// fillPluginDataInOrder feeds the blocks one after another to the plugins of cursors, it stops at the first
+// block not fed, a failed item rolls the block back, and returns the last one fed
+func (p *pluginService) fillPluginDataInOrder(start, end int, cursors map[string]int) int {
+	for blockNum := start; blockNum <= end; blockNum++ {
+		ok, err := p.fillPluginData(blockNum, cursors)
+		if err != nil {
+			log.Error("fill-in block data in order cause error ", err)
+		}
+		if err != nil || !ok {
+			return blockNum - 1
+		}
+		p.CommonService.SetHeartBeat(fmt.Sprintf("%s:heartBeat:%s", util.NetworkNode, "plugins"))
+	}
+	return end
+}
 
+// advancePluginCursors moves the cursor of every plugin to the last block of the batch fed to it without a gap,
+// a block committed after a later one did not move the cursor in its transaction. The first block not fed
+// stops the cursor, it is fed again with the next batch
+func (p *pluginService) advancePluginCursors(cursors, advanced map[string]int) {
+	shared := -1
+	for name, blockNum := range cursors {
+		if shared == -1 || advanced[name] < shared {
+			shared = advanced[name]
+		}
+		if advanced[name] == blockNum {
+			continue
+		}
+		cursor := model.SyncCursor{Worker: model.SyncCursorPlugins, Plugin: pluginCursorKey(name), BlockNum: advanced[name], FinalizedBlockNum: advanced[name]}
+		if err := p.SqlRepository.SaveSyncCursor(nil, &cursor); err != nil {
+			log.Error("Save plugin cursor ", cursor.Plugin, " error ", err)
+		}
+	}
+	if shared <= 0 {
+		return
+	}
+	if err := p.SqlRepository.SaveSyncCursor(nil, &model.SyncCursor{Worker: model.SyncCursorPlugins, BlockNum: shared, FinalizedBlockNum: shared}); err != nil {
+		log.Error("Save plugins cursor error ", err)
+	}
+	p.updateChainMetadata(map[string]interface{}{"plugins:finalized_blockNum": shared})
+}
+
+// Rollback tells the plugins the blocks after blockNum are no longer canonical. Plugins implementing
+// model.PluginRollback drop their rows, and the cursors are rewound so the blocks are fed again
+func (p *pluginService) Rollback(blockNum int) error {
+	rewound := false
+	for name, plugin := range plugins.RegisteredPlugins {
+		key := pluginCursorKey(name)
+		if cursor := p.SqlRepository.GetSyncCursor(model.SyncCursorPlugins, key); cursor == nil || cursor.BlockNum <= blockNum {
+			continue
+		}
+		if r, ok := plugin.(model.PluginRollback); ok {
+			if err := r.Rollback(blockNum); err != nil {
+				return fmt.Errorf("plugin %s rollback to %d: %v", key, blockNum, err)
+			}
+		}
+		if err := p.SqlRepository.RewindSyncCursor(model.SyncCursorPlugins, key, blockNum); err != nil {
+			return err
+		}
+		log.Warn("Plugin ", key, " rolled back to block ", blockNum)
+		rewound = true
+	}
+	if !rewound {
+		return nil
+	}
+	return p.SqlRepository.RewindSyncCursor(model.SyncCursorPlugins, "", blockNum)
+}
+
+// pluginCursorKey identifies the cursor of a plugin version, a new version starts a new cursor
+func pluginCursorKey(name string) string {
+	return fmt.Sprintf("%s:%s", name, plugins.RegisteredPlugins[name].Version())
+}
+
+// loadPluginCursors reads the cursor of every registered plugin, a plugin without one starts
+// following the head from the shared plugins cursor, its history is filled by plugins reindex
+func (p *pluginService) loadPluginCursors() *pluginCursors {
+	shared, err := p.RedisRepository.GetFinalizedBlockNumForPlugin(context.TODO())
+	if err != nil {
+		log.Warn(err)
+	}
+	cursors := pluginCursors{blockNums: make(map[string]int)}
+	for name := range plugins.RegisteredPlugins {
+		key := pluginCursorKey(name)
+		if cursor := p.SqlRepository.GetSyncCursor(model.SyncCursorPlugins, key); cursor != nil {
+			cursors.blockNums[name] = cursor.FinalizedBlockNum
+			continue
+		}
+		log.Warn("Plugin ", key, " has no cursor, following the head from ", shared, ", run plugins reindex to fill its history")
+		cursors.blockNums[name] = int(shared)
+		_ = p.SqlRepository.SaveSyncCursor(nil, &model.SyncCursor{Worker: model.SyncCursorPlugins, Plugin: key, BlockNum: int(shared), FinalizedBlockNum: int(shared)})
+	}
+	return &cursors
+}
+
+// fillPluginData feeds a stored block to the plugins whose cursor is behind it, a cursor right before the block
+// advances to it in the block transaction. It returns false when the block is not stored yet
+func (p *pluginService) fillPluginData(blockNum int, cursors map[string]int) (ok bool, err error) {
 	log.Info("fillPluginData with block: ", blockNum)
 
 	if bestNum, _ := p.RedisRepository.GetFillBestBlockNum(context.TODO()); blockNum > bestNum {
-		return nil
+		return false, nil
+	}
+
+	var names []string
+	for name, num := range cursors {
+		if num < blockNum {
+			names = append(names, name)
+		}
 	}
+	if len(names) == 0 {
+		return true, nil
+	}
+	// concurrent blocks lock the cursor rows in the same order
+	sort.Strings(names)
 
-	if block = p.SqlRepository.GetBlockByNum(blockNum); block == nil {
+	err = p.inBlockTxn(blockNum, func(txn *model.GormDB) error {
+		if ok, err = p.emitStoredBlock(blockNum, names); err != nil || !ok {
+			return err
+		}
+		for _, name := range names {
+			cursor := model.SyncCursor{Worker: model.SyncCursorPlugins, Plugin: pluginCursorKey(name), BlockNum: blockNum, FinalizedBlockNum: blockNum}
+			if err = p.SqlRepository.AdvanceSyncCursor(txn, &cursor); err != nil {
+				return err
+			}
+		}
 		return nil
+	})
+	return ok && err == nil, err
+}
+
+// FeedBlock feeds a stored block to every plugin in one transaction, it returns false when the block
+// is not fully stored yet
+func (p *pluginService) FeedBlock(blockNum int) (ok bool, err error) {
+	err = p.inBlockTxn(blockNum, func(*model.GormDB) error {
+		ok, err = p.emitStoredBlock(blockNum, nil)
+		return err
+	})
+	return ok, err
+}
+
+// inBlockTxn opens the transaction the plugins write block blockNum in, it commits together with what
+// fn writes through txn and rolls back entirely when fn fails
+func (p *pluginService) inBlockTxn(blockNum int, fn func(txn *model.GormDB) error) error {
+	txn, err := p.DbStorage.BeginBlock(blockNum)
+	if err != nil {
+		return err
+	}
+	if err = fn(txn); err != nil {
+		if rollbackErr := p.DbStorage.EndBlock(blockNum, false); rollbackErr != nil {
+			log.Error("Rollback plugins block ", blockNum, " error ", rollbackErr)
+		}
+		return err
+	}
+	return p.DbStorage.EndBlock(blockNum, true)
+}
+
+// runPlugin calls a plugin within a savepoint of the block transaction, the writes of a failing call are undone
+// while the other plugins keep theirs
+func (p *pluginService) runPlugin(blockNum int, fn func() error) (string, error) {
+	savepoint, err := p.DbStorage.Savepoint(blockNum)
+	if err != nil {
+		return "", err
+	}
+	stack, err := callPlugin(fn)
+	if err != nil && savepoint != nil {
+		p.DbStorage.DbRollback(savepoint)
+	}
+	return stack, err
+}
+
+// emitStoredBlock feeds the stored extrinsics and events of a block to the named plugins, it returns
+// false when the block is not fully stored yet
+func (p *pluginService) emitStoredBlock(blockNum int, names []string) (bool, error) {
+	var block *model.ChainBlock
+	var extrinsics []model.ChainExtrinsic
+	var events []model.ChainEvent
+
+	// a block not finalized yet may still be orphaned by a reorg
+	if block = p.SqlRepository.GetBlockByNum(blockNum); block == nil || !block.Finalized {
+		return false, nil
 	}
 	if extrinsics = p.SqlRepository.GetRawExtrinsicsByBlockNum(blockNum); extrinsics == nil {
-		return nil
+		return false, nil
 	}
 	if events = p.SqlRepository.GetRawEventByBlockNum(blockNum); events == nil {
-		return nil
+		return false, nil
 	}
 
 	eventMap := make(map[string][]model.ChainEvent)
@@ -259,25 +507,66 @@ func (p *pluginService) fillPluginData(blockNum int, finalized bool) (err error)
 		if extrinsic.ExtrinsicHash != "" {
 			feeMap[extrinsic.ExtrinsicIndex] = extrinsic.Fee
 		}
-		if err = p.EmitExtrinsic(block, &extrinsic, eventMap); err != nil {
-			return err
+		if err := p.emitExtrinsic(block, &extrinsic, eventMap, names); err != nil {
+			return false, err
 		}
 	}
 	for _, event := range events {
-		if err = p.EmitEvent(block, &event, feeMap); err != nil {
-			return err
+		if err := p.emitEvent(block, &event, feeMap, names); err != nil {
+			return false, err
 		}
 	}
+	return true, nil
+}
 
-	if err == nil {
-		p.updateChainMetadata(map[string]interface{}{"plugins:finalized_blockNum": blockNum})
+// Reindex replays the stored blocks [from, to] through one plugin while the daemon keeps feeding the
+// head to every plugin, to 0 replays up to the cursor of the plugin
+func (p *pluginService) Reindex(name string, from, to int) error {
+	name = strings.ToLower(name)
+	if _, ok := plugins.RegisteredPlugins[name]; !ok {
+		return fmt.Errorf("plugin %s is not registered", name)
+	}
+	key := pluginCursorKey(name)
+	if to == 0 {
+		cursor := p.SqlRepository.GetSyncCursor(model.SyncCursorPlugins, key)
+		if cursor == nil {
+			return fmt.Errorf("plugin %s has no cursor yet, set --to", key)
+		}
+		to = cursor.FinalizedBlockNum
+	}
+	if from < 0 || to < from {
+		return fmt.Errorf("invalid reindex range %d - %d", from, to)
 	}
 
+	log.Info("Reindex plugin ", key, " from ", from, " to ", to)
+	names := []string{name}
+	for blockNum := from; blockNum <= to; blockNum++ {
+		var ok bool
+		err := p.inBlockTxn(blockNum, func(*model.GormDB) (err error) {
+			ok, err = p.emitStoredBlock(blockNum, names)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("reindex plugin %s at block %d: %v", key, blockNum, err)
+		}
+		if !ok {
+			log.Warn("Reindex plugin ", key, " skipped block ", blockNum, ", it is not stored")
+		}
+		if blockNum%1000 == 0 {
+			log.Info("Reindex plugin ", key, " at block ", blockNum)
+		}
+	}
+	log.Info("Reindex plugin ", key, " finished")
 	return nil
 }
 
 // after extrinsic created, emit extrinsic data to subscribe plugins
 func (p *pluginService) EmitExtrinsic(block *model.ChainBlock, extrinsic *model.ChainExtrinsic, eventsMap map[string][]model.ChainEvent) (err error) {
+	return p.emitExtrinsic(block, extrinsic, eventsMap, nil)
+}
+
+// emitExtrinsic emits to the subscribed plugins within names, nil names emits to all of them
+func (p *pluginService) emitExtrinsic(block *model.ChainBlock, extrinsic *model.ChainExtrinsic, eventsMap map[string][]model.ChainEvent, names []string) (err error) {
 	pBlock := block.AsPlugin()
 	pExtrinsic := extrinsic.AsPlugin()
 	events := eventsMap[pExtrinsic.ExtrinsicIndex]
@@ -291,12 +580,22 @@ func (p *pluginService) EmitExtrinsic(block *model.ChainBlock, extrinsic *model.
 		pEvents = append(pEvents, *event.AsPlugin())
 	}
 
-	for _, plugin := range subscribeExtrinsic[extrinsic.CallModule] {
-		log.Info("plugin: ", plugin)
-		err = plugin.ProcessExtrinsic(pBlock, pExtrinsic, pEvents)
+	for _, name := range subscribeExtrinsic[extrinsic.CallModule] {
+		if names != nil && !util.StringInSlice(name, names) {
+			continue
+		}
+		log.Info("plugin: ", name)
+		plugin := plugins.RegisteredPlugins[name]
+		stack, err := p.runPlugin(block.BlockNum, func() error { return plugin.ProcessExtrinsic(pBlock, pExtrinsic, pEvents) })
 		if err != nil && strings.Contains(err.Error(), "Duplicate entry") != true {
-			log.Error(err)
-			return err
+			// a plugin fed in order rolls the block back and holds its cursor
+			if pluginInOrder(name) {
+				return fmt.Errorf("plugin %s extrinsic %s: %v", name, extrinsic.ExtrinsicIndex, err)
+			}
+			// the other plugins go on, the item is retried from the dead-letter table
+			if err = p.recordPluginFailure(name, block.BlockNum, model.PluginFailureExtrinsic, extrinsic.ExtrinsicIndex, err, stack); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -304,6 +603,11 @@ func (p *pluginService) EmitExtrinsic(block *model.ChainBlock, extrinsic *model.
 
 // after event created, emit event data to subscribe plugins
 func (p *pluginService) EmitEvent(block *model.ChainBlock, event *model.ChainEvent, feeMap map[string]decimal.Decimal) (err error) {
+	return p.emitEvent(block, event, feeMap, nil)
+}
+
+// emitEvent emits to the subscribed plugins within names, nil names emits to all of them
+func (p *pluginService) emitEvent(block *model.ChainBlock, event *model.ChainEvent, feeMap map[string]decimal.Decimal, names []string) (err error) {
 
 	pBlock := block.AsPlugin()
 	pEvent := event.AsPlugin()
@@ -313,11 +617,20 @@ func (p *pluginService) EmitEvent(block *model.ChainBlock, event *model.ChainEve
 	log.Info(subscribeEvent[event.ModuleId])
 
 	fee := feeMap[event.EventIndex]
-	for _, plugin := range subscribeEvent[event.ModuleId] {
-		err = plugin.ProcessEvent(pBlock, pEvent, fee)
+	for _, name := range subscribeEvent[event.ModuleId] {
+		if names != nil && !util.StringInSlice(name, names) {
+			continue
+		}
+		plugin := plugins.RegisteredPlugins[name]
+		stack, err := p.runPlugin(block.BlockNum, func() error { return plugin.ProcessEvent(pBlock, pEvent, fee) })
 		// log.Info(strings.Contains(err.Error(), "Duplicate entry"))
 		if err != nil && strings.Contains(err.Error(), "Duplicate entry") != true {
-			return err
+			if pluginInOrder(name) {
+				return fmt.Errorf("plugin %s event %s: %v", name, pluginEventIndex(event), err)
+			}
+			if err = p.recordPluginFailure(name, block.BlockNum, model.PluginFailureEvent, pluginEventIndex(event), err, stack); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
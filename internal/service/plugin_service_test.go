@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins"
+	"github.com/CoolBitX-Technology/subscan/plugins/testkit"
+	"github.com/itering/subscan-plugin/router"
+	"github.com/shopspring/decimal"
+)
+
+// feedSql serves stored finalized blocks with one extrinsic and one event, the plugin cursors are written
+// through the block transaction
+type feedSql struct {
+	model.SqlRepository
+	failures []model.PluginFailure
+}
+
+func (s *feedSql) GetBlockByNum(blockNum int) *model.ChainBlock {
+	return &model.ChainBlock{BlockNum: blockNum, Finalized: true}
+}
+
+func (s *feedSql) GetRawExtrinsicsByBlockNum(blockNum int) []model.ChainExtrinsic {
+	return []model.ChainExtrinsic{{BlockNum: blockNum, ExtrinsicIndex: fmt.Sprintf("%d-0", blockNum), CallModule: "feed"}}
+}
+
+func (s *feedSql) GetRawEventByBlockNum(blockNum int, _ ...string) []model.ChainEvent {
+	return []model.ChainEvent{{BlockNum: blockNum, ModuleId: "feed", EventIndex: fmt.Sprintf("%d-0", blockNum)}}
+}
+
+func (s *feedSql) AdvanceSyncCursor(txn *model.GormDB, cursor *model.SyncCursor) error {
+	return txn.Create(cursor).Error
+}
+
+func (s *feedSql) SavePluginFailure(_ *model.GormDB, failure *model.PluginFailure) error {
+	s.failures = append(s.failures, *failure)
+	return nil
+}
+
+type feedRedis struct {
+	model.RedisRepository
+	best int
+}
+
+func (r *feedRedis) GetFillBestBlockNum(context.Context) (int, error) {
+	return r.best, nil
+}
+
+type feedCommon struct {
+	model.CommonService
+}
+
+func (c *feedCommon) SetHeartBeat(string) {}
+
+// feedPlugin subscribes the feed module and fails the extrinsic of failAt
+type feedPlugin struct {
+	inOrder bool
+	failAt  int
+}
+
+func (f *feedPlugin) InitDao(model.Dao)            {}
+func (f *feedPlugin) InitHttp() []router.Http      { return nil }
+func (f *feedPlugin) Migrate()                     {}
+func (f *feedPlugin) SubscribeExtrinsic() []string { return []string{"feed"} }
+func (f *feedPlugin) SubscribeEvent() []string     { return []string{"feed"} }
+func (f *feedPlugin) Version() string              { return "0.1" }
+func (f *feedPlugin) InOrder() bool                { return f.inOrder }
+
+func (f *feedPlugin) ProcessExtrinsic(block *model.Block, _ *model.Extrinsic, _ []model.Event) error {
+	if block.BlockNum == f.failAt {
+		return errors.New("ledger gap")
+	}
+	return nil
+}
+
+func (f *feedPlugin) ProcessEvent(*model.Block, *model.Event, decimal.Decimal) error {
+	return nil
+}
+
+func TestFillPluginDataInOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		inOrder      bool
+		wantFed      int
+		wantCursor   int
+		wantFailures int
+	}{
+		{name: "in order failure holds the cursor", inOrder: true, wantFed: 101, wantCursor: 101},
+		{name: "concurrent failure is dead-lettered", inOrder: false, wantFed: 103, wantCursor: 103, wantFailures: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dao, err := testkit.NewDao("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dao.Close()
+			if err = dao.DB().AutoMigrate(&model.SyncCursor{}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			plugins.RegisteredPlugins["feed"] = &feedPlugin{inOrder: tt.inOrder, failAt: 102}
+			subscribeExtrinsic["feed"], subscribeEvent["feed"] = []string{"feed"}, []string{"feed"}
+			defer func() {
+				delete(plugins.RegisteredPlugins, "feed")
+				delete(subscribeExtrinsic, "feed")
+				delete(subscribeEvent, "feed")
+			}()
+
+			sql := &feedSql{}
+			p := &pluginService{RedisRepository: &feedRedis{best: 103}, SqlRepository: sql, CommonService: &feedCommon{}, DbStorage: dao.DbStorage}
+			if fed := p.fillPluginDataInOrder(100, 103, map[string]int{"feed": 99}); fed != tt.wantFed {
+				t.Errorf("fed up to %d, want %d", fed, tt.wantFed)
+			}
+
+			var cursor model.SyncCursor
+			if err = dao.DB().Order("block_num desc").First(&cursor).Error; err != nil {
+				t.Fatal(err)
+			}
+			if cursor.BlockNum != tt.wantCursor {
+				t.Errorf("cursor %d, want %d", cursor.BlockNum, tt.wantCursor)
+			}
+			if len(sql.failures) != tt.wantFailures {
+				t.Errorf("%d failures dead-lettered, want %d", len(sql.failures), tt.wantFailures)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/prometheus/common/log"
+)
+
+type redecodeService struct {
+	RedisRepository model.RedisRepository
+	SqlRepository   model.SqlRepository
+	CommonService   model.CommonService
+	BlockService    model.BlockService
+	PluginService   model.PluginService
+}
+
+type RedecodeConfig struct {
+	RedisRepository model.RedisRepository
+	SqlRepository   model.SqlRepository
+}
+
+func NewRedecodeService(c *RedecodeConfig, cs model.CommonService, b model.BlockService, p model.PluginService) model.RedecodeService {
+	return &redecodeService{
+		RedisRepository: c.RedisRepository,
+		SqlRepository:   c.SqlRepository,
+		CommonService:   cs,
+		BlockService:    b,
+		PluginService:   p,
+	}
+}
+
+// specDecodeReport counts the re-decoded blocks of one spec version
+type specDecodeReport struct {
+	Blocks     int
+	Failed     int
+	FirstBlock int
+	FirstError error
+}
+
+// Redecode rebuilds the extrinsics, events and logs of the stored blocks [from, to] from their
+// raw data with the current type registry. codecErrorOnly limits it to blocks that failed to
+// decode before, flagged with codec_error or stored without events
+func (r *redecodeService) Redecode(from, to, workers int, codecErrorOnly bool) error {
+	if from < 0 || to < from {
+		return fmt.Errorf("invalid redecode range %d - %d", from, to)
+	}
+	return r.redecode(workers, func(blocks chan<- model.ChainBlock) {
+		for num := from; num <= to; num += archiveQueryBatch {
+			var nums []int
+			for i := num; i <= to && i < num+archiveQueryBatch; i++ {
+				nums = append(nums, i)
+			}
+			stored := r.SqlRepository.BlocksReverseByNum(nums)
+			for _, i := range nums {
+				block, ok := stored[i]
+				if !ok || (codecErrorOnly && !block.CodecError && (block.EventCount > 0 || block.BlockNum == 0)) {
+					continue
+				}
+				blocks <- block
+			}
+		}
+	})
+}
+
+// RetryDecodeFailures re-decodes the quarantined blocks of decode_failures, a block leaves the table
+// once it decodes
+func (r *redecodeService) RetryDecodeFailures(workers int) error {
+	nums := r.SqlRepository.DecodeFailureBlockNums()
+	if len(nums) == 0 {
+		log.Info("No decode failure to retry")
+		return nil
+	}
+	return r.redecode(workers, func(blocks chan<- model.ChainBlock) {
+		for start := 0; start < len(nums); start += archiveQueryBatch {
+			end := start + archiveQueryBatch
+			if end > len(nums) {
+				end = len(nums)
+			}
+			stored := r.SqlRepository.BlocksReverseByNum(nums[start:end])
+			for _, i := range nums[start:end] {
+				if block, ok := stored[i]; ok {
+					blocks <- block
+				}
+			}
+		}
+	})
+}
+
+// redecode runs RedecodeBlock over the blocks sent by feed and reports the outcome per spec version, the
+// plugins are rolled back once to the lowest block of the run afterwards to ingest the new decoding
+func (r *redecodeService) redecode(workers int, feed func(blocks chan<- model.ChainBlock)) error {
+	if workers < 1 {
+		workers = 1
+	}
+	r.CommonService.InitSubRuntimeLatest()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		lowest  = -1
+		reports = make(map[int]*specDecodeReport)
+		blocks  = make(chan model.ChainBlock, workers*2)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for block := range blocks {
+				err := r.BlockService.RedecodeBlock(&block)
+				mu.Lock()
+				report, ok := reports[block.SpecVersion]
+				if !ok {
+					report = &specDecodeReport{}
+					reports[block.SpecVersion] = report
+				}
+				report.Blocks++
+				if lowest == -1 || block.BlockNum < lowest {
+					lowest = block.BlockNum
+				}
+				if err != nil {
+					log.Error("Redecode block ", block.BlockNum, " error ", err)
+					if report.Failed == 0 || block.BlockNum < report.FirstBlock {
+						report.FirstBlock, report.FirstError = block.BlockNum, err
+					}
+					report.Failed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	feed(blocks)
+	close(blocks)
+	wg.Wait()
+
+	if lowest > 0 {
+		if err := r.PluginService.Rollback(lowest - 1); err != nil {
+			return err
+		}
+	}
+	return redecodeSummary(reports)
+}
+
+// redecodeSummary logs the outcome per spec version, failures point at a type registry to fix
+func redecodeSummary(reports map[int]*specDecodeReport) error {
+	var specs []int
+	for spec := range reports {
+		specs = append(specs, spec)
+	}
+	sort.Ints(specs)
+
+	var total, failed int
+	for _, spec := range specs {
+		report := reports[spec]
+		total += report.Blocks
+		failed += report.Failed
+		if report.Failed == 0 {
+			log.Info("Redecode spec ", spec, ": ", report.Blocks, " blocks ok")
+			continue
+		}
+		log.Warn("Redecode spec ", spec, ": ", report.Failed, "/", report.Blocks, " blocks failed, first ",
+			report.FirstBlock, " ", report.FirstError)
+	}
+	log.Info("Redecode finished, ", total-failed, "/", total, " blocks re-decoded")
+	if failed > 0 {
+		return fmt.Errorf("%d blocks failed to decode, see the per spec report above", failed)
+	}
+	return nil
+}
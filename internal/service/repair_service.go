@@ -2,24 +2,20 @@ package service
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"strconv"
-	"strings"
 	"sync"
 	"syscall"
 
+	"github.com/CoolBitX-Technology/subscan/internal/repository"
 	"github.com/CoolBitX-Technology/subscan/model"
-	"github.com/CoolBitX-Technology/subscan/plugins"
 	"github.com/CoolBitX-Technology/subscan/util"
-	"github.com/itering/substrate-api-rpc/rpc"
-	"github.com/itering/substrate-api-rpc/websocket"
 	ws "github.com/itering/substrate-api-rpc/websocket"
 	"github.com/panjf2000/ants"
 	"github.com/prometheus/common/log"
-	"github.com/shopspring/decimal"
 )
 
 const pSize = 100 // process rows per time
@@ -33,13 +29,15 @@ type repairService struct {
 	RuntimeService  model.RuntimeService
 	BlockService    model.BlockService
 	PluginService   model.PluginService
-	DbStorage       *DbStorage
+	DbStorage       *repository.DbStorage
+	ChainSource     model.ChainSource
 }
 
 type RepairConfig struct {
 	SqlRepository   model.SqlRepository
 	RedisRepository model.RedisRepository
-	DbStorage       *DbStorage
+	DbStorage       *repository.DbStorage
+	ChainSource     model.ChainSource
 }
 
 func NewRepairService(c *RepairConfig, done chan struct{}, cs model.CommonService, r model.RuntimeService, b model.BlockService, p model.PluginService) model.RepairService {
@@ -51,6 +49,7 @@ func NewRepairService(c *RepairConfig, done chan struct{}, cs model.CommonServic
 		RuntimeService:  r,
 		BlockService:    b,
 		PluginService:   p,
+		ChainSource:     c.ChainSource,
 	}
 }
 
@@ -63,6 +62,7 @@ func (s *repairService) initRepairService(done chan struct{}) *repairService {
 		RuntimeService:  s.RuntimeService,
 		BlockService:    s.BlockService,
 		PluginService:   s.PluginService,
+		ChainSource:     s.ChainSource,
 	}
 }
 
@@ -169,7 +169,7 @@ func (s *repairService) RepairBlocksBySet(bs []string) {
 	p, _ := ants.NewPoolWithFunc(16, func(i interface{}) {
 		blockFinalized := i.(model.BlockFinalized)
 		func(bf model.BlockFinalized) {
-			if err := s.fillBlockDataBySet(nil, bf.BlockNum, bs); err != nil {
+			if err := s.fillBlockDataBySet(bf.BlockNum, bs); err != nil {
 				log.Error("fillBlockData get error ", err)
 			} else {
 				s.CommonService.SetHeartBeat(fmt.Sprintf("%s:heartBeat:%s", util.NetworkNode, "substrate"))
@@ -197,7 +197,7 @@ func (s *repairService) RepairBlocks(bs *model.IntBoolMap) { // this method shou
 	p, _ := ants.NewPoolWithFunc(16, func(i interface{}) {
 		blockFinalized := i.(model.BlockFinalized)
 		func(bf model.BlockFinalized) {
-			if err := s.fillBlockData(nil, bf.BlockNum, bs); err != nil {
+			if err := s.fillBlockData(bf.BlockNum, bs); err != nil {
 				log.Error("fillBlockData get error ", err)
 			} else {
 				s.CommonService.SetHeartBeat(fmt.Sprintf("%s:heartBeat:%s", util.NetworkNode, "substrate"))
@@ -225,6 +225,7 @@ func (s *repairService) RepairPlugins(bs *model.IntBoolMap) {
 	var wg sync.WaitGroup
 
 	p, _ := ants.NewPoolWithFunc(8, func(i interface{}) {
+		defer wg.Done()
 		blockNum := i.(model.BlockFinalized)
 		func(bf model.BlockFinalized) {
 			if err := s.fillPluginData(bf.BlockNum, bs); err != nil {
@@ -233,8 +234,8 @@ func (s *repairService) RepairPlugins(bs *model.IntBoolMap) {
 				s.CommonService.SetHeartBeat(fmt.Sprintf("%s:heartBeat:%s", util.NetworkNode, "substrate"))
 			}
 		}(blockNum)
-		wg.Done()
 	}, ants.WithOptions(ants.Options{PanicHandler: func(c interface{}) {
+		log.Error("RepairPlugins panic ", c, "\n", string(debug.Stack()))
 	}}))
 
 	defer p.Release()
@@ -248,69 +249,19 @@ func (s *repairService) RepairPlugins(bs *model.IntBoolMap) {
 }
 
 func (s *repairService) PluginRegister() {
-	log.Info("--- PluginRegister ---")
-	for name, plugin := range plugins.RegisteredPlugins {
-		log.Info("name: ", name)
-		db := s.DbStorage
-		db.Prefix = name
-		plugin.InitDao(db)
-		for _, moduleId := range plugin.SubscribeExtrinsic() {
-			subscribeExtrinsic[moduleId] = append(subscribeExtrinsic[moduleId], plugin)
-		}
-		for _, moduleId := range plugin.SubscribeEvent() {
-			subscribeEvent[moduleId] = append(subscribeEvent[moduleId], plugin)
-		}
-	}
+	registerPlugins(s.DbStorage, s.SqlRepository)
 }
 
-func (s *repairService) fillBlockDataBySet(conn websocket.WsConn, blockNum int, bs []string) (err error) {
+func (s *repairService) fillBlockDataBySet(blockNum int, bs []string) (err error) {
 	// block := s.SqlRepository.GetBlockByNum(blockNum)
 	// if block != nil && block.Finalized && block.ExtrinsicsCount != 0 {
 	// 	bs.Store(blockNum, true)
 	// 	return nil
 	// }
 
-	v := &rpc.JsonRpcResult{}
-
-	err = ws.SendWsRequest(conn, v, rpc.ChainGetBlockHash(wsBlockHash, blockNum))
-	blockHash, err := v.ToString()
-	if err != nil || blockHash == "" {
-		return fmt.Errorf("ChainGetBlockHash get error %v", err)
-	}
-
-	err = ws.SendWsRequest(conn, v, rpc.ChainGetBlock(wsBlock, blockHash))
-	if err != nil {
-		return fmt.Errorf("ChainGetBlock get error %v", err)
-	}
-	rpcBlock := v.ToBlock()
-
-	err = ws.SendWsRequest(conn, v, rpc.StateGetStorage(wsEvent, util.EventStorageKey, blockHash))
-	if err != nil {
-		return fmt.Errorf("StateGetStorage get error %v", err)
-	}
-	event, _ := v.ToString()
-
-	err = ws.SendWsRequest(conn, v, rpc.ChainGetRuntimeVersion(wsSpec, blockHash))
-
+	blockHash, rpcBlock, event, specVersion, err := fetchChainBlock(s.ChainSource, s.CommonService, s.RuntimeService, blockNum)
 	if err != nil {
-		return fmt.Errorf("ChainGetRuntimeVersion get error %v", err)
-	}
-
-	var specVersion int
-
-	if r := v.ToRuntimeVersion(); r == nil {
-		specVersion = s.CommonService.GetCurrentRuntimeSpecVersion(blockNum)
-	} else {
-		specVersion = r.SpecVersion
-		_ = s.RuntimeService.RegRuntimeVersion(r.ImplName, specVersion, blockHash)
-	}
-
-	if specVersion > util.CurrentRuntimeSpecVersion {
-		util.CurrentRuntimeSpecVersion = specVersion
-	}
-
-	if rpcBlock == nil || specVersion == -1 {
-		return errors.New("nil block data")
+		return err
 	}
 
 	// // refresh finalized info for update
@@ -330,88 +281,46 @@ func (s *repairService) fillBlockDataBySet(conn websocket.WsConn, blockNum int,
 	// 	return
 	// }
 
-	// for Create
-	if err = s.BlockService.CreateChainBlock(conn, blockHash, &rpcBlock.Block, event, specVersion, true); err == nil {
+	// for Create, a block failing to decode is quarantined in decode_failures and counts as repaired
+	if err = s.BlockService.CreateChainBlock(blockHash, rpcBlock, event, specVersion, true); err == nil {
 		s.RedisRepository.AddRepairedBlock(context.TODO(), blockNum)
 	} else {
 		log.Error("Create chain block error ", err)
-		if strings.Contains(err.Error(), "Recovering from panic in DecodeExtrinsic") {
-			s.RedisRepository.AddRepairedBlock(context.TODO(), blockNum)
-			log.Error("Recovering from panic in DecodeExtrinsic at block: ", blockNum)
-		}
 	}
 	return
 }
 
-func (s *repairService) fillBlockData(conn websocket.WsConn, blockNum int, bs *model.IntBoolMap) (err error) {
+func (s *repairService) fillBlockData(blockNum int, bs *model.IntBoolMap) (err error) {
 	block := s.SqlRepository.GetBlockByNum(blockNum)
 	if block != nil && block.Finalized && block.ExtrinsicsCount != 0 {
 		bs.Store(blockNum, true)
 		return nil
 	}
 
-	v := &rpc.JsonRpcResult{}
-
-	err = ws.SendWsRequest(conn, v, rpc.ChainGetBlockHash(wsBlockHash, blockNum))
-	blockHash, err := v.ToString()
-	if err != nil || blockHash == "" {
-		return fmt.Errorf("ChainGetBlockHash get error %v", err)
-	}
-
-	err = ws.SendWsRequest(conn, v, rpc.ChainGetBlock(wsBlock, blockHash))
+	blockHash, rpcBlock, event, specVersion, err := fetchChainBlock(s.ChainSource, s.CommonService, s.RuntimeService, blockNum)
 	if err != nil {
-		return fmt.Errorf("ChainGetBlock get error %v", err)
-	}
-	rpcBlock := v.ToBlock()
-
-	err = ws.SendWsRequest(conn, v, rpc.StateGetStorage(wsEvent, util.EventStorageKey, blockHash))
-	if err != nil {
-		return fmt.Errorf("StateGetStorage get error %v", err)
-	}
-	event, _ := v.ToString()
-
-	err = ws.SendWsRequest(conn, v, rpc.ChainGetRuntimeVersion(wsSpec, blockHash))
-
-	if err != nil {
-		return fmt.Errorf("ChainGetRuntimeVersion get error %v", err)
-	}
-
-	var specVersion int
-
-	if r := v.ToRuntimeVersion(); r == nil {
-		specVersion = s.CommonService.GetCurrentRuntimeSpecVersion(blockNum)
-	} else {
-		specVersion = r.SpecVersion
-		_ = s.RuntimeService.RegRuntimeVersion(r.ImplName, specVersion, blockHash)
-	}
-
-	if specVersion > util.CurrentRuntimeSpecVersion {
-		util.CurrentRuntimeSpecVersion = specVersion
-	}
-
-	if rpcBlock == nil || specVersion == -1 {
-		return errors.New("nil block data")
+		return err
 	}
 
 	// refresh finalized info for update
 	if block != nil {
 		// Confirm data, only set block Finalized, refresh all block data
-		block.ExtrinsicsRoot = rpcBlock.Block.Header.ExtrinsicsRoot
+		block.ExtrinsicsRoot = rpcBlock.Header.ExtrinsicsRoot
 		block.Hash = blockHash
-		block.ParentHash = rpcBlock.Block.Header.ParentHash
-		block.StateRoot = rpcBlock.Block.Header.StateRoot
-		block.Extrinsics = util.ToString(rpcBlock.Block.Extrinsics)
-		block.Logs = util.ToString(rpcBlock.Block.Header.Digest.Logs)
+		block.ParentHash = rpcBlock.Header.ParentHash
+		block.StateRoot = rpcBlock.Header.StateRoot
+		block.Extrinsics = util.ToString(rpcBlock.Extrinsics)
+		block.Logs = util.ToString(rpcBlock.Header.Digest.Logs)
 		block.Event = event
 		block.CodecError = false
-		if err = s.BlockService.UpdateBlockData(conn, block, true); err == nil {
+		if err = s.BlockService.UpdateBlockData(block, true); err == nil {
 			bs.Store(blockNum, true)
 		}
 		return
 	}
 
 	// for Create
-	if err = s.BlockService.CreateChainBlock(conn, blockHash, &rpcBlock.Block, event, specVersion, true); err == nil {
+	if err = s.BlockService.CreateChainBlock(blockHash, rpcBlock, event, specVersion, true); err == nil {
 		bs.Store(blockNum, true)
 	} else {
 		log.Error("Create chain block error ", err)
@@ -420,58 +329,15 @@ func (s *repairService) fillBlockData(conn websocket.WsConn, blockNum int, bs *m
 
 }
 
-func (s *repairService) fillPluginData(blockNum int, bs *model.IntBoolMap) (err error) {
-	var block *model.ChainBlock
-	var extrinsics []model.ChainExtrinsic
-	var events []model.ChainEvent
-
-	if block = s.SqlRepository.GetBlockByNum(blockNum); block == nil {
-		return nil
-	}
-	if extrinsics = s.SqlRepository.GetRawExtrinsicsByBlockNum(blockNum); extrinsics == nil {
-		return nil
-	}
-	if events = s.SqlRepository.GetRawEventByBlockNum(blockNum); events == nil {
-		return nil
-	}
-
-	eventMap := make(map[string][]model.ChainEvent)
-	for _, e := range events {
-		extrinsicIndex := fmt.Sprintf("%d-%d", blockNum, e.ExtrinsicIdx)
-		eventMap[extrinsicIndex] = append(eventMap[extrinsicIndex], model.ChainEvent{
-			BlockNum:      e.BlockNum,
-			ExtrinsicIdx:  e.ExtrinsicIdx,
-			ModuleId:      e.ModuleId,
-			EventId:       e.EventId,
-			Params:        e.Params,
-			ExtrinsicHash: e.ExtrinsicHash,
-			EventIdx:      e.EventIdx,
-		})
-	}
-
-	feeMap := make(map[string]decimal.Decimal)
-	for _, extrinsic := range extrinsics {
-		if extrinsic.ExtrinsicHash != "" {
-			feeMap[extrinsic.ExtrinsicIndex] = extrinsic.Fee
-		}
-		err = s.PluginService.EmitExtrinsic(block, &extrinsic, eventMap)
-		if err != nil {
-			bs.Store(blockNum, false)
-			return err
-		}
-	}
-	for _, event := range events {
-		err = s.PluginService.EmitEvent(block, &event, feeMap)
-		if err != nil {
-			log.Info(err)
-			bs.Store(blockNum, false)
-			return err
-		}
+func (s *repairService) fillPluginData(blockNum int, bs *model.IntBoolMap) error {
+	ok, err := s.PluginService.FeedBlock(blockNum)
+	if err != nil {
+		log.Info(err)
+		bs.Store(blockNum, false)
+		return err
 	}
-
-	if err == nil {
+	if ok {
 		bs.Store(blockNum, true)
 	}
-
-	return err
+	return nil
 }
@@ -1,32 +1,46 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/common/log"
 
 	"github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/util"
 	"github.com/itering/substrate-api-rpc/metadata"
-	"github.com/itering/substrate-api-rpc/rpc"
 )
 
 type runtimeService struct {
 	SqlRepository model.SqlRepository
+	ChainSource   model.ChainSource
 }
 
 type RuntimeConfig struct {
 	SqlRepository model.SqlRepository
+	ChainSource   model.ChainSource
 }
 
 func NewRunTimeService(c *RuntimeConfig) model.RuntimeService {
 	return &runtimeService{
 		SqlRepository: c.SqlRepository,
+		ChainSource:   c.ChainSource,
 	}
 }
 
 var (
 	runtimeSpecs []int
+	// runtimeLock serializes registration between the runtime version subscription and the block workers
+	runtimeLock sync.Mutex
+)
+
+const (
+	metadataRetryTimes       = 10
+	metadataRetryInterval    = time.Second
+	metadataRetryMaxInterval = time.Minute
 )
 
 func (r *runtimeService) RegCodecMetadata(hash ...string) (coded string, err error) {
@@ -39,7 +53,11 @@ func (r *runtimeService) RegCodecMetadata(hash ...string) (coded string, err err
 	// 	fmt.Fprintf(os.Stderr, "Retrying GetMetadataByHash in %v\n", 10*time.Second)
 	// 	time.Sleep(10 * time.Second)
 	// }
-	coded, err = rpc.GetMetadataByHash(nil, hash...)
+	var blockHash string
+	if len(hash) > 0 {
+		blockHash = hash[0]
+	}
+	coded, err = r.ChainSource.GetMetadata(blockHash)
 	if err != nil {
 		return "", err
 	}
@@ -82,18 +100,31 @@ func (r *runtimeService) SubstrateRuntimeInfo(spec int) *metadata.Instant {
 	return runtime
 }
 
+// RegRuntimeVersion stores spec with its metadata once, fetching the metadata is retried with backoff
+// and a spec whose metadata never arrived is tried again on the next call
 func (r *runtimeService) RegRuntimeVersion(name string, spec int, hash ...string) error {
+	runtimeLock.Lock()
+	defer runtimeLock.Unlock()
 	if util.IntInSlice(spec, runtimeSpecs) {
 		return nil
 	}
-	if affected := r.SqlRepository.CreateRuntimeVersion(name, spec); affected > 0 {
-		if coded, err := r.RegCodecMetadata(hash...); coded != "" && err == nil {
-			runtime := metadata.RegNewMetadataType(spec, coded)
-			r.SetRuntimeData(spec, runtime, coded)
-		} else {
-			log.Error(err)
-			panic("get runtime metadata error")
+	r.SqlRepository.CreateRuntimeVersion(name, spec)
+	if raw := r.SqlRepository.RuntimeVersionRaw(spec); raw == nil || raw.Raw == "" {
+		var coded string
+		err := util.Retry(metadataRetryTimes, metadataRetryInterval, metadataRetryMaxInterval, func() (err error) {
+			if coded, err = r.RegCodecMetadata(hash...); err == nil && coded == "" {
+				err = errors.New("empty metadata")
+			}
+			if err != nil {
+				log.Warn("Get runtime metadata of spec ", spec, " error ", err)
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("get runtime metadata of spec %d error: %v", spec, err)
 		}
+		r.SetRuntimeData(spec, metadata.RegNewMetadataType(spec, coded), coded)
+		log.Info("Registered runtime ", name, " spec ", spec)
 	}
 	runtimeSpecs = append(runtimeSpecs, spec)
 	return nil
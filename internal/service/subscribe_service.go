@@ -12,6 +12,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/CoolBitX-Technology/subscan/internal/rpcpool"
 	"github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/util"
 	"github.com/gorilla/websocket"
@@ -27,6 +28,7 @@ const (
 	ChainNewHead                        = "chain_newHead"
 	ChainFinalizedHead                  = "chain_finalizedHead"
 	StateStorage                        = "state_storage"
+	StateRuntimeVersion                 = "state_runtimeVersion"
 	BlockTime                           = 6
 )
 
@@ -39,6 +41,7 @@ const (
 	runtimeVersion = iota + 1
 	newHeader
 	finalizeHeader
+	subscribeRuntimeVersion
 )
 
 type subscription struct {
@@ -55,11 +58,13 @@ type subscribeService struct {
 	CommonService   model.CommonService
 	RuntimeService  model.RuntimeService
 	BlockService    model.BlockService
+	ChainSource     model.ChainSource
 }
 
 type SubscribeConfig struct {
 	RedisRepository model.RedisRepository
 	SqlRepository   model.SqlRepository
+	ChainSource     model.ChainSource
 }
 
 func NewSubscribeService(c *SubscribeConfig, done chan struct{}, cs model.CommonService, r model.RuntimeService, b model.BlockService) model.SubscribeService {
@@ -72,6 +77,7 @@ func NewSubscribeService(c *SubscribeConfig, done chan struct{}, cs model.Common
 		CommonService:   cs,
 		RuntimeService:  r,
 		BlockService:    b,
+		ChainSource:     c.ChainSource,
 	}
 }
 
@@ -85,6 +91,7 @@ func (s *subscribeService) initSubscribeService(done chan struct{}) *subscribeSe
 		CommonService:   s.CommonService,
 		RuntimeService:  s.RuntimeService,
 		BlockService:    s.BlockService,
+		ChainSource:     s.ChainSource,
 	}
 }
 
@@ -115,15 +122,8 @@ func (s *subscribeService) Subscribe(conn ws.WsConn, interrupt chan os.Signal) {
 		}
 	}()
 
-	if err = conn.WriteMessage(websocket.TextMessage, rpc.ChainGetRuntimeVersion(runtimeVersion)); err != nil {
-		log.Info("write: ", err)
-	}
-	if err = conn.WriteMessage(websocket.TextMessage, rpc.ChainSubscribeNewHead(newHeader)); err != nil {
-		log.Info("write: ", err)
-	}
-	if err = conn.WriteMessage(websocket.TextMessage, rpc.ChainSubscribeFinalizedHeads(finalizeHeader)); err != nil {
-		log.Info("write: ", err)
-	}
+	endpoint := rpcpool.Active()
+	subscribeTopics(conn)
 
 	ticker := time.NewTicker(time.Second * 3)
 	defer ticker.Stop()
@@ -133,8 +133,14 @@ func (s *subscribeService) Subscribe(conn ws.WsConn, interrupt chan os.Signal) {
 		case <-done:
 			return
 		case <-ticker.C:
+			if active := rpcpool.Active(); active != endpoint {
+				log.Warn("--- Switch Substrate WebSocket Connection to ", active, " ---")
+				endpoint = switchEndpoint(conn, active)
+				continue
+			}
 			if err := conn.WriteMessage(websocket.TextMessage, rpc.SystemHealth(rand.Intn(100)+finalizeHeader)); err != nil {
 				log.Error("SystemHealth get error: ", err)
+				rpcpool.Observe(endpoint, 0, 0, err)
 				if !conn.IsConnected() {
 					log.Info("--- SetUp Substrate WebSocket Connection ---")
 					conn.CloseAndReconnect()
@@ -153,6 +159,31 @@ func (s *subscribeService) Subscribe(conn ws.WsConn, interrupt chan os.Signal) {
 	}
 }
 
+// subscribeTopics sends the runtime version request and the head subscriptions
+func subscribeTopics(conn ws.WsConn) {
+	if err := conn.WriteMessage(websocket.TextMessage, rpc.ChainGetRuntimeVersion(runtimeVersion)); err != nil {
+		log.Info("write: ", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, rpc.ChainSubscribeNewHead(newHeader)); err != nil {
+		log.Info("write: ", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, rpc.ChainSubscribeFinalizedHeads(finalizeHeader)); err != nil {
+		log.Info("write: ", err)
+	}
+	subscribeRuntime, _ := json.Marshal(rpc.Param{Id: subscribeRuntimeVersion, Method: "state_subscribeRuntimeVersion", Params: []string{}, JsonRpc: "2.0"})
+	if err := conn.WriteMessage(websocket.TextMessage, subscribeRuntime); err != nil {
+		log.Info("write: ", err)
+	}
+}
+
+// switchEndpoint re-dials conn to the endpoint the pool failed over to and subscribes again
+func switchEndpoint(conn ws.WsConn, endpoint string) string {
+	conn.Close()
+	conn.Dial(endpoint, nil)
+	subscribeTopics(conn)
+	return endpoint
+}
+
 func (s *subscribeService) Parser(message []byte) (err error) {
 	upgradeHealth := func(topic string) {
 		for index, subscript := range subscriptionIds {
@@ -169,10 +200,12 @@ func (s *subscribeService) Parser(message []byte) (err error) {
 
 	switch j.Id {
 	case runtimeVersion:
-		r := j.ToRuntimeVersion()
-		_ = s.RuntimeService.RegRuntimeVersion(r.ImplName, r.SpecVersion)
-		_ = s.updateChainMetadata(map[string]interface{}{"implName": r.ImplName, "specVersion": r.SpecVersion})
-		util.CurrentRuntimeSpecVersion = r.SpecVersion
+		if j.Result == nil {
+			return
+		}
+		if r := j.ToRuntimeVersion(); r != nil {
+			go s.upgradeRuntime(r)
+		}
 		return
 	}
 
@@ -181,6 +214,12 @@ func (s *subscribeService) Parser(message []byte) (err error) {
 		r := j.ToNewHead()
 		_ = s.updateChainMetadata(map[string]interface{}{"blockNum": util.HexToNumStr(r.Number)})
 		upgradeHealth(j.Method)
+		go func() {
+			s.newHead <- true
+			onceNewHead.Do(func() {
+				go s.SubscribeFetchBestBlock()
+			})
+		}()
 	case ChainFinalizedHead:
 		r := j.ToNewHead()
 		_ = s.updateChainMetadata(map[string]interface{}{"finalized_blockNum": util.HexToNumStr(r.Number)})
@@ -193,12 +232,35 @@ func (s *subscribeService) Parser(message []byte) (err error) {
 		}()
 	case StateStorage:
 		upgradeHealth(j.Method)
+	case StateRuntimeVersion:
+		if j.Params == nil {
+			return
+		}
+		var r rpc.RuntimeVersion
+		if util.UnmarshalAny(&r, j.Params.Result); r.SpecVersion == 0 {
+			return
+		}
+		go s.upgradeRuntime(&r)
 	default:
 		return
 	}
 	return
 }
 
+// upgradeRuntime registers and persists the metadata of a new spec as soon as the node reports it, the
+// block workers wait for the registration before decoding a block of that spec
+func (s *subscribeService) upgradeRuntime(r *rpc.RuntimeVersion) {
+	if err := s.RuntimeService.RegRuntimeVersion(r.ImplName, r.SpecVersion); err != nil {
+		log.Error("Register runtime version error ", err)
+		return
+	}
+	_ = s.updateChainMetadata(map[string]interface{}{"implName": r.ImplName, "specVersion": r.SpecVersion})
+	if r.SpecVersion > util.CurrentRuntimeSpecVersion {
+		log.Info("Runtime upgraded to spec ", r.SpecVersion)
+		util.CurrentRuntimeSpecVersion = r.SpecVersion
+	}
+}
+
 func (s *subscribeService) SubscribeFetchBlock() {
 	var wg sync.WaitGroup
 	ctx := context.TODO()
@@ -206,7 +268,7 @@ func (s *subscribeService) SubscribeFetchBlock() {
 	p, _ := ants.NewPoolWithFunc(10, func(i interface{}) {
 		blockNum := i.(model.BlockFinalized)
 		func(bf model.BlockFinalized) {
-			if err := s.fillBlockData(nil, bf.BlockNum, bf.Finalized); err != nil {
+			if err := s.fillBlockData(bf.BlockNum, bf.Finalized); err != nil {
 				log.Error("fillBlockData get error ", err)
 			} else {
 				s.CommonService.SetHeartBeat(fmt.Sprintf("%s:heartBeat:%s", util.NetworkNode, "substrate"))
@@ -242,96 +304,200 @@ func (s *subscribeService) SubscribeFetchBlock() {
 	}
 }
 
-const (
-	wsBlockHash = iota + 1
-	wsBlock
-	wsEvent
-	wsSpec
-)
-
-func (s *subscribeService) fillBlockData(conn ws.WsConn, blockNum int, finalized bool) (err error) {
+func (s *subscribeService) fillBlockData(blockNum int, finalized bool) (err error) {
 	block := s.SqlRepository.GetBlockByNum(blockNum)
 	if block != nil && block.Finalized && !block.CodecError && block.ExtrinsicsCount != 0 {
 		return nil
 	}
 
-	v := &rpc.JsonRpcResult{}
-
-	// Block Hash
-	if err = ws.SendWsRequest(conn, v, rpc.ChainGetBlockHash(wsBlockHash, blockNum)); err != nil {
-		return fmt.Errorf("websocket send error: %v", err)
+	blockHash, rpcBlock, event, specVersion, err := s.fetchBlock(blockNum)
+	if err != nil {
+		return err
 	}
 
-	blockHash, err := v.ToString()
-	if err != nil || blockHash == "" {
-		return fmt.Errorf("ChainGetBlockHash get error %v", err)
+	var setFinalized = func() {
+		if finalized {
+			_ = s.RedisRepository.SaveFillAlreadyFinalizedBlockNum(context.TODO(), blockNum)
+		}
 	}
-	log.Info("Block num: ", blockNum, " hash: ", blockHash)
-
-	// block
-	if err = ws.SendWsRequest(conn, v, rpc.ChainGetBlock(wsBlock, blockHash)); err != nil {
-		return fmt.Errorf("websocket send error: %v", err)
+	// the stored block is the canonical one, confirming it only sets it finalized
+	if block != nil && block.Hash == blockHash {
+		if finalized {
+			s.SqlRepository.SetBlockFinalized(block)
+			setFinalized()
+		}
+		return nil
 	}
 
-	rpcBlock := v.ToBlock()
-	// event
-	if err = ws.SendWsRequest(conn, v, rpc.StateGetStorage(wsEvent, util.EventStorageKey, blockHash)); err != nil {
-		return fmt.Errorf("websocket send error: %v", err)
+	// the stored block lost a fork, drop it and index the canonical one instead
+	if block != nil {
+		log.Warn("Block ", blockNum, " hash changed from ", block.Hash, " to ", blockHash)
+		if err = s.BlockService.RollbackBlock(blockNum); err != nil {
+			return err
+		}
 	}
 
-	event, _ := v.ToString()
+	// for Create
+	if err = s.BlockService.CreateChainBlock(blockHash, rpcBlock, event, specVersion, finalized); err == nil {
+		_ = s.RedisRepository.SaveFillAlreadyBlockNum(context.TODO(), blockNum)
+		setFinalized()
+	} else {
+		log.Error("Create chain block error ", err)
+	}
+	return
+}
 
-	// runtime
-	if err = ws.SendWsRequest(conn, v, rpc.ChainGetRuntimeVersion(wsSpec, blockHash)); err != nil {
-		return fmt.Errorf("websocket send error: %v", err)
+// fetchBlock reads block hash, block, event storage and spec version of blockNum from the chain source
+func (s *subscribeService) fetchBlock(blockNum int) (blockHash string, block *rpc.Block, event string, specVersion int, err error) {
+	return fetchChainBlock(s.ChainSource, s.CommonService, s.RuntimeService, blockNum)
+}
 
+func fetchChainBlock(source model.ChainSource, cs model.CommonService, rs model.RuntimeService, blockNum int) (blockHash string, block *rpc.Block, event string, specVersion int, err error) {
+	if blockHash, err = source.GetBlockHash(blockNum); err != nil {
+		return "", nil, "", 0, err
 	}
+	log.Info("Block num: ", blockNum, " hash: ", blockHash)
 
-	var specVersion int
+	if block, err = source.GetBlock(blockHash); err != nil {
+		return "", nil, "", 0, err
+	}
+	if event, err = source.GetEventStorage(blockHash); err != nil {
+		return "", nil, "", 0, err
+	}
 
-	if r := v.ToRuntimeVersion(); r == nil {
-		specVersion = s.CommonService.GetCurrentRuntimeSpecVersion(blockNum)
+	if r, _ := source.GetRuntimeVersion(blockHash); r == nil {
+		specVersion = cs.GetCurrentRuntimeSpecVersion(blockNum)
 	} else {
 		specVersion = r.SpecVersion
-		_ = s.RuntimeService.RegRuntimeVersion(r.ImplName, specVersion, blockHash)
+		if err = rs.RegRuntimeVersion(r.ImplName, specVersion, blockHash); err != nil {
+			return "", nil, "", 0, err
+		}
 	}
 
 	if specVersion > util.CurrentRuntimeSpecVersion {
 		util.CurrentRuntimeSpecVersion = specVersion
 	}
 
-	if rpcBlock == nil || specVersion == -1 {
-		return errors.New("nil block data")
+	if specVersion == -1 {
+		return "", nil, "", 0, errors.New("nil block data")
 	}
+	return
+}
 
-	var setFinalized = func() {
-		if finalized {
-			_ = s.RedisRepository.SaveFillAlreadyFinalizedBlockNum(context.TODO(), blockNum)
+// SubscribeFetchBestBlock indexes the not yet finalized blocks between the finalized head
+// and the best head, rolling back orphaned blocks when the chain reorganizes
+func (s *subscribeService) SubscribeFetchBestBlock() {
+	ctx := context.TODO()
+	for {
+		select {
+		case <-s.newHead:
+			best, err := s.RedisRepository.GetBestBlockNum(ctx)
+			if err != nil || best == 0 {
+				continue
+			}
+			final, err := s.RedisRepository.GetFinalizedBlockNum(ctx)
+			if err != nil || final == 0 {
+				continue
+			}
+
+			lastNum, _ := s.RedisRepository.GetFillBestBlockNum(ctx)
+			startBlock := lastNum + 1
+			if startBlock > int(best) {
+				// the new head is not higher than what we have, it may be a sibling
+				startBlock = int(best)
+			}
+			if startBlock <= int(final) {
+				startBlock = int(final) + 1
+			}
+
+			for i := startBlock; i <= int(best); i++ {
+				if err := s.fillBestBlock(i); err != nil {
+					log.Error("fillBestBlock get error ", err)
+					break
+				}
+			}
+		case <-s.done:
+			return
 		}
 	}
-	// refresh finalized info for update
+}
+
+// fillBestBlock indexes a not yet finalized block, if its parent does not match
+// the stored block at blockNum-1 the orphaned branch is rolled back first
+func (s *subscribeService) fillBestBlock(blockNum int) (err error) {
+	blockHash, rpcBlock, event, specVersion, err := s.fetchBlock(blockNum)
+	if err != nil {
+		return err
+	}
+
+	block := s.SqlRepository.GetBlockByNum(blockNum)
+	if block != nil && block.Hash == blockHash {
+		return nil
+	}
+
+	if err = s.checkReorg(blockNum, rpcBlock.Header.ParentHash); err != nil {
+		return err
+	}
+
 	if block != nil {
-		// Confirm data, only set block Finalized, refresh all block data
-		block.ExtrinsicsRoot = rpcBlock.Block.Header.ExtrinsicsRoot
-		block.Hash = blockHash
-		block.ParentHash = rpcBlock.Block.Header.ParentHash
-		block.StateRoot = rpcBlock.Block.Header.StateRoot
-		block.Extrinsics = util.ToString(rpcBlock.Block.Extrinsics)
-		block.Logs = util.ToString(rpcBlock.Block.Header.Digest.Logs)
-		block.Event = event
-		block.CodecError = false
-		_ = s.BlockService.UpdateBlockData(conn, block, finalized)
-		return
+		if err = s.BlockService.RollbackBlock(blockNum); err != nil {
+			return err
+		}
 	}
 
-	// for Create
-	if err = s.BlockService.CreateChainBlock(conn, blockHash, &rpcBlock.Block, event, specVersion, finalized); err == nil {
-		_ = s.RedisRepository.SaveFillAlreadyBlockNum(context.TODO(), blockNum)
-		setFinalized()
-	} else {
-		log.Error("Create chain block error ", err)
+	if err = s.BlockService.CreateChainBlock(blockHash, rpcBlock, event, specVersion, false); err != nil {
+		return err
 	}
-	return
+	_ = s.RedisRepository.SaveFillAlreadyBlockNum(context.TODO(), blockNum)
+	return nil
+}
+
+// checkReorg walks back from blockNum-1 until the stored block matches the canonical chain,
+// rolling back every orphaned block on the way and re-indexing the canonical ones
+func (s *subscribeService) checkReorg(blockNum int, parentHash string) error {
+	parent := s.SqlRepository.GetBlockByNum(blockNum - 1)
+	if parent == nil || parent.Hash == parentHash {
+		return nil
+	}
+	if parent.Finalized {
+		return fmt.Errorf("block %d parent hash %s conflicts with finalized block %s", blockNum, parentHash, parent.Hash)
+	}
+
+	log.Warn("Reorg detected at block ", blockNum-1, " stored: ", parent.Hash, " canonical: ", parentHash)
+
+	forkPoint := blockNum - 1
+	for num := blockNum - 1; num > 0; num-- {
+		stored := s.SqlRepository.GetBlockByNum(num)
+		if stored == nil || stored.Finalized {
+			break
+		}
+		canonicalHash := parentHash
+		if num != blockNum-1 {
+			var err error
+			if canonicalHash, err = s.ChainSource.GetBlockHash(num); err != nil {
+				return err
+			}
+		}
+		if stored.Hash == canonicalHash {
+			break
+		}
+		if err := s.BlockService.RollbackBlock(num); err != nil {
+			return err
+		}
+		forkPoint = num
+	}
+
+	for num := forkPoint; num < blockNum; num++ {
+		blockHash, rpcBlock, event, specVersion, err := s.fetchBlock(num)
+		if err != nil {
+			return err
+		}
+		if err = s.BlockService.CreateChainBlock(blockHash, rpcBlock, event, specVersion, false); err != nil {
+			return err
+		}
+	}
+	log.Info("Reorg resolved, re-indexed blocks ", forkPoint, " - ", blockNum-1)
+	return nil
 }
 
 func (s *subscribeService) updateChainMetadata(metadata map[string]interface{}) (err error) {
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/CoolBitX-Technology/subscan/internal/source"
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/itering/substrate-api-rpc/rpc"
+)
+
+// reorgChain stores blocks and the extrinsic, event and log rows of each one, tagged with the hash of the
+// block they were indexed from
+type reorgChain struct {
+	model.SqlRepository
+	blocks     map[int]*model.ChainBlock
+	rows       map[string]map[int]string
+	rolledBack []int
+}
+
+func newReorgChain() *reorgChain {
+	return &reorgChain{
+		blocks: make(map[int]*model.ChainBlock),
+		rows:   map[string]map[int]string{"extrinsic": {}, "event": {}, "log": {}},
+	}
+}
+
+func (c *reorgChain) store(blockNum int, hash, parentHash string, finalized bool) {
+	c.blocks[blockNum] = &model.ChainBlock{BlockNum: blockNum, Hash: hash, ParentHash: parentHash, Finalized: finalized, ExtrinsicsCount: 1}
+	for _, rows := range c.rows {
+		rows[blockNum] = hash
+	}
+}
+
+func (c *reorgChain) GetBlockByNum(blockNum int) *model.ChainBlock {
+	if b, ok := c.blocks[blockNum]; ok {
+		block := *b
+		return &block
+	}
+	return nil
+}
+
+func (c *reorgChain) SetBlockFinalized(block *model.ChainBlock) {
+	c.blocks[block.BlockNum].Finalized = true
+}
+
+func (c *reorgChain) DbBegin() *model.GormDB      { return &model.GormDB{} }
+func (c *reorgChain) DbCommit(*model.GormDB)      {}
+func (c *reorgChain) DbRollback(*model.GormDB)    {}
+func (c *reorgChain) dropRows(kind string, n int) { delete(c.rows[kind], n) }
+
+func (c *reorgChain) DropExtrinsicNotFinalizedData(_ *model.GormDB, blockNum int) error {
+	c.dropRows("extrinsic", blockNum)
+	return nil
+}
+
+func (c *reorgChain) DropEventNotFinalizedData(_ *model.GormDB, blockNum int, _ bool) error {
+	c.dropRows("event", blockNum)
+	return nil
+}
+
+func (c *reorgChain) DropLogsNotFinalizedData(_ *model.GormDB, blockNum int, _ bool) error {
+	c.dropRows("log", blockNum)
+	return nil
+}
+
+func (c *reorgChain) DropBlockNotFinalizedData(_ *model.GormDB, blockNum int) error {
+	delete(c.blocks, blockNum)
+	c.rolledBack = append(c.rolledBack, blockNum)
+	return nil
+}
+
+// hashes are the stored block hashes by number
+func (c *reorgChain) hashes() map[int]string {
+	hashes := make(map[int]string)
+	for num, b := range c.blocks {
+		hashes[num] = b.Hash
+	}
+	return hashes
+}
+
+// reorgBlocks rolls back through blockService and stores the created blocks without decoding them
+type reorgBlocks struct {
+	model.BlockService
+	chain   *reorgChain
+	created []int
+}
+
+func (b *reorgBlocks) CreateChainBlock(hash string, block *rpc.Block, _ string, _ int, finalized bool) error {
+	blockNum := util.StringToInt(util.HexToNumStr(block.Header.Number))
+	b.chain.store(blockNum, hash, block.Header.ParentHash, finalized)
+	b.created = append(b.created, blockNum)
+	return nil
+}
+
+type reorgCommon struct {
+	model.CommonService
+}
+
+func (c *reorgCommon) GetCurrentRuntimeSpecVersion(int) int { return 1 }
+
+func canonicalHash(blockNum int) string { return fmt.Sprintf("0xc%d", blockNum) }
+
+func forkHash(blockNum int) string { return fmt.Sprintf("0xf%d", blockNum) }
+
+// replayChain records the canonical chain up to head for the replay source
+func replayChain(t *testing.T, head int) model.ChainSource {
+	dir, err := ioutil.TempDir("", "subscan-reorg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	var lines []byte
+	record := func(method string, params, result interface{}) {
+		raw, _ := json.Marshal(params)
+		line, _ := json.Marshal(map[string]interface{}{"method": method, "params": json.RawMessage(raw), "result": result})
+		lines = append(append(lines, line...), '\n')
+	}
+	for num := 1; num <= head; num++ {
+		hash := canonicalHash(num)
+		record("chain_getBlockHash", []int{num}, hash)
+		record("chain_getBlock", []string{hash}, map[string]interface{}{"block": map[string]interface{}{
+			"extrinsics": []string{},
+			"header": map[string]interface{}{
+				"number":     fmt.Sprintf("0x%x", num),
+				"parentHash": canonicalHash(num - 1),
+				"digest":     map[string]interface{}{"logs": []string{}},
+			},
+		}})
+		record("state_getStorageAt", []string{util.EventStorageKey, hash}, "0x00")
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "requests.jsonl"), lines, 0644); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := source.NewReplay(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+// storedChain is blocks 1 - head, the ones from forkAt on the fork, the ones up to finalizedTo finalized
+func storedChain(head, forkAt, finalizedTo int) *reorgChain {
+	chain := newReorgChain()
+	for num := 1; num <= head; num++ {
+		hash, parentHash := canonicalHash(num), canonicalHash(num-1)
+		if forkAt > 0 && num >= forkAt {
+			hash = forkHash(num)
+		}
+		if forkAt > 0 && num > forkAt {
+			parentHash = forkHash(num - 1)
+		}
+		chain.store(num, hash, parentHash, num <= finalizedTo)
+	}
+	return chain
+}
+
+func newReorgSubscribe(t *testing.T, chain *reorgChain, head int) (*subscribeService, *reorgBlocks) {
+	blocks := &reorgBlocks{BlockService: &blockService{SqlRepository: chain}, chain: chain}
+	return &subscribeService{
+		RedisRepository: &fillCursorRedis{},
+		SqlRepository:   chain,
+		CommonService:   &reorgCommon{},
+		BlockService:    blocks,
+		ChainSource:     replayChain(t, head),
+	}, blocks
+}
+
+func canonicalHashes(head int) map[int]string {
+	hashes := make(map[int]string)
+	for num := 1; num <= head; num++ {
+		hashes[num] = canonicalHash(num)
+	}
+	return hashes
+}
+
+// assertCanonical checks the stored blocks and their rows are the canonical ones, no orphaned row is left
+func assertCanonical(t *testing.T, chain *reorgChain, head int) {
+	t.Helper()
+	want := canonicalHashes(head)
+	if got := chain.hashes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("stored blocks %v, want %v", got, want)
+	}
+	for kind, rows := range chain.rows {
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("%s rows %v, want %v", kind, rows, want)
+		}
+	}
+}
+
+func TestCheckReorg(t *testing.T) {
+	tests := []struct {
+		name           string
+		stored         int
+		forkAt         int
+		finalizedTo    int
+		blockNum       int
+		wantRolledBack []int
+		wantErr        bool
+	}{
+		{name: "parent matches", stored: 3, blockNum: 4},
+		{name: "parent not stored", stored: 2, blockNum: 4},
+		{name: "1-deep reorg", stored: 3, forkAt: 3, finalizedTo: 1, blockNum: 4, wantRolledBack: []int{3}},
+		{name: "N-deep reorg", stored: 5, forkAt: 3, finalizedTo: 1, blockNum: 6, wantRolledBack: []int{3, 4, 5}},
+		{name: "fork of a finalized block", stored: 3, forkAt: 3, finalizedTo: 3, blockNum: 4, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := storedChain(tt.stored, tt.forkAt, tt.finalizedTo)
+			s, blocks := newReorgSubscribe(t, chain, tt.blockNum)
+			err := s.checkReorg(tt.blockNum, canonicalHash(tt.blockNum-1))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkReorg error %v, want error %v", err, tt.wantErr)
+			}
+			sort.Ints(chain.rolledBack)
+			if !reflect.DeepEqual(chain.rolledBack, tt.wantRolledBack) {
+				t.Errorf("rolled back %v, want %v", chain.rolledBack, tt.wantRolledBack)
+			}
+			if !reflect.DeepEqual(blocks.created, tt.wantRolledBack) {
+				t.Errorf("re-indexed %v, want %v", blocks.created, tt.wantRolledBack)
+			}
+			if !tt.wantErr {
+				assertCanonical(t, chain, tt.stored)
+			}
+		})
+	}
+}
+
+func TestFillBestBlock(t *testing.T) {
+	tests := []struct {
+		name           string
+		stored         int
+		forkAt         int
+		blockNum       int
+		wantRolledBack []int
+		wantCreated    []int
+	}{
+		{name: "next block", stored: 3, blockNum: 4, wantCreated: []int{4}},
+		{name: "stored already", stored: 4, blockNum: 4},
+		{name: "sibling head", stored: 4, forkAt: 4, blockNum: 4, wantRolledBack: []int{4}, wantCreated: []int{4}},
+		{name: "1-deep reorg", stored: 3, forkAt: 3, blockNum: 4, wantRolledBack: []int{3}, wantCreated: []int{3, 4}},
+		{name: "N-deep reorg", stored: 5, forkAt: 2, blockNum: 6, wantRolledBack: []int{2, 3, 4, 5}, wantCreated: []int{2, 3, 4, 5, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := storedChain(tt.stored, tt.forkAt, 1)
+			s, blocks := newReorgSubscribe(t, chain, tt.blockNum)
+			if err := s.fillBestBlock(tt.blockNum); err != nil {
+				t.Fatal(err)
+			}
+			sort.Ints(chain.rolledBack)
+			if !reflect.DeepEqual(chain.rolledBack, tt.wantRolledBack) {
+				t.Errorf("rolled back %v, want %v", chain.rolledBack, tt.wantRolledBack)
+			}
+			if !reflect.DeepEqual(blocks.created, tt.wantCreated) {
+				t.Errorf("created %v, want %v", blocks.created, tt.wantCreated)
+			}
+			assertCanonical(t, chain, tt.blockNum)
+		})
+	}
+}
+
+func TestFillBlockDataFinalizes(t *testing.T) {
+	tests := []struct {
+		name           string
+		forkAt         int
+		wantRolledBack []int
+		wantCreated    []int
+	}{
+		{name: "canonical block is only set finalized"},
+		{name: "orphaned block is rebuilt", forkAt: 3, wantRolledBack: []int{3}, wantCreated: []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := storedChain(3, tt.forkAt, 2)
+			s, blocks := newReorgSubscribe(t, chain, 3)
+			if err := s.fillBlockData(3, true); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(chain.rolledBack, tt.wantRolledBack) {
+				t.Errorf("rolled back %v, want %v", chain.rolledBack, tt.wantRolledBack)
+			}
+			if !reflect.DeepEqual(blocks.created, tt.wantCreated) {
+				t.Errorf("created %v, want %v", blocks.created, tt.wantCreated)
+			}
+			if !chain.blocks[3].Finalized {
+				t.Error("block 3 is not finalized")
+			}
+			if finalized := s.RedisRepository.(*fillCursorRedis).finalized; finalized != 3 {
+				t.Errorf("finalized fill cursor %d, want 3", finalized)
+			}
+			assertCanonical(t, chain, 3)
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/gorilla/websocket"
+	"github.com/itering/substrate-api-rpc/rpc"
+	ws "github.com/itering/substrate-api-rpc/websocket"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	SyncCursorWorker   = "sync"
+	syncReportInterval = 10 * time.Second
+)
+
+type syncService struct {
+	RedisRepository model.RedisRepository
+	SqlRepository   model.SqlRepository
+	CommonService   model.CommonService
+	RuntimeService  model.RuntimeService
+	BlockService    model.BlockService
+}
+
+type SyncConfig struct {
+	RedisRepository model.RedisRepository
+	SqlRepository   model.SqlRepository
+}
+
+func NewSyncService(c *SyncConfig, cs model.CommonService, r model.RuntimeService, b model.BlockService) model.SyncService {
+	return &syncService{
+		RedisRepository: c.RedisRepository,
+		SqlRepository:   c.SqlRepository,
+		CommonService:   cs,
+		RuntimeService:  r,
+		BlockService:    b,
+	}
+}
+
+// rawBlock is everything CreateChainBlock needs, fetched ahead of the decoder
+type rawBlock struct {
+	BlockNum    int
+	Hash        string
+	Block       *rpc.Block
+	Event       string
+	SpecVersion int
+}
+
+type syncResult struct {
+	BlockNum int
+	Err      error
+}
+
+// Sync backfills blocks [from, to] as finalized. Requests are sent in JSON-RPC batches of
+// batch blocks and fetched ahead of the workers, the contiguous progress is checkpointed in
+// the sync_cursor table so a restarted sync of the same range continues where it stopped
+func (s *syncService) Sync(conn ws.WsConn, from, to, workers, batch int) error {
+	if from < 0 || to < from {
+		return fmt.Errorf("invalid sync range %d - %d", from, to)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if batch < 1 {
+		batch = 1
+	}
+
+	worker := syncCursorWorker(from, to)
+	start := from
+	if cursor := s.SqlRepository.GetSyncCursor(worker, ""); cursor != nil && cursor.FinalizedBlockNum >= from {
+		start = cursor.FinalizedBlockNum + 1
+		log.Info("Resume sync ", from, " - ", to, " from checkpoint ", cursor.FinalizedBlockNum)
+	}
+	if start > to {
+		log.Info("Sync ", from, " - ", to, " already done")
+		return nil
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	blocks := make(chan *rawBlock, workers*batch*2)
+	results := make(chan syncResult, workers*batch)
+
+	var fetchErr error
+	go func() {
+		defer close(blocks)
+		fetchErr = s.prefetch(conn, start, to, batch, blocks, results, done)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range blocks {
+				err := s.BlockService.CreateChainBlock(b.Hash, b.Block, b.Event, b.SpecVersion, true)
+				results <- syncResult{BlockNum: b.BlockNum, Err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var (
+		progress    = newFillCheckpoint(s.RedisRepository, start)
+		checkpoint  = start - 1
+		failed      []int
+		count       int
+		startAt     = time.Now()
+		lastReport  = time.Now()
+		lastReportN int
+	)
+	for r := range results {
+		if r.Err != nil {
+			log.Error("Sync block ", r.BlockNum, " error ", r.Err)
+			failed = append(failed, r.BlockNum)
+			continue
+		}
+		count++
+		checkpoint = progress.done(r.BlockNum)
+
+		if time.Since(lastReport) >= syncReportInterval {
+			_ = s.SqlRepository.SaveSyncCursor(nil, &model.SyncCursor{Worker: worker, BlockNum: checkpoint, FinalizedBlockNum: checkpoint})
+			log.Info(fmt.Sprintf("Sync progress %d/%d blocks, checkpoint %d, %.2f blocks/s (avg %.2f blocks/s)",
+				count, to-start+1, checkpoint,
+				float64(count-lastReportN)/time.Since(lastReport).Seconds(),
+				float64(count)/time.Since(startAt).Seconds()))
+			lastReport, lastReportN = time.Now(), count
+		}
+	}
+	if checkpoint >= start {
+		_ = s.SqlRepository.SaveSyncCursor(nil, &model.SyncCursor{Worker: worker, BlockNum: checkpoint, FinalizedBlockNum: checkpoint})
+	}
+
+	log.Info(fmt.Sprintf("Sync %d - %d finished, %d blocks in %s, %.2f blocks/s, checkpoint %d",
+		start, to, count, time.Since(startAt).Round(time.Second), float64(count)/time.Since(startAt).Seconds(), checkpoint))
+
+	if fetchErr != nil {
+		return fetchErr
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d blocks failed, first %d, re-run the same range to retry", len(failed), failed[0])
+	}
+	return nil
+}
+
+// fillCheckpoint is the contiguous prefix of a range of blocks stored out of order, a failed block holds it back.
+// The fill cursors of the daemon follow it when the range starts right after them, the blocks between the cursors
+// and a later range are left to the daemon
+type fillCheckpoint struct {
+	sync.Mutex
+	redis      model.RedisRepository
+	from       int
+	checkpoint int
+	completed  map[int]bool
+}
+
+func newFillCheckpoint(r model.RedisRepository, from int) *fillCheckpoint {
+	return &fillCheckpoint{redis: r, from: from, checkpoint: from - 1, completed: make(map[int]bool)}
+}
+
+// done marks a block of the range stored and returns the checkpoint
+func (f *fillCheckpoint) done(blockNum int) int {
+	f.Lock()
+	defer f.Unlock()
+	if blockNum <= f.checkpoint {
+		return f.checkpoint
+	}
+	f.completed[blockNum] = true
+	moved := false
+	for f.completed[f.checkpoint+1] {
+		delete(f.completed, f.checkpoint+1)
+		f.checkpoint++
+		moved = true
+	}
+	if moved {
+		f.advanceFillCursors()
+	}
+	return f.checkpoint
+}
+
+func (f *fillCheckpoint) advanceFillCursors() {
+	c := context.TODO()
+	current, _ := f.redis.GetFillFinalizedBlockNum(c)
+	if f.from > current+1 || f.checkpoint <= current {
+		return
+	}
+	_ = f.redis.SaveFillAlreadyBlockNum(c, f.checkpoint)
+	_ = f.redis.SaveFillAlreadyFinalizedBlockNum(c, f.checkpoint)
+}
+
+// prefetch fetches [start, to] batch by batch and feeds the workers, blocks already
+// finalized in the database are reported as done without being fetched again
+func (s *syncService) prefetch(conn ws.WsConn, start, to, batch int, blocks chan<- *rawBlock, results chan<- syncResult, done chan struct{}) error {
+	for num := start; num <= to; num += batch {
+		end := num + batch - 1
+		if end > to {
+			end = to
+		}
+
+		var nums []int
+		for i := num; i <= end; i++ {
+			nums = append(nums, i)
+		}
+		stored := s.SqlRepository.BlocksReverseByNum(nums)
+
+		var fetch []int
+		for _, i := range nums {
+			if b, ok := stored[i]; ok && b.Finalized && !b.CodecError {
+				results <- syncResult{BlockNum: i}
+				continue
+			}
+			fetch = append(fetch, i)
+		}
+		if len(fetch) == 0 {
+			continue
+		}
+
+		raws, err := s.fetchBatch(conn, fetch)
+		if err != nil {
+			return err
+		}
+		for _, raw := range raws {
+			select {
+			case blocks <- raw:
+			case <-done:
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// fetchBatch gets the block hashes in one batch, then block, events and runtime version
+// of every hash in a second one
+func (s *syncService) fetchBatch(conn ws.WsConn, nums []int) ([]*rawBlock, error) {
+	var requests [][]byte
+	for i, num := range nums {
+		requests = append(requests, rpc.ChainGetBlockHash(i+1, num))
+	}
+	hashResults, err := batchRequest(conn, requests)
+	if err != nil {
+		return nil, err
+	}
+
+	raws := make([]*rawBlock, len(nums))
+	requests = requests[:0]
+	for i, num := range nums {
+		hash, err := hashResults[i+1].ToString()
+		if err != nil || hash == "" {
+			return nil, fmt.Errorf("ChainGetBlockHash %d get error %v", num, err)
+		}
+		raws[i] = &rawBlock{BlockNum: num, Hash: hash}
+		id := i*3 + 1
+		requests = append(requests,
+			rpc.ChainGetBlock(id, hash),
+			rpc.StateGetStorage(id+1, util.EventStorageKey, hash),
+			rpc.ChainGetRuntimeVersion(id+2, hash))
+	}
+	dataResults, err := batchRequest(conn, requests)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, raw := range raws {
+		id := i*3 + 1
+		block := dataResults[id].ToBlock()
+		if block == nil {
+			return nil, fmt.Errorf("ChainGetBlock %d get nil block", raw.BlockNum)
+		}
+		raw.Block = &block.Block
+		raw.Event, _ = dataResults[id+1].ToString()
+
+		if r := dataResults[id+2].ToRuntimeVersion(); r != nil {
+			raw.SpecVersion = r.SpecVersion
+			// registering a spec fetches metadata, keep it out of the concurrent workers
+			if err = s.RuntimeService.RegRuntimeVersion(r.ImplName, r.SpecVersion, raw.Hash); err != nil {
+				return nil, err
+			}
+		} else {
+			raw.SpecVersion = s.CommonService.GetCurrentRuntimeSpecVersion(raw.BlockNum)
+		}
+		if raw.SpecVersion == -1 {
+			return nil, fmt.Errorf("block %d unknown spec version", raw.BlockNum)
+		}
+	}
+	return raws, nil
+}
+
+// batchRequest sends requests as one JSON-RPC batch and returns the responses by id
+func batchRequest(conn ws.WsConn, requests [][]byte) (map[int]*rpc.JsonRpcResult, error) {
+	body := append([]byte("["), bytes.Join(requests, []byte(","))...)
+	body = append(body, ']')
+	if err := conn.WriteMessage(websocket.TextMessage, body); err != nil {
+		return nil, fmt.Errorf("websocket send error: %v", err)
+	}
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		return nil, fmt.Errorf("websocket read error: %v", err)
+	}
+
+	var results []rpc.JsonRpcResult
+	if err = json.Unmarshal(message, &results); err != nil {
+		return nil, fmt.Errorf("batch response decode error: %v", err)
+	}
+	if len(results) != len(requests) {
+		return nil, errors.New("batch response size mismatch")
+	}
+	resultMap := make(map[int]*rpc.JsonRpcResult, len(results))
+	for i := range results {
+		resultMap[results[i].Id] = &results[i]
+	}
+	return resultMap, nil
+}
+
+// syncCursorWorker keeps one checkpoint per requested range
+func syncCursorWorker(from, to int) string {
+	return fmt.Sprintf("%s:%d-%d", SyncCursorWorker, from, to)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+)
+
+// fillCursorRedis keeps the fill cursors of the daemon, the setters keep the maximum like redisRepository
+type fillCursorRedis struct {
+	model.RedisRepository
+	best, finalized int
+}
+
+func (r *fillCursorRedis) SaveFillAlreadyBlockNum(_ context.Context, blockNum int) error {
+	if blockNum > r.best {
+		r.best = blockNum
+	}
+	return nil
+}
+
+func (r *fillCursorRedis) SaveFillAlreadyFinalizedBlockNum(_ context.Context, blockNum int) error {
+	if blockNum > r.finalized {
+		r.finalized = blockNum
+	}
+	return nil
+}
+
+func (r *fillCursorRedis) GetFillFinalizedBlockNum(context.Context) (int, error) {
+	return r.finalized, nil
+}
+
+func TestFillCheckpoint(t *testing.T) {
+	tests := []struct {
+		name           string
+		cursor         int
+		from           int
+		done           []int
+		wantCheckpoint int
+		wantCursor     int
+	}{
+		{name: "in order", cursor: 99, from: 100, done: []int{100, 101, 102}, wantCheckpoint: 102, wantCursor: 102},
+		{name: "out of order", cursor: 99, from: 100, done: []int{102, 101}, wantCheckpoint: 99, wantCursor: 99},
+		{name: "gap filled", cursor: 99, from: 100, done: []int{102, 101, 100}, wantCheckpoint: 102, wantCursor: 102},
+		{name: "gap held", cursor: 99, from: 100, done: []int{100, 102, 103}, wantCheckpoint: 100, wantCursor: 100},
+		{name: "range past the cursors", cursor: 50, from: 100, done: []int{100, 101}, wantCheckpoint: 101, wantCursor: 50},
+		{name: "range behind the cursors", cursor: 200, from: 100, done: []int{100, 101}, wantCheckpoint: 101, wantCursor: 200},
+		{name: "range overlapping the cursors", cursor: 100, from: 90, done: []int{90, 91}, wantCheckpoint: 91, wantCursor: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fillCursorRedis{best: tt.cursor, finalized: tt.cursor}
+			progress := newFillCheckpoint(r, tt.from)
+			checkpoint := tt.from - 1
+			for _, blockNum := range tt.done {
+				checkpoint = progress.done(blockNum)
+			}
+			if checkpoint != tt.wantCheckpoint {
+				t.Errorf("checkpoint %d, want %d", checkpoint, tt.wantCheckpoint)
+			}
+			if r.best != tt.wantCursor || r.finalized != tt.wantCursor {
+				t.Errorf("fill cursors %d/%d, want %d", r.best, r.finalized, tt.wantCursor)
+			}
+		})
+	}
+}
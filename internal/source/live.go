@@ -0,0 +1,40 @@
+package source
+
+import (
+	"encoding/json"
+	"math/rand"
+	"time"
+
+	"github.com/CoolBitX-Technology/subscan/internal/rpcpool"
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/itering/substrate-api-rpc/pkg/recws"
+	"github.com/itering/substrate-api-rpc/rpc"
+	ws "github.com/itering/substrate-api-rpc/websocket"
+	"github.com/prometheus/common/log"
+)
+
+// NewLive reads from the active endpoint of the rpc pool
+func NewLive() model.ChainSource {
+	return &chainSource{transport: liveTransport{}, subscribe: liveSubscribeConn}
+}
+
+// liveTransport sends requests through the substrate-api-rpc websocket pool and reports
+// every round trip to the endpoint health score
+type liveTransport struct{}
+
+func (liveTransport) Call(method string, params interface{}) (*rpc.JsonRpcResult, error) {
+	request, _ := json.Marshal(rpc.Param{Id: rand.Intn(10000), Method: method, Params: params, JsonRpc: "2.0"})
+	v := &rpc.JsonRpcResult{}
+	endpoint := rpcpool.Active()
+	start := time.Now()
+	err := ws.SendWsRequest(nil, v, request)
+	rpcpool.Observe(endpoint, time.Since(start), 0, err)
+	return v, err
+}
+
+func liveSubscribeConn() ws.WsConn {
+	conn := &recws.RecConn{KeepAliveTimeout: 60 * time.Second, WriteTimeout: time.Second * 30, ReadTimeout: 30 * time.Second}
+	conn.Dial(rpcpool.Active(), nil)
+	log.Info("Dial to :", rpcpool.Active())
+	return conn
+}
@@ -0,0 +1,90 @@
+package source
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/itering/substrate-api-rpc/rpc"
+	ws "github.com/itering/substrate-api-rpc/websocket"
+	"github.com/prometheus/common/log"
+)
+
+// NewRecorder reads from the live node and appends everything it reads to dir,
+// the directory can be replayed later with NewReplay
+func NewRecorder(dir string) (model.ChainSource, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	requests, err := newRecordWriter(filepath.Join(dir, requestsFile))
+	if err != nil {
+		return nil, err
+	}
+	subscription, err := newRecordWriter(filepath.Join(dir, subscriptionFile))
+	if err != nil {
+		return nil, err
+	}
+	return &chainSource{
+		transport: &recordTransport{Transport: liveTransport{}, w: requests},
+		subscribe: func() ws.WsConn { return &recordConn{WsConn: liveSubscribeConn(), w: subscription} },
+	}, nil
+}
+
+// recordWriter appends JSON lines, it is shared by concurrent requests
+type recordWriter struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func newRecordWriter(path string) (*recordWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &recordWriter{f: f}, nil
+}
+
+func (w *recordWriter) write(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.f.Write(append(line, '\n')); err != nil {
+		log.Error("Record chain data error ", err)
+	}
+}
+
+// recordTransport records the responses of the wrapped transport, failed round trips are not recorded
+type recordTransport struct {
+	Transport
+	w *recordWriter
+}
+
+func (t *recordTransport) Call(method string, params interface{}) (*rpc.JsonRpcResult, error) {
+	v, err := t.Transport.Call(method, params)
+	if err != nil {
+		return v, err
+	}
+	raw, _ := json.Marshal(params)
+	line, _ := json.Marshal(record{Method: method, Params: raw, Result: v.Result, Error: v.Error})
+	t.w.write(line)
+	return v, nil
+}
+
+// recordConn records every message read from the subscription connection
+type recordConn struct {
+	ws.WsConn
+	w *recordWriter
+}
+
+func (c *recordConn) ReadMessage() (int, []byte, error) {
+	messageType, message, err := c.WsConn.ReadMessage()
+	if err == nil {
+		var compact bytes.Buffer
+		if json.Compact(&compact, message) == nil {
+			c.w.write(compact.Bytes())
+		}
+	}
+	return messageType, message, err
+}
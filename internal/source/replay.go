@@ -0,0 +1,163 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/gorilla/websocket"
+	"github.com/itering/substrate-api-rpc/rpc"
+	ws "github.com/itering/substrate-api-rpc/websocket"
+)
+
+const (
+	// request/response pairs, one record per line
+	requestsFile = "requests.jsonl"
+	// raw messages read from the subscription connection, one per line
+	subscriptionFile = "subscription.jsonl"
+)
+
+// record is one recorded request and its response
+type record struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result interface{}     `json:"result,omitempty"`
+	Error  *rpc.Error      `json:"error,omitempty"`
+}
+
+// NewReplay serves requests and head subscriptions from a directory written by NewRecorder
+func NewReplay(dir string) (model.ChainSource, error) {
+	transport, err := newReplayTransport(filepath.Join(dir, requestsFile))
+	if err != nil {
+		return nil, err
+	}
+	var messages [][]byte
+	if err = readLines(filepath.Join(dir, subscriptionFile), func(line []byte) error {
+		messages = append(messages, append([]byte(nil), line...))
+		return nil
+	}); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return &chainSource{
+		transport: transport,
+		subscribe: func() ws.WsConn { return &replayConn{messages: messages} },
+	}, nil
+}
+
+// replayTransport answers with the recorded responses of the same method and params,
+// a request recorded several times gets its responses in order, the last one repeats
+type replayTransport struct {
+	mu        sync.Mutex
+	responses map[string][]*rpc.JsonRpcResult
+}
+
+func newReplayTransport(path string) (*replayTransport, error) {
+	t := &replayTransport{responses: make(map[string][]*rpc.JsonRpcResult)}
+	err := readLines(path, func(line []byte) error {
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil {
+			return fmt.Errorf("%s decode error: %v", path, err)
+		}
+		key := requestKey(r.Method, r.Params)
+		t.responses[key] = append(t.responses[key], &rpc.JsonRpcResult{JsonRpc: "2.0", Result: r.Result, Error: r.Error})
+		return nil
+	})
+	return t, err
+}
+
+func (t *replayTransport) Call(method string, params interface{}) (*rpc.JsonRpcResult, error) {
+	raw, _ := json.Marshal(params)
+	key := requestKey(method, raw)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	responses := t.responses[key]
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("replay: no recorded response for %s", key)
+	}
+	if len(responses) > 1 {
+		t.responses[key] = responses[1:]
+	}
+	v := *responses[0]
+	return &v, nil
+}
+
+// replayConn plays the recorded subscription messages back, writes are dropped since
+// the responses to them are part of the recording. A finished recording goes quiet
+type replayConn struct {
+	mu       sync.Mutex
+	messages [][]byte
+	next     int
+}
+
+func (c *replayConn) Dial(string, http.Header) {}
+
+func (c *replayConn) IsConnected() bool { return true }
+
+func (c *replayConn) Close() {}
+
+func (c *replayConn) MarkUnusable() {}
+
+func (c *replayConn) CloseAndReconnect() {}
+
+func (c *replayConn) WriteMessage(int, []byte) error { return nil }
+
+func (c *replayConn) WriteJSON(interface{}) error { return nil }
+
+func (c *replayConn) ReadMessage() (int, []byte, error) {
+	c.mu.Lock()
+	if c.next >= len(c.messages) {
+		c.mu.Unlock()
+		select {}
+	}
+	message := c.messages[c.next]
+	c.next++
+	c.mu.Unlock()
+	return websocket.TextMessage, message, nil
+}
+
+func (c *replayConn) ReadJSON(v interface{}) error {
+	_, message, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(message, v)
+}
+
+// requestKey matches requests by method and params, hand edited recordings may be indented
+func requestKey(method string, params json.RawMessage) string {
+	var compact bytes.Buffer
+	if json.Compact(&compact, params) != nil || compact.Len() == 0 {
+		compact.WriteString("null")
+	}
+	return method + " " + compact.String()
+}
+
+func readLines(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	// metadata and blocks do not fit the default line limit
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err = fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/itering/substrate-api-rpc/rpc"
+	"github.com/itering/substrate-api-rpc/storage"
+	"github.com/itering/substrate-api-rpc/storageKey"
+	ws "github.com/itering/substrate-api-rpc/websocket"
+)
+
+// Transport sends one JSON-RPC request and returns its response
+type Transport interface {
+	Call(method string, params interface{}) (*rpc.JsonRpcResult, error)
+}
+
+type chainSource struct {
+	transport Transport
+	subscribe func() ws.WsConn
+}
+
+// New picks the source from the environment: CHAIN_REPLAY_DIR replays a recording,
+// CHAIN_RECORD_DIR records the live node into that directory, otherwise the live node is used
+func New() (model.ChainSource, error) {
+	switch {
+	case util.ChainReplayDir != "":
+		return NewReplay(util.ChainReplayDir)
+	case util.ChainRecordDir != "":
+		return NewRecorder(util.ChainRecordDir)
+	}
+	return NewLive(), nil
+}
+
+func (s *chainSource) GetBlockHash(blockNum int) (string, error) {
+	v, err := s.transport.Call("chain_getBlockHash", []int{blockNum})
+	if err != nil {
+		return "", err
+	}
+	hash, err := v.ToString()
+	if err != nil || hash == "" {
+		return "", fmt.Errorf("ChainGetBlockHash %d get error %v", blockNum, err)
+	}
+	return hash, nil
+}
+
+func (s *chainSource) GetBlock(hash string) (*rpc.Block, error) {
+	v, err := s.transport.Call("chain_getBlock", []string{hash})
+	if err != nil {
+		return nil, err
+	}
+	block := v.ToBlock()
+	if block == nil {
+		return nil, fmt.Errorf("ChainGetBlock %s get nil block", hash)
+	}
+	return &block.Block, nil
+}
+
+func (s *chainSource) GetEventStorage(hash string) (string, error) {
+	v, err := s.transport.Call("state_getStorageAt", []string{util.EventStorageKey, hash})
+	if err != nil {
+		return "", err
+	}
+	return v.ToString()
+}
+
+func (s *chainSource) GetRuntimeVersion(hash string) (*rpc.RuntimeVersion, error) {
+	v, err := s.transport.Call("chain_getRuntimeVersion", hashParams(hash))
+	if err != nil {
+		return nil, err
+	}
+	if v.Error == nil && v.Result == nil {
+		return nil, errors.New("ChainGetRuntimeVersion get nil result")
+	}
+	if r := v.ToRuntimeVersion(); r != nil {
+		return r, nil
+	}
+	return nil, fmt.Errorf("ChainGetRuntimeVersion %s get error %v", hash, v.Error)
+}
+
+// GetMetadata returns the raw metadata at hash, the latest one when hash is empty
+func (s *chainSource) GetMetadata(hash string) (string, error) {
+	v, err := s.transport.Call("state_getMetadata", hashParams(hash))
+	if err != nil {
+		return "", err
+	}
+	return v.ToString()
+}
+
+func (s *chainSource) ReadStorage(module, prefix, hash string, arg ...string) (storage.StateStorage, error) {
+	key := storageKey.EncodeStorageKey(module, prefix, arg...)
+	method, params := "state_getStorage", []string{util.AddHex(key.EncodeKey)}
+	if hash != "" {
+		method, params = "state_getStorageAt", append(params, hash)
+	}
+	v, err := s.transport.Call(method, params)
+	if err != nil {
+		return "", err
+	}
+	dataHex, err := v.ToString()
+	if err != nil || dataHex == "" {
+		return "", err
+	}
+	return storage.Decode(dataHex, key.ScaleType, nil)
+}
+
+func (s *chainSource) GetPaymentQueryInfo(encodedExtrinsic, hash string) (*rpc.PaymentQueryInfo, error) {
+	v, err := s.transport.Call("payment_queryInfo", append([]string{util.AddHex(encodedExtrinsic)}, hashParams(hash)...))
+	if err != nil {
+		return nil, err
+	}
+	if info := v.ToPaymentQueryInfo(); info != nil {
+		return info, nil
+	}
+	return nil, fmt.Errorf("get PaymentQueryInfo error %v", v.Error)
+}
+
+func (s *chainSource) SubscribeConn() ws.WsConn {
+	return s.subscribe()
+}
+
+func hashParams(hash string) []string {
+	if hash == "" {
+		return []string{}
+	}
+	return []string{hash}
+}
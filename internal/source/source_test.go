@@ -0,0 +1,103 @@
+package source
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itering/substrate-api-rpc/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNode answers like a node with a single block
+type fakeNode struct {
+	calls int
+}
+
+func (n *fakeNode) Call(method string, params interface{}) (*rpc.JsonRpcResult, error) {
+	n.calls++
+	v := &rpc.JsonRpcResult{JsonRpc: "2.0"}
+	switch method {
+	case "chain_getBlockHash":
+		v.Result = "0xabc"
+	case "chain_getBlock":
+		v.Result = map[string]interface{}{"block": map[string]interface{}{
+			"extrinsics": []interface{}{"0x280402000b"},
+			"header":     map[string]interface{}{"number": "0x1", "parentHash": "0x0", "digest": map[string]interface{}{"logs": []interface{}{}}},
+		}}
+	case "state_getStorageAt":
+		v.Result = "0x04"
+	case "chain_getRuntimeVersion":
+		v.Result = map[string]interface{}{"specVersion": 9, "implName": "fake"}
+	default:
+		return nil, fmt.Errorf("unexpected %s", method)
+	}
+	return v, nil
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chain-source")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	requests, err := newRecordWriter(filepath.Join(dir, requestsFile))
+	assert.NoError(t, err)
+	node := &fakeNode{}
+	live := &chainSource{transport: &recordTransport{Transport: node, w: requests}}
+
+	hash, err := live.GetBlockHash(1)
+	assert.NoError(t, err)
+	block, err := live.GetBlock(hash)
+	assert.NoError(t, err)
+	event, err := live.GetEventStorage(hash)
+	assert.NoError(t, err)
+	version, err := live.GetRuntimeVersion(hash)
+	assert.NoError(t, err)
+
+	subscription := `{"jsonrpc":"2.0","method":"chain_finalizedHead","params":{"result":{"number":"0x1"},"subscription":"1"}}`
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, subscriptionFile), []byte(subscription+"\n"), 0644))
+
+	replay, err := NewReplay(dir)
+	assert.NoError(t, err)
+
+	replayHash, err := replay.GetBlockHash(1)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, replayHash)
+	replayBlock, err := replay.GetBlock(hash)
+	assert.NoError(t, err)
+	assert.Equal(t, block, replayBlock)
+	replayEvent, err := replay.GetEventStorage(hash)
+	assert.NoError(t, err)
+	assert.Equal(t, event, replayEvent)
+	replayVersion, err := replay.GetRuntimeVersion(hash)
+	assert.NoError(t, err)
+	assert.Equal(t, version, replayVersion)
+	assert.Equal(t, 4, node.calls)
+
+	_, err = replay.GetBlockHash(2)
+	assert.Error(t, err)
+
+	_, message, err := replay.SubscribeConn().ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, subscription, string(message))
+}
+
+func TestReplayResponsesInOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chain-source")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recording := `{"method":"chain_getBlockHash","params":[ 5 ],"result":"0x1"}
+{"method":"chain_getBlockHash","params":[5],"result":"0x2"}
+`
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, requestsFile), []byte(recording), 0644))
+	replay, err := NewReplay(dir)
+	assert.NoError(t, err)
+	for _, want := range []string{"0x1", "0x2", "0x2"} {
+		hash, err := replay.GetBlockHash(5)
+		assert.NoError(t, err)
+		assert.Equal(t, want, hash)
+	}
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/itering/substrate-api-rpc/rpc"
+	"github.com/shopspring/decimal"
+)
+
+// ArchivedBlock is one line of an archive segment: the raw block as kept in chain_blocks plus
+// the node-derived validator and fees, so it can be imported again without a node
+type ArchivedBlock struct {
+	BlockNum       int                        `json:"block_num"`
+	Hash           string                     `json:"hash"`
+	ParentHash     string                     `json:"parent_hash"`
+	StateRoot      string                     `json:"state_root"`
+	ExtrinsicsRoot string                     `json:"extrinsics_root"`
+	Logs           []string                   `json:"logs"`
+	Extrinsics     []string                   `json:"extrinsics"`
+	Event          string                     `json:"event"`
+	SpecVersion    int                        `json:"spec_version"`
+	Validator      string                     `json:"validator"`
+	Fees           map[string]decimal.Decimal `json:"fees,omitempty"`
+}
+
+// ArchivedRuntime is the metadata of one spec version, written next to the segments
+type ArchivedRuntime struct {
+	Name        string `json:"name"`
+	SpecVersion int    `json:"spec_version"`
+	RawData     string `json:"raw_data"`
+}
+
+// AsArchived keeps the raw data of the block, fees are keyed by extrinsic index
+func (c *ChainBlock) AsArchived(fees map[string]decimal.Decimal) *ArchivedBlock {
+	a := ArchivedBlock{
+		BlockNum:       c.BlockNum,
+		Hash:           c.Hash,
+		ParentHash:     c.ParentHash,
+		StateRoot:      c.StateRoot,
+		ExtrinsicsRoot: c.ExtrinsicsRoot,
+		Event:          c.Event,
+		SpecVersion:    c.SpecVersion,
+		Validator:      c.Validator,
+		Fees:           fees,
+	}
+	_ = json.Unmarshal([]byte(c.Logs), &a.Logs)
+	_ = json.Unmarshal([]byte(c.Extrinsics), &a.Extrinsics)
+	return &a
+}
+
+// AsRpcBlock rebuilds the block as chain_getBlock returned it
+func (a *ArchivedBlock) AsRpcBlock() *rpc.Block {
+	return &rpc.Block{
+		Extrinsics: a.Extrinsics,
+		Header: rpc.ChainNewHeadResult{
+			Number:         fmt.Sprintf("0x%x", a.BlockNum),
+			ParentHash:     a.ParentHash,
+			StateRoot:      a.StateRoot,
+			ExtrinsicsRoot: a.ExtrinsicsRoot,
+			Digest:         rpc.ChainNewHeadLog{Logs: a.Logs},
+		},
+	}
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	DecodeStageExtrinsic = "extrinsic"
+	DecodeStageEvent     = "event"
+	DecodeStageLog       = "log"
+)
+
+// DecodeError is a block the metadata of its spec can not decode, Payload is the raw input of the failed stage
+type DecodeError struct {
+	BlockNum int
+	Spec     int
+	Stage    string
+	Payload  string
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode %s of block %d with spec %d: %v", e.Stage, e.BlockNum, e.Spec, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// AsFailure is the quarantine record of the error
+func (e *DecodeError) AsFailure(hash string) *DecodeFailure {
+	return &DecodeFailure{
+		BlockNum:    e.BlockNum,
+		BlockHash:   hash,
+		SpecVersion: e.Spec,
+		Stage:       e.Stage,
+		Error:       e.Err.Error(),
+		Payload:     e.Payload,
+	}
+}
+
+// DecodeFailure is a quarantined block, one row per block holding the last decode failure. The block
+// itself is kept with codec_error set and without extrinsics, events and logs until it decodes
+type DecodeFailure struct {
+	ID          uint      `gorm:"primary_key" json:"-"`
+	BlockNum    int       `json:"block_num"`
+	BlockHash   string    `json:"block_hash" sql:"size:100"`
+	SpecVersion int       `json:"spec_version"`
+	Stage       string    `json:"stage" sql:"size:20"`
+	Error       string    `json:"error" sql:"type:text;"`
+	Payload     string    `json:"payload" sql:"type:MEDIUMTEXT;"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (d DecodeFailure) TableName() string {
+	return "decode_failures"
+}
+
+const (
+	TypeRegistryReload         = "reload"
+	TypeRegistryReloadRedecode = "redecode"
+)
+
+// TypeRegistryReport is the outcome of a custom type registry reload, Unknown are the types of the
+// latest metadata still without a decoder
+type TypeRegistryReport struct {
+	Resolved []string `json:"resolved"`
+	Unknown  []string `json:"unknown"`
+}
@@ -23,6 +23,11 @@ type RedisRepository interface {
 	SaveFillAlreadyFinalizedBlockNum(c context.Context, blockNum int) (err error)
 	GetFillBestBlockNum(c context.Context) (num int, err error)
 	GetFillFinalizedBlockNum(c context.Context) (num int, err error)
+	ResetFillBlockNum(c context.Context, best, finalized int) error
+	SetRPCEndpointStatus(c context.Context, worker string, status interface{}) error
+	GetRPCEndpointStatus(c context.Context) (map[string]string, error)
+	PublishTypeRegistryReload(c context.Context, message string) error
+	SubscribeTypeRegistryReload(c context.Context) <-chan string
 	AddMissingBlocks(c context.Context, num int) error
 	AddRepairedBlock(c context.Context, num int) error
 	AddMissingBlocksInBulk(c context.Context, blockNum int, page, row int) error
@@ -38,6 +43,19 @@ type RedisRepository interface {
 	GetMissingBlockSet(c context.Context) ([]string, error)
 }
 
+// ChainSource is where ingestion reads chain data, a live node or a recording of one
+type ChainSource interface {
+	GetBlockHash(blockNum int) (string, error)
+	GetBlock(hash string) (*rpc.Block, error)
+	GetEventStorage(hash string) (string, error)
+	GetRuntimeVersion(hash string) (*rpc.RuntimeVersion, error)
+	GetMetadata(hash string) (string, error)
+	ReadStorage(module, prefix, hash string, arg ...string) (storage.StateStorage, error)
+	GetPaymentQueryInfo(encodedExtrinsic, hash string) (*rpc.PaymentQueryInfo, error)
+	// SubscribeConn is a connection for the head subscriptions
+	SubscribeConn() websocket.WsConn
+}
+
 type SqlRepository interface {
 	Close()
 	Migration(blockNum int)
@@ -46,6 +64,7 @@ type SqlRepository interface {
 	DbCommit(*GormDB)
 	DbRollback(*GormDB)
 	CreateBlock(*GormDB, *ChainBlock) (err error)
+	DropBlockNotFinalizedData(txn *GormDB, blockNum int) error
 	UpdateEventAndExtrinsic(*GormDB, *ChainBlock, int, int, int, string, bool, bool) error
 	GetNearBlock(int) *ChainBlock
 	SetBlockFinalized(*ChainBlock)
@@ -56,14 +75,14 @@ type SqlRepository interface {
 	GetBlockList(blockNum int, page, row int) []ChainBlock
 	BlockAsJson(c context.Context, block *ChainBlock) *ChainBlockJson
 	CreateEvent(txn *GormDB, event *ChainEvent) *gorm.DB
-	DropEventNotFinalizedData(blockNum int, finalized bool) bool
+	DropEventNotFinalizedData(txn *GormDB, blockNum int, finalized bool) error
 	GetRawEventByBlockNum(blockNum int, where ...string) []ChainEvent
 	GetEventByBlockNum(blockNum int, where ...string) []ChainEventJson
 	GetEventList(page, row, blockNum int, order string, where ...string) ([]ChainEvent, int)
 	GetEventsByIndex(extrinsicIndex string) []ChainEvent
 	GetEventByIdx(index string) *ChainEvent
 	CreateExtrinsic(c context.Context, txn *GormDB, extrinsic *ChainExtrinsic) *gorm.DB
-	DropExtrinsicNotFinalizedData(c context.Context, blockNum int) *gorm.DB
+	DropExtrinsicNotFinalizedData(txn *GormDB, blockNum int) error
 	GetExtrinsicsByBlockNum(blockNum int) []ChainExtrinsicJson
 	GetRawExtrinsicsByBlockNum(blockNum int) []ChainExtrinsic
 	GetExtrinsicList(c context.Context, page, row int, order string, blockNum int, ms map[string]string, queryWhere ...string) ([]ChainExtrinsic, int)
@@ -72,7 +91,8 @@ type SqlRepository interface {
 	GetExtrinsicsDetailByIndex(c context.Context, index string) *ExtrinsicDetail
 	ExtrinsicsAsJson(e *ChainExtrinsic) *ChainExtrinsicJson
 	CreateLog(txn *GormDB, ce *ChainLog) error
-	DropLogsNotFinalizedData(blockNum int, finalized bool) bool
+	DropLogsNotFinalizedData(txn *GormDB, blockNum int, finalized bool) error
+	DropBlockDerivedData(txn *GormDB, blockNum int) (extrinsics, signed, events int, err error)
 	GetLogsByIndex(index string) *ChainLogJson
 	GetLogByBlockNum(blockNum int) []ChainLogJson
 	CreateRuntimeVersion(name string, specVersion int) int64
@@ -80,24 +100,52 @@ type SqlRepository interface {
 	RuntimeVersionList() []RuntimeVersion
 	RuntimeVersionRaw(spec int) *metadata.RuntimeRaw
 	RuntimeVersionRecent() *RuntimeVersion
+	RuntimeVersionRawList() []RuntimeVersion
+	SaveSyncCursor(txn *GormDB, cursor *SyncCursor) error
+	AdvanceSyncCursor(txn *GormDB, cursor *SyncCursor) error
+	RewindSyncCursor(worker, plugin string, blockNum int) error
+	GetSyncCursor(worker, plugin string) *SyncCursor
+	SaveDecodeFailure(txn *GormDB, failure *DecodeFailure) error
+	DeleteDecodeFailure(txn *GormDB, blockNum int) error
+	GetDecodeFailureList(page, row int) ([]DecodeFailure, int)
+	DecodeFailureBlockNums() []int
+	SavePluginFailure(txn *GormDB, failure *PluginFailure) error
+	GetPluginFailure(id uint) *PluginFailure
+	GetPluginFailureList(page, row int, plugin string) ([]PluginFailure, int)
+	DuePluginFailures(maxAttempts, limit int) []PluginFailure
+	DeletePluginFailure(txn *GormDB, id uint) error
+	GetPluginSchemaVersion(plugin string) (int, error)
+	SavePluginMigration(migration *PluginSchemaMigration) error
+	LockPluginMigrations() (unlock func(), err error)
 }
 
 type CommonService interface {
 	Close()
 	InitSubRuntimeLatest()
-	ValidatorsList(conn websocket.WsConn, hash string) (validatorList []string)
+	InitRuntime(spec int, raw string) error
+	ValidatorsList(hash string) (validatorList []string)
 	GetCurrentRuntimeSpecVersion(blockNum int) int
 	Ping(ctx context.Context, e *empty.Empty) (*empty.Empty, error)
 	SetHeartBeat(action string)
 	DaemonHealth(ctx context.Context) map[string]bool
 	Metadata() (map[string]string, error)
 	ReadTypeRegistry() ([]byte, error)
+	ReloadTypeRegistry() (*TypeRegistryReport, error)
+	BroadcastTypeRegistryReload(redecode bool) error
+	WatchTypeRegistryReload(c context.Context, redecode func())
+	RebuildSyncCursorCache()
+	SetRPCEndpointStatus(worker string, status interface{})
+	RPCEndpointStatus() map[string]interface{}
 	EmitLog(txn *GormDB, blockNum int, l []storage.DecoderLog, finalized bool, validatorList []string) (validator string, err error)
 }
 
 type BlockService interface {
-	CreateChainBlock(conn websocket.WsConn, hash string, block *rpc.Block, event string, spec int, finalized bool) (err error)
-	UpdateBlockData(conn websocket.WsConn, block *ChainBlock, finalized bool) (err error)
+	CreateChainBlock(hash string, block *rpc.Block, event string, spec int, finalized bool) (err error)
+	UpdateBlockData(block *ChainBlock, finalized bool) (err error)
+	ImportChainBlock(archived *ArchivedBlock) error
+	RedecodeBlock(block *ChainBlock) error
+	RollbackBlock(blockNum int) error
+	DecodeFailures(page, row int) ([]DecodeFailure, int)
 	GetBlocksSampleByNums(page, row int) []SampleBlockJson
 	GetMissingBlockMap(blockNum int, page, row int) IntBoolMap
 	GetMissingBlockSet(blockNum int, page, row int) ([]string, error)
@@ -115,10 +163,11 @@ type ExtrinsicService interface {
 	GetExtrinsicByIndex(index string) *ExtrinsicDetail
 	GetExtrinsicDetailByHash(hash string) *ExtrinsicDetail
 	GetExtrinsicByHash(hash string) *ChainExtrinsic
-	CreateExtrinsic(c context.Context, txn *GormDB, block *ChainBlock, encodeExtrinsics []string, decodeExtrinsics []map[string]interface{}, eventMap map[string][]ChainEvent) (int, int, map[string]string, map[string]decimal.Decimal, error)
+	CreateExtrinsic(c context.Context, txn *GormDB, block *ChainBlock, encodeExtrinsics []string, decodeExtrinsics []map[string]interface{}, eventMap map[string][]ChainEvent, knownFees map[string]decimal.Decimal) (int, int, map[string]string, map[string]decimal.Decimal, error)
 	GetTimestamp(extrinsic *ChainExtrinsic) (blockTimestamp int)
 	GetExtrinsicSuccess(e []ChainEvent) bool
-	GetExtrinsicFee(p websocket.WsConn, encodeExtrinsic string, blockHash string) (fee decimal.Decimal, err error)
+	GetExtrinsicFee(encodeExtrinsic string, blockHash string) (fee decimal.Decimal, err error)
+	ExtrinsicErrorQuery(module, name string) (string, bool)
 }
 
 type EventService interface {
@@ -134,6 +183,13 @@ type PluginService interface {
 	EmitExtrinsic(block *ChainBlock, extrinsic *ChainExtrinsic, eventsMap map[string][]ChainEvent) (err error)
 	PluginsFetchBlock()
 	PluginRegister()
+	Reindex(name string, from, to int) error
+	FeedBlock(blockNum int) (bool, error)
+	Rollback(blockNum int) error
+	PluginFailures(page, row int, plugin string) ([]PluginFailure, int)
+	RetryPluginFailure(id uint) error
+	DiscardPluginFailure(id uint) error
+	MigratePlugins(dryRun bool) ([]PluginSchemaMigration, error)
 }
 
 type RuntimeService interface {
@@ -149,6 +205,21 @@ type SubscribeService interface {
 	Subscribe(conn websocket.WsConn, interrupt chan os.Signal)
 	Parser(message []byte) (err error)
 	SubscribeFetchBlock()
+	SubscribeFetchBestBlock()
+}
+
+type SyncService interface {
+	Sync(conn websocket.WsConn, from, to, workers, batch int) error
+}
+
+type ArchiveService interface {
+	Export(dir string, from, to, segment int) error
+	Import(dir string, from, to, workers int) error
+}
+
+type RedecodeService interface {
+	Redecode(from, to, workers int, codecErrorOnly bool) error
+	RetryDecodeFailures(workers int) error
 }
 
 type RepairService interface {
@@ -171,6 +242,8 @@ type DB interface {
 	AddIndex(model interface{}, indexName string, columns ...string) error
 	// Add column unique index
 	AddUniqueIndex(model interface{}, indexName string, columns ...string) error
+	// Drop an index, nothing when it does not exist
+	RemoveIndex(model interface{}, indexName string) error
 
 	DbBegin() *GormDB
 
@@ -184,6 +257,16 @@ type DB interface {
 	Update(c *GormDB, model interface{}, query interface{}, attr map[string]interface{}) *GormDB
 	// Delete one or more record
 	Delete(model interface{}, query interface{}) error
+
+	// Plugin tables without a go model, the table name is prefixed like the model ones
+	// Create the table or add its missing columns and indexes
+	MigrateTable(table *Table) error
+	// Insert one row of column values
+	InsertRow(c *GormDB, table string, row map[string]interface{}) error
+	// Delete the rows matching every condition, at least one is required
+	DeleteRows(c *GormDB, table string, where []Cond) error
+	// Find the rows matching every condition, option pages and orders them, c may be nil
+	FindRows(c *GormDB, table string, where []Cond, option *Option) ([]map[string]interface{}, error)
 }
 
 type Option struct {
@@ -203,6 +286,11 @@ type Dao interface {
 
 	// Plugin set prefix
 	SetPrefix(string)
+
+	// Transaction of the block being fed to the plugins, the writes of every plugin commit together
+	// with the plugin cursors. Commit and rollback it like a DbBegin one, outside block processing
+	// it is a transaction of its own
+	BlockTxn(blockNum int) *GormDB
 }
 
 type Block struct {
@@ -264,3 +352,46 @@ type Plugin interface {
 	// Plugins version
 	Version() string
 }
+
+// PluginConfigure is implemented by plugins taking their config map of plugins.toml, it is called
+// before InitDao, with a nil map when the plugin has no config
+type PluginConfigure interface {
+	Configure(config map[string]interface{}) error
+}
+
+// PluginRollback is implemented by plugins that can drop what they ingested, the core calls it once
+// blocks they were fed are re-decoded
+type PluginRollback interface {
+	// Drop the data of the blocks after blockNum
+	Rollback(blockNum int) error
+}
+
+// PluginInOrder is implemented by plugins reading what they stored for the previous blocks, E.g a ledger per
+// account, the core feeds them one block after another instead of several blocks at once. A failed item is not
+// dead-lettered, the block is fed again until the plugin takes it
+type PluginInOrder interface {
+	InOrder() bool
+}
+
+// PluginMigrations is implemented by plugins versioning their schema, the core runs the pending steps
+// when the plugin starts and refuses to start it when its tables are newer than the steps
+type PluginMigrations interface {
+	// Ordered steps, never reorder or drop an applied one
+	Migrations() []PluginMigration
+}
+
+// PluginRoutes is implemented by plugins serving their api through the core router, with methods,
+// path params, validated params and the R envelope, unlike the raw POST handlers of InitHttp
+type PluginRoutes interface {
+	Routes() []PluginRoute
+}
+
+// PluginExtrinsicErrors is implemented by plugins decoding why extrinsics failed, E.g the system plugin, the
+// extrinsic apis show and filter the errors through it
+type PluginExtrinsicErrors interface {
+	// Error of a failed extrinsic, nil when it was not decoded
+	ExtrinsicError(extrinsicIndex string) *ExtrinsicError
+	// Where clause of chain_extrinsics matching the extrinsics failed with the error, an empty module or name
+	// matches any
+	ExtrinsicErrorQuery(module, name string) string
+}
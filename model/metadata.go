@@ -0,0 +1,35 @@
+package model
+
+import (
+	"sync"
+
+	"github.com/itering/substrate-api-rpc/metadata"
+)
+
+// SpecMetadata processes the raw metadata of a spec once, for the plugins reading the metadata of every block
+// E.g NewSpecMetadata(dao.SpecialMetadata)
+type SpecMetadata struct {
+	raw      func(spec int) string
+	mu       sync.Mutex
+	instants map[int]*metadata.Instant
+}
+
+func NewSpecMetadata(raw func(spec int) string) *SpecMetadata {
+	return &SpecMetadata{raw: raw, instants: make(map[int]*metadata.Instant)}
+}
+
+// Instant is the processed metadata of spec, nil while its raw metadata is not stored
+func (s *SpecMetadata) Instant(spec int) *metadata.Instant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if instant, ok := s.instants[spec]; ok {
+		return instant
+	}
+	raw := s.raw(spec)
+	if raw == "" {
+		return nil
+	}
+	instant := metadata.Process(&metadata.RuntimeRaw{Spec: spec, Raw: raw})
+	s.instants[spec] = instant
+	return instant
+}
@@ -1,9 +1,12 @@
 package model_test
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/itering/substrate-api-rpc/metadata"
 	"github.com/shopspring/decimal"
 	"gopkg.in/go-playground/assert.v1"
 )
@@ -48,3 +51,102 @@ func TestModelPluginRender(t *testing.T) {
 	assert.Equal(t, &model.Extrinsic{ExtrinsicHash: "0x0", Params: []byte(`{"a":"b"}`), Fee: decimal.New(1, 0)}, extrinsic.AsPlugin())
 
 }
+
+func TestArchivedBlock(t *testing.T) {
+	block := model.ChainBlock{BlockNum: 300, Hash: "0x1", ParentHash: "0x0", StateRoot: "0x2", ExtrinsicsRoot: "0x3",
+		Logs: `["0x0642"]`, Extrinsics: `["0x280402","0x1c0503"]`, Event: "0x04", SpecVersion: 2, Validator: "0x5"}
+	fees := map[string]decimal.Decimal{"300-1": decimal.New(15, 0)}
+	archived := block.AsArchived(fees)
+	assert.Equal(t, []string{"0x0642"}, archived.Logs)
+	assert.Equal(t, []string{"0x280402", "0x1c0503"}, archived.Extrinsics)
+	assert.Equal(t, fees, archived.Fees)
+
+	rpcBlock := archived.AsRpcBlock()
+	assert.Equal(t, "0x12c", rpcBlock.Header.Number)
+	assert.Equal(t, "0x0", rpcBlock.Header.ParentHash)
+	assert.Equal(t, archived.Logs, rpcBlock.Header.Digest.Logs)
+	assert.Equal(t, archived.Extrinsics, rpcBlock.Extrinsics)
+}
+
+func TestDecodeError(t *testing.T) {
+	cause := errors.New("Recovering from panic in DecodeEvent")
+	var err error = &model.DecodeError{BlockNum: 10, Spec: 26, Stage: model.DecodeStageEvent, Payload: "0x04", Err: cause}
+	var decodeErr *model.DecodeError
+	assert.Equal(t, errors.As(fmt.Errorf("create block: %w", err), &decodeErr), true)
+	assert.Equal(t, errors.Is(err, cause), true)
+	assert.Equal(t, err.Error(), "decode event of block 10 with spec 26: Recovering from panic in DecodeEvent")
+
+	failure := decodeErr.AsFailure("0xabc")
+	assert.Equal(t, failure.BlockNum, 10)
+	assert.Equal(t, failure.BlockHash, "0xabc")
+	assert.Equal(t, failure.SpecVersion, 26)
+	assert.Equal(t, failure.Stage, "event")
+	assert.Equal(t, failure.Payload, "0x04")
+	assert.Equal(t, failure.TableName(), "decode_failures")
+}
+
+func TestPluginTable(t *testing.T) {
+	table := model.Table{
+		Name:    "stake",
+		Columns: []model.TableColumn{{Name: "block_num", Type: "int"}, {Name: "amount", Type: "decimal"}},
+		Indexes: []model.TableIndex{{Name: "block_num", Columns: []string{"block_num"}}},
+	}
+	assert.Equal(t, table.Validate(), nil)
+	table.Columns = append(table.Columns, model.TableColumn{Name: "id", Type: "int"})
+	assert.NotEqual(t, table.Validate(), nil)
+	table.Columns = table.Columns[:2]
+	table.Indexes[0].Columns = []string{"amount`; drop table x"}
+	assert.NotEqual(t, table.Validate(), nil)
+
+	where, values, err := model.CondsSQL([]model.Cond{{Column: "block_num", Op: ">", Value: 10}, {Column: "amount", Op: "=", Value: "1"}})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, where, "`block_num` > ? AND `amount` = ?")
+	assert.Equal(t, values, []interface{}{10, "1"})
+	_, _, err = model.CondsSQL([]model.Cond{{Column: "block_num", Op: "; delete", Value: 10}})
+	assert.NotEqual(t, err, nil)
+}
+
+func TestPendingMigrations(t *testing.T) {
+	up := func(model.Dao) error { return nil }
+	steps := []model.PluginMigration{{Version: 1, Name: "amount decimal", Up: up}, {Version: 2, Name: "backfill fee", Up: up}}
+
+	pending, err := model.PendingMigrations("transfer", steps, 0)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(pending), 2)
+	pending, err = model.PendingMigrations("transfer", steps, 1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, pending[0].Name, "backfill fee")
+	pending, err = model.PendingMigrations("transfer", steps, 2)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(pending), 0)
+
+	_, err = model.PendingMigrations("transfer", steps, 3)
+	assert.Equal(t, err.Error(), "plugin transfer schema version 3 is newer than its code version 2")
+	_, err = model.PendingMigrations("transfer", []model.PluginMigration{{Version: 2, Up: up}}, 0)
+	assert.NotEqual(t, err, nil)
+	_, err = model.PendingMigrations("transfer", []model.PluginMigration{{Version: 1}}, 0)
+	assert.NotEqual(t, err, nil)
+}
+
+func TestSpecMetadata(t *testing.T) {
+	const spec = 90002
+	instant := &metadata.Instant{MetadataVersion: 14}
+	metadata.RuntimeMetadata[spec] = instant
+	defer delete(metadata.RuntimeMetadata, spec)
+	reads := 0
+	s := model.NewSpecMetadata(func(s int) string {
+		reads++
+		if s == spec {
+			return "0x00"
+		}
+		return ""
+	})
+
+	assert.Equal(t, s.Instant(spec), instant)
+	assert.Equal(t, s.Instant(spec), instant)
+	assert.Equal(t, reads, 1)
+	// a spec without metadata is read again
+	assert.Equal(t, s.Instant(spec+1) == nil, true)
+	assert.Equal(t, s.Instant(spec+1) == nil, true)
+	assert.Equal(t, reads, 3)
+}
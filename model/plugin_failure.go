@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	PluginFailureExtrinsic = "extrinsic"
+	PluginFailureEvent     = "event"
+)
+
+// PluginFailure is an extrinsic or event a plugin failed to process, kept for retry while the
+// plugin goes on with the next items. ItemIndex is the extrinsic index or block_num-event_idx
+type PluginFailure struct {
+	ID          uint      `gorm:"primary_key" json:"id"`
+	Plugin      string    `json:"plugin" sql:"size:100"`
+	BlockNum    int       `json:"block_num"`
+	Kind        string    `json:"kind" sql:"size:20"`
+	ItemIndex   string    `json:"item_index" sql:"size:100"`
+	Error       string    `json:"error" sql:"type:text;"`
+	Stack       string    `json:"stack" sql:"type:text;"`
+	Attempts    int       `json:"attempts"`
+	NextRetryAt time.Time `json:"next_retry_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (p PluginFailure) TableName() string {
+	return "plugin_failures"
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// PluginMigration is a numbered schema step of a plugin, E.g a column type change or a backfill
+// AutoMigration cannot do. Up runs once on the Dao of the plugin, after its Migrate
+type PluginMigration struct {
+	// 1 for the first step, each next step adds one
+	Version int
+	Name    string
+	Up      func(d Dao) error
+}
+
+// PluginSchemaMigration is a step applied to the tables of a plugin, keyed by the plugin prefix
+type PluginSchemaMigration struct {
+	ID        uint      `gorm:"primary_key" json:"-"`
+	Plugin    string    `json:"plugin" sql:"size:100"`
+	Version   int       `json:"version"`
+	Name      string    `json:"name" sql:"size:255"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+func (p PluginSchemaMigration) TableName() string {
+	return "plugin_migrations"
+}
+
+// PendingMigrations are the steps after the applied version, it fails when the steps are not numbered
+// 1, 2, ... or when the applied version is newer than the last step, the plugin must not run then
+func PendingMigrations(plugin string, steps []PluginMigration, applied int) ([]PluginMigration, error) {
+	for i, step := range steps {
+		if step.Version != i+1 {
+			return nil, fmt.Errorf("plugin %s migration %q has version %d, want %d", plugin, step.Name, step.Version, i+1)
+		}
+		if step.Up == nil {
+			return nil, fmt.Errorf("plugin %s migration %d has no Up", plugin, step.Version)
+		}
+	}
+	if applied > len(steps) {
+		return nil, fmt.Errorf("plugin %s schema version %d is newer than its code version %d", plugin, applied, len(steps))
+	}
+	return steps[applied:], nil
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// PluginRoute is an api of a plugin, mounted at /api/plugin/<plugin>/<Path> and answered in the R envelope
+type PluginRoute struct {
+	// GET or POST, POST when empty
+	Method string
+	// Relative path, E.g transfers/:address
+	Path string
+	// Paths under /api also serving the route, E.g scan/transfers for an endpoint moved out of the core
+	Aliases []string
+	// New params struct, bound from the uri, the query of a GET or the JSON body of a POST by their uri, form
+	// and json tags, then checked by their validate tags. Nil when the route takes no params
+	Params func() interface{}
+	// Data of the response, an *ApiError sets the code and status of a failure
+	Handle func(r *PluginRequest) (interface{}, error)
+}
+
+type PluginRequest struct {
+	// Bound and validated Params
+	Params  interface{}
+	Request *http.Request
+}
+
+type ApiError struct {
+	Status  int
+	Code    int
+	Message string
+}
+
+func NewApiError(status, code int, format string, args ...interface{}) *ApiError {
+	return &ApiError{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *ApiError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Table is the schema of a plugin table without a go model, E.g of an out-of-process plugin. Every
+// table gets an auto increment id column
+type Table struct {
+	Name    string        `json:"name"`
+	Columns []TableColumn `json:"columns"`
+	Indexes []TableIndex  `json:"indexes"`
+}
+
+type TableColumn struct {
+	Name string `json:"name"`
+	// one of int, bigint, bool, string, text, decimal
+	Type string `json:"type"`
+}
+
+type TableIndex struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+}
+
+// Cond is a column condition of FindRows and DeleteRows, conditions are joined with AND
+type Cond struct {
+	Column string      `json:"column"`
+	Op     string      `json:"op"`
+	Value  interface{} `json:"value"`
+}
+
+var (
+	identifierRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+	columnTypes      = map[string]string{
+		"int":     "int",
+		"bigint":  "bigint",
+		"bool":    "tinyint(1)",
+		"string":  "varchar(255)",
+		"text":    "text",
+		"decimal": "decimal(65,0)",
+	}
+	condOps = []string{"=", "!=", ">", ">=", "<", "<="}
+)
+
+// ValidIdentifier reports whether name is usable as a table, column or index name
+func ValidIdentifier(name string) bool {
+	return identifierRegexp.MatchString(name)
+}
+
+// ColumnSQLType is the mysql type of a table column type
+func ColumnSQLType(columnType string) (string, bool) {
+	t, ok := columnTypes[columnType]
+	return t, ok
+}
+
+func (t *Table) Validate() error {
+	if !ValidIdentifier(t.Name) {
+		return fmt.Errorf("invalid table name %q", t.Name)
+	}
+	columns := map[string]bool{"id": true}
+	for _, c := range t.Columns {
+		if !ValidIdentifier(c.Name) || columns[c.Name] {
+			return fmt.Errorf("table %s: invalid or duplicate column %q", t.Name, c.Name)
+		}
+		if _, ok := ColumnSQLType(c.Type); !ok {
+			return fmt.Errorf("table %s: column %s has unknown type %q", t.Name, c.Name, c.Type)
+		}
+		columns[c.Name] = true
+	}
+	for _, i := range t.Indexes {
+		if !ValidIdentifier(i.Name) || len(i.Columns) == 0 {
+			return fmt.Errorf("table %s: invalid index %q", t.Name, i.Name)
+		}
+		for _, c := range i.Columns {
+			if !columns[c] {
+				return fmt.Errorf("table %s: index %s on unknown column %q", t.Name, i.Name, c)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Cond) Validate() error {
+	if !ValidIdentifier(c.Column) {
+		return fmt.Errorf("invalid column %q", c.Column)
+	}
+	for _, op := range condOps {
+		if c.Op == op {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid operator %q", c.Op)
+}
+
+// CondsSQL joins conds into a where clause with its values
+func CondsSQL(conds []Cond) (string, []interface{}, error) {
+	var (
+		clauses []string
+		values  []interface{}
+	)
+	for _, c := range conds {
+		if err := c.Validate(); err != nil {
+			return "", nil, err
+		}
+		clauses = append(clauses, fmt.Sprintf("`%s` %s ?", c.Column, c.Op))
+		values = append(values, c.Value)
+	}
+	return strings.Join(clauses, " AND "), values, nil
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/CoolBitX-Technology/subscan/util"
 	"github.com/jinzhu/gorm"
@@ -23,6 +24,9 @@ const (
 type GormDB struct {
 	*gorm.DB
 	GdbDone bool
+	// Savepoint is set on a handle nested in another transaction, committing it leaves the commit to
+	// the owner and rolling it back only undoes the writes since the savepoint
+	Savepoint string
 }
 
 var (
@@ -152,6 +156,27 @@ type RuntimeVersion struct {
 	RawData     string `json:"-" sql:"type:MEDIUMTEXT;"`
 }
 
+const (
+	SyncCursorSubstrate = "substrate"
+	SyncCursorPlugins   = "plugins"
+)
+
+// SyncCursor records how far a worker has ingested, one row per worker, network and plugin.
+// It is written in the same transaction as the data, redis fill cursors are only a cache of it
+type SyncCursor struct {
+	ID                uint      `gorm:"primary_key" json:"-"`
+	Worker            string    `json:"worker" sql:"size:100"`
+	Network           string    `json:"network" sql:"size:100"`
+	Plugin            string    `json:"plugin" sql:"size:100;default:''"`
+	BlockNum          int       `json:"block_num"`
+	FinalizedBlockNum int       `json:"finalized_block_num"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func (c SyncCursor) TableName() string {
+	return "sync_cursor"
+}
+
 type ChainLog struct {
 	ID        uint   `gorm:"primary_key"`
 	BlockNum  int    `json:"block_num" `
@@ -65,6 +65,14 @@ type ExtrinsicDetail struct {
 	Event              *[]ChainEvent    `json:"event"`
 	Fee                decimal.Decimal  `json:"fee"`
 	Finalized          bool             `json:"finalized"`
+	Error              *ExtrinsicError  `json:"error"`
+}
+
+// ExtrinsicError is why an extrinsic failed, E.g module Balances name InsufficientBalance, or name BadOrigin
+type ExtrinsicError struct {
+	Module string `json:"module"`
+	Name   string `json:"name"`
+	Doc    string `json:"doc"`
 }
 
 type ChainEventJson struct {
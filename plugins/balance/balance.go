@@ -2,55 +2,64 @@ package balance
 
 import (
 	"fmt"
-	plugin "github.com/itering/subscan-plugin"
-	"github.com/itering/subscan-plugin/router"
-	"github.com/itering/subscan-plugin/storage"
-	"github.com/CoolBitX-Technology/subscan/plugins/balance/dao"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/plugins/balance/http"
 	"github.com/CoolBitX-Technology/subscan/plugins/balance/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/balance/repository"
 	"github.com/CoolBitX-Technology/subscan/plugins/balance/service"
-	"github.com/CoolBitX-Technology/subscan/util"
+	plugin "github.com/itering/subscan-plugin"
+	"github.com/itering/subscan-plugin/router"
+	"github.com/prometheus/common/log"
 	"github.com/shopspring/decimal"
-	"strings"
 )
 
-var srv *service.Service
+var srv model.BalanceService
 
 type Balance struct {
-	d storage.Dao
+	d     m.Dao
+	state model.ChainState
 }
 
 func New() *Balance {
-	return &Balance{}
+	return &Balance{state: repository.NewRpcChainState()}
 }
 
-func (a *Balance) InitDao(d storage.Dao) {
-	srv = service.New(d)
+func (a *Balance) InitDao(d m.Dao) {
+	srv = service.New(repository.NewsqlBalanceRepository(d), a.state)
 	a.d = d
 	a.Migrate()
 }
 
 func (a *Balance) InitHttp() []router.Http {
-	return http.Router(srv)
+	return nil
+}
+
+func (a *Balance) Routes() []m.PluginRoute {
+	return http.Routes(srv)
 }
 
-func (a *Balance) ProcessExtrinsic(*storage.Block, *storage.Extrinsic, []storage.Event) error {
+func (a *Balance) ProcessExtrinsic(*m.Block, *m.Extrinsic, []m.Event) error {
 	return nil
 }
 
-func (a *Balance) ProcessEvent(block *storage.Block, event *storage.Event, fee decimal.Decimal) error {
+// ProcessEvent refreshes the balances of the accounts of a balances or system event, E.g both sides of a
+// Balances.Transfer or the account of System.NewAccount
+func (a *Balance) ProcessEvent(block *m.Block, event *m.Event, fee decimal.Decimal) error {
 	if event == nil {
 		return nil
 	}
-	var paramEvent []storage.EventParam
-	util.UnmarshalAny(&paramEvent, event.Params)
+	return srv.RefreshAccounts(block, event)
+}
 
-	switch fmt.Sprintf("%s-%s", strings.ToLower(event.ModuleId), strings.ToLower(event.EventId)) {
-	case strings.ToLower("System-NewAccount"):
-		return dao.NewAccount(a.d, util.ToString(paramEvent[0].Value))
-	}
+// Rollback drops the balances of the blocks after blockNum
+func (a *Balance) Rollback(blockNum int) error {
+	return srv.Rollback(blockNum)
+}
 
-	return nil
+// InOrder as the first block of an account creates its row and the later ones only move it forward
+func (a *Balance) InOrder() bool {
+	return true
 }
 
 func (a *Balance) SubscribeExtrinsic() []string {
@@ -58,11 +67,11 @@ func (a *Balance) SubscribeExtrinsic() []string {
 }
 
 func (a *Balance) SubscribeEvent() []string {
-	return []string{"system"}
+	return []string{"balances", "system"}
 }
 
 func (a *Balance) Version() string {
-	return "0.1"
+	return "0.2"
 }
 
 func (a *Balance) UiConf() *plugin.UiConfig {
@@ -76,11 +85,30 @@ func (a *Balance) UiConf() *plugin.UiConfig {
 		{Name: "nonce", Label: "nonce"},
 		{Name: "balance", Label: "balance"},
 		{Name: "lock", Label: "lock"},
+		{Name: "free", Label: "free"},
+		{Name: "reserved", Label: "reserved"},
 	}
 	return conf
 }
 
 func (a *Balance) Migrate() {
-	_ = a.d.AutoMigration(&model.Account{})
-	_ = a.d.AddUniqueIndex(&model.Account{}, "address", "address")
+	var e error
+	if e = a.d.AutoMigration(&model.Account{}); e != nil {
+		log.Error(e)
+	}
+	if e = a.d.AutoMigration(&model.History{}); e != nil {
+		log.Error(e)
+	}
+	if e = a.d.AddUniqueIndex(&model.Account{}, "address", "address"); e != nil {
+		log.Error(e)
+	}
+	if e = a.d.AddIndex(&model.Account{}, "block_num", "block_num"); e != nil {
+		log.Error(e)
+	}
+	if e = a.d.AddUniqueIndex(&model.History{}, "address_block_num", "address", "block_num"); e != nil {
+		log.Error(e)
+	}
+	if e = a.d.AddIndex(&model.History{}, "block_num", "block_num"); e != nil {
+		log.Error(e)
+	}
 }
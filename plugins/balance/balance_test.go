@@ -0,0 +1,78 @@
+package balance
+
+import (
+	"testing"
+
+	"github.com/CoolBitX-Technology/subscan/plugins/balance/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/balance/model/mocks"
+	"github.com/CoolBitX-Technology/subscan/plugins/testkit"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	alice = "f4e1f21a11b5b74c2b43f26bff6a046a2e1f16d08bbe0dc9ba7582bdb4745c6b"
+	bob   = "8274c1f83eda177fafc715e5845bcb424a5c5b6939abfedc504c7d72aec18fa8"
+)
+
+func accountData(nonce int, free, reserved, miscFrozen int64) *model.AccountData {
+	data := &model.AccountData{Nonce: nonce}
+	data.Data.Free = decimal.New(free, 0)
+	data.Data.Reserved = decimal.New(reserved, 0)
+	data.Data.MiscFrozen = decimal.New(miscFrozen, 0)
+	return data
+}
+
+func TestFeedBalance(t *testing.T) {
+	state := new(mocks.ChainState)
+	h, err := testkit.New(&Balance{state: state})
+	assert.NoError(t, err)
+	defer h.Close()
+	block := testkit.MustLoadFixture("../testkit/testdata/block_5095844.json")
+	next := testkit.MustLoadFixture("../testkit/testdata/block_5095844.json")
+	next.Block.BlockNum, next.Block.Hash = 5095845, "0x01"
+	for i := range next.Events {
+		next.Events[i].BlockNum = 5095845
+	}
+
+	state.On("AccountData", block.Block.Hash, alice).Return(accountData(1, 100, 20, 5), nil)
+	state.On("AccountData", block.Block.Hash, bob).Return(accountData(0, 193309000000000, 0, 0), nil)
+	state.On("AccountData", "0x01", alice).Return(accountData(2, 50, 20, 0), nil)
+	state.On("AccountData", "0x01", bob).Return(accountData(0, 193309000000050, 0, 0), nil)
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, h.Feed(block))
+		testkit.AssertNoErrors(t, h)
+	}
+	state.AssertNumberOfCalls(t, "AccountData", 2)
+	d := h.Dao("balance")
+	testkit.AssertCount(t, d, "balance_accounts", 2)
+	testkit.AssertCount(t, d, "balance_histories", 2)
+	testkit.AssertRow(t, d, "balance_accounts", map[string]interface{}{
+		"address": alice, "nonce": 1, "balance": 120, "lock": 5, "free": 100, "reserved": 20, "misc_frozen": 5, "block_num": 5095844,
+	})
+
+	assert.NoError(t, h.Feed(next))
+	testkit.AssertNoErrors(t, h)
+	testkit.AssertCount(t, d, "balance_histories", 4)
+	testkit.AssertRow(t, d, "balance_accounts", map[string]interface{}{"address": alice, "nonce": 2, "balance": 70, "lock": 0, "block_num": 5095845})
+
+	// a reindex of an older block leaves the latest balance
+	state.On("AccountData", "0x00", mock.Anything).Return(accountData(0, 1, 0, 0), nil)
+	older := testkit.MustLoadFixture("../testkit/testdata/block_5095844.json")
+	older.Block.BlockNum, older.Block.Hash = 5095843, "0x00"
+	for i := range older.Events {
+		older.Events[i].BlockNum = 5095843
+	}
+	assert.NoError(t, h.Feed(older))
+	testkit.AssertRow(t, d, "balance_accounts", map[string]interface{}{"address": alice, "balance": 70, "block_num": 5095845})
+
+	assert.NoError(t, h.Rollback(5095844))
+	testkit.AssertCount(t, d, "balance_histories", 4)
+	testkit.AssertRow(t, d, "balance_accounts", map[string]interface{}{"address": alice, "nonce": 1, "balance": 120, "lock": 5, "block_num": 5095844})
+
+	assert.NoError(t, h.Rollback(5095842))
+	testkit.AssertCount(t, d, "balance_histories", 0)
+	testkit.AssertCount(t, d, "balance_accounts", 0)
+}
@@ -1,86 +1,77 @@
 package http
 
 import (
-	"encoding/json"
 	"net/http"
 
-	"github.com/CoolBitX-Technology/subscan/plugins/balance/service"
-	"github.com/CoolBitX-Technology/subscan/util/validator"
-	"github.com/itering/subscan-plugin/router"
-	"github.com/pkg/errors"
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/balance/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/CoolBitX-Technology/subscan/util/ss58"
 )
 
 var (
-	svc *service.Service
+	svc model.BalanceService
 )
 
-func Router(s *service.Service) []router.Http {
-	svc = s
-	return []router.Http{
-		{"accounts", accounts},
-	}
+type accountsParams struct {
+	Row  int `json:"row" form:"row" validate:"min=1,max=100"`
+	Page int `json:"page" form:"page" validate:"min=0"`
 }
 
-func accounts(w http.ResponseWriter, r *http.Request) error {
-	p := new(struct {
-		Row  int `json:"row" validate:"min=1,max=100"`
-		Page int `json:"page" validate:"min=0"`
-	})
-	if err := validator.Validate(r.Body, p); err != nil {
-		toJson(w, 10001, nil, err)
-		return nil
-	}
-
-	list, count := svc.GetAccountListJson(p.Page, p.Row)
-
-	toJson(w, 0, map[string]interface{}{
-		"list": list, "count": count,
-	}, nil)
-	return nil
+type historyParams struct {
+	Row     int    `json:"row" form:"row" validate:"min=1,max=100"`
+	Page    int    `json:"page" form:"page" validate:"min=0"`
+	Address string `json:"address" uri:"address"`
 }
 
-type J struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	TTL     int         `json:"ttl"`
-	Data    interface{} `json:"data,omitempty"`
-}
-
-func (j J) Render(w http.ResponseWriter) error {
-	header := w.Header()
-	if val := header["Content-Type"]; len(val) == 0 {
-		header["Content-Type"] = []string{"application/json; charset=utf-8"}
+// Routes serves POST /api/plugin/balance/accounts and GET /api/plugin/balance/history/:address
+func Routes(s model.BalanceService) []m.PluginRoute {
+	svc = s
+	return []m.PluginRoute{
+		{
+			Method: http.MethodPost,
+			Path:   "accounts",
+			Params: func() interface{} { return new(accountsParams) },
+			Handle: accounts,
+		},
+		{
+			Method: http.MethodGet,
+			Path:   "history/:address",
+			Params: func() interface{} { return new(historyParams) },
+			Handle: history,
+		},
 	}
-	return nil
 }
 
-func (j J) WriteContentType(w http.ResponseWriter) {
-	var (
-		jsonBytes []byte
-		err       error
-	)
-	_ = j.Render(w)
-	if jsonBytes, err = json.Marshal(j); err != nil {
-		_ = errors.WithStack(err)
-		return
+// accounts lists the accounts by balance
+func accounts(r *m.PluginRequest) (interface{}, error) {
+	p := r.Params.(*accountsParams)
+	list, err := svc.GetAccountListJson(p.Page, p.Row)
+	if err != nil {
+		return nil, err
 	}
-	if _, err = w.Write(jsonBytes); err != nil {
-		_ = errors.WithStack(err)
+	for i, account := range list {
+		list[i].Address = ss58.Encode(account.Address, util.StringToInt(util.AddressType))
 	}
+	return map[string]interface{}{
+		"list": list, "count": len(list),
+	}, nil
 }
 
-func toJson(w http.ResponseWriter, code int, data interface{}, err error) {
-	j := J{
-		Message: "Success",
-		TTL:     1,
-		Data:    data,
+// history lists the balances of an address, the latest first
+func history(r *m.PluginRequest) (interface{}, error) {
+	p := r.Params.(*historyParams)
+	if p.Address == "" || ss58.Decode(p.Address, util.StringToInt(util.AddressType)) == "" {
+		return nil, m.NewApiError(http.StatusBadRequest, m.AddressValidateError, "Invalid address")
 	}
+	list, err := svc.GetHistoryListJson(p.Page, p.Row, p.Address)
 	if err != nil {
-		j.Message = err.Error()
+		return nil, err
 	}
-	if code != 0 {
-		j.Code = code
+	for i, h := range list {
+		list[i].Address = ss58.Encode(h.Address, util.StringToInt(util.AddressType))
 	}
-	j.WriteContentType(w)
-	_ = j.Render(w)
+	return map[string]interface{}{
+		"list": list, "count": len(list),
+	}, nil
 }
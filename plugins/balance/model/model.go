@@ -1,17 +1,38 @@
 package model
 
 import (
+	"github.com/CoolBitX-Technology/subscan/model"
 	"github.com/shopspring/decimal"
 )
 
+// Account is the balance of an account at the last block changing it, balance is free + reserved and lock
+// the largest frozen
 type Account struct {
-	ID      uint            `gorm:"primary_key" json:"-"`
-	Address string          `sql:"default: null;size:100" json:"address"`
-	Nonce   int             `json:"nonce"`
-	Balance decimal.Decimal `json:"balance" sql:"type:decimal(30,0);"`
-	Lock    decimal.Decimal `json:"lock" sql:"type:decimal(30,0);"`
+	ID         uint            `gorm:"primary_key" json:"-"`
+	Address    string          `sql:"default: null;size:100" json:"address"`
+	Nonce      int             `json:"nonce"`
+	Balance    decimal.Decimal `json:"balance" sql:"type:decimal(30,0);"`
+	Lock       decimal.Decimal `json:"lock" sql:"type:decimal(30,0);"`
+	Free       decimal.Decimal `json:"free" sql:"type:decimal(30,0);"`
+	Reserved   decimal.Decimal `json:"reserved" sql:"type:decimal(30,0);"`
+	MiscFrozen decimal.Decimal `json:"misc_frozen" sql:"type:decimal(30,0);"`
+	FeeFrozen  decimal.Decimal `json:"fee_frozen" sql:"type:decimal(30,0);"`
+	BlockNum   int             `json:"block_num"`
 }
 
+// History is the balance of an account after a block changing it
+type History struct {
+	ID         uint            `gorm:"primary_key" json:"-"`
+	Address    string          `sql:"default: null;size:100" json:"address"`
+	BlockNum   int             `json:"block_num"`
+	Nonce      int             `json:"nonce"`
+	Free       decimal.Decimal `json:"free" sql:"type:decimal(30,0);"`
+	Reserved   decimal.Decimal `json:"reserved" sql:"type:decimal(30,0);"`
+	MiscFrozen decimal.Decimal `json:"misc_frozen" sql:"type:decimal(30,0);"`
+	FeeFrozen  decimal.Decimal `json:"fee_frozen" sql:"type:decimal(30,0);"`
+}
+
+// AccountData is System.Account, a killed account reads zero
 type AccountData struct {
 	Nonce    int `json:"nonce"`
 	RefCount int `json:"ref_count"`
@@ -22,3 +43,23 @@ type AccountData struct {
 		FeeFrozen  decimal.Decimal `json:"feeFrozen"`
 	} `json:"data"`
 }
+
+// ChainState reads the chain storage at a block hash
+type ChainState interface {
+	AccountData(hash, accountId string) (*AccountData, error)
+}
+
+type BalanceService interface {
+	RefreshAccounts(b *model.Block, e *model.Event) error
+	GetAccountListJson(page, row int) ([]Account, error)
+	GetHistoryListJson(page, row int, addr string) ([]History, error)
+	Rollback(blockNum int) error
+}
+
+type BalanceRepository interface {
+	HasHistory(b *model.Block, address string) (bool, error)
+	SaveBalance(b *model.Block, address string, data *AccountData) error
+	GetAccountList(page, row int) ([]Account, error)
+	GetHistoryListByAddr(page, row int, addr string) ([]History, error)
+	Rollback(blockNum int) error
+}
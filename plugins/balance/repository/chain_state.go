@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"github.com/CoolBitX-Technology/subscan/plugins/balance/model"
+	"github.com/itering/substrate-api-rpc/rpc"
+)
+
+type rpcChainState struct{}
+
+// NewRpcChainState reads the storage from the nodes of CHAIN_WS_ENDPOINT
+func NewRpcChainState() model.ChainState {
+	return &rpcChainState{}
+}
+
+func (r *rpcChainState) AccountData(hash, accountId string) (*model.AccountData, error) {
+	raw, err := rpc.ReadStorage(nil, "system", "account", hash, accountId)
+	if err != nil {
+		return nil, err
+	}
+	data := new(model.AccountData)
+	raw.ToAny(data)
+	return data, nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"fmt"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/balance/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/CoolBitX-Technology/subscan/util/ss58"
+	"github.com/prometheus/common/log"
+	"github.com/shopspring/decimal"
+)
+
+const PluginPrefix = "balance"
+
+type sqlBalanceRepository struct {
+	DB m.Dao
+}
+
+func NewsqlBalanceRepository(db m.Dao) model.BalanceRepository {
+	return &sqlBalanceRepository{
+		DB: db,
+	}
+}
+
+func tableName(txn *m.GormDB, record interface{}) string {
+	return fmt.Sprintf("%s_%s", PluginPrefix, txn.DB.Unscoped().NewScope(record).TableName())
+}
+
+// HasHistory is true when the balance of the account was read at the block, the block transaction is read
+// as the balances of the block are not committed yet
+func (s *sqlBalanceRepository) HasHistory(b *m.Block, address string) (bool, error) {
+	txn := s.DB.BlockTxn(b.BlockNum)
+	defer s.DB.DbRollback(txn)
+	var count int
+	if err := txn.DB.Table(tableName(txn, &model.History{})).Where("address = ? AND block_num = ?", address, b.BlockNum).Count(&count).Error; err != nil {
+		return false, err
+	}
+	s.DB.DbCommit(txn)
+	return count > 0, nil
+}
+
+// SaveBalance adds the balance of the block to the history, the account keeps the balance of its latest block,
+// E.g a reindex of old blocks does not overwrite it
+func (s *sqlBalanceRepository) SaveBalance(b *m.Block, address string, data *model.AccountData) error {
+	txn := s.DB.BlockTxn(b.BlockNum)
+	defer s.DB.DbRollback(txn)
+
+	history := model.History{
+		Address:    address,
+		BlockNum:   b.BlockNum,
+		Nonce:      data.Nonce,
+		Free:       data.Data.Free,
+		Reserved:   data.Data.Reserved,
+		MiscFrozen: data.Data.MiscFrozen,
+		FeeFrozen:  data.Data.FeeFrozen,
+	}
+	if err := txn.DB.Table(tableName(txn, &history)).Create(&history).Error; err != nil {
+		return err
+	}
+
+	tableAccount := tableName(txn, &model.Account{})
+	var count int
+	if err := txn.DB.Table(tableAccount).Where("address = ?", address).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		account := model.Account{Address: address}
+		if err := txn.DB.Table(tableAccount).Create(&account).Error; err != nil {
+			return err
+		}
+	}
+	if err := txn.DB.Table(tableAccount).Where("address = ? AND block_num <= ?", address, b.BlockNum).Updates(accountAttrs(&history)).Error; err != nil {
+		return err
+	}
+
+	s.DB.DbCommit(txn)
+	log.Info("New a balance of ", address, " at block ", b.BlockNum)
+	return nil
+}
+
+func accountAttrs(h *model.History) map[string]interface{} {
+	return map[string]interface{}{
+		"nonce":       h.Nonce,
+		"balance":     h.Free.Add(h.Reserved),
+		"lock":        decimal.Max(h.MiscFrozen, h.FeeFrozen),
+		"free":        h.Free,
+		"reserved":    h.Reserved,
+		"misc_frozen": h.MiscFrozen,
+		"fee_frozen":  h.FeeFrozen,
+		"block_num":   h.BlockNum,
+	}
+}
+
+func (s *sqlBalanceRepository) GetAccountList(page, row int) ([]model.Account, error) {
+	var accounts []model.Account
+	opt := m.Option{PluginPrefix: PluginPrefix, Page: page, PageSize: row, Order: "balance desc"}
+	err := s.DB.FindBy(&accounts, nil, &opt)
+	return accounts, err
+}
+
+func (s *sqlBalanceRepository) GetHistoryListByAddr(page, row int, addr string) ([]model.History, error) {
+	var list []model.History
+	opt := m.Option{PluginPrefix: PluginPrefix, Page: page, PageSize: row, Order: "block_num desc"}
+	account := ss58.Decode(addr, util.StringToInt(util.AddressType))
+	err := s.DB.FindBy(&list, map[string]interface{}{"address": account}, &opt)
+	return list, err
+}
+
+// Rollback drops the history after blockNum, the accounts changed after it go back to their latest balance
+// before it or are dropped when they had none
+func (s *sqlBalanceRepository) Rollback(blockNum int) error {
+	if err := s.DB.Delete(&model.History{}, fmt.Sprintf("block_num > %d", blockNum)); err != nil {
+		return err
+	}
+	var changed []model.Account
+	for page := 0; ; page++ {
+		var accounts []model.Account
+		opt := m.Option{PluginPrefix: PluginPrefix, Page: page, PageSize: 1000}
+		if err := s.DB.FindBy(&accounts, []string{fmt.Sprintf("block_num > %d", blockNum)}, &opt); err != nil {
+			return err
+		}
+		changed = append(changed, accounts...)
+		if len(accounts) < opt.PageSize {
+			break
+		}
+	}
+	for _, account := range changed {
+		where := fmt.Sprintf("address = '%s'", account.Address)
+		var last []model.History
+		opt := m.Option{PluginPrefix: PluginPrefix, PageSize: 1, Order: "block_num desc"}
+		if err := s.DB.FindBy(&last, []string{fmt.Sprintf("%s AND block_num <= %d", where, blockNum)}, &opt); err != nil {
+			return err
+		}
+		if len(last) == 0 {
+			if err := s.DB.Delete(&model.Account{}, where); err != nil {
+				return err
+			}
+			continue
+		}
+		txn := s.DB.DbBegin()
+		if err := s.DB.Update(txn, &model.Account{}, []string{where}, accountAttrs(&last[0])).Error; err != nil {
+			s.DB.DbRollback(txn)
+			return err
+		}
+		s.DB.DbCommit(txn)
+	}
+	return nil
+}
@@ -1,21 +1,72 @@
 package service
 
 import (
-	"github.com/CoolBitX-Technology/subscan/plugins/balance/dao"
+	"strings"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/plugins/balance/model"
-	"github.com/itering/subscan-plugin/storage"
+	"github.com/CoolBitX-Technology/subscan/util"
 )
 
 type Service struct {
-	d storage.Dao
+	sql   model.BalanceRepository
+	state model.ChainState
 }
 
-func (s *Service) GetAccountListJson(page, row int) ([]model.Account, int) {
-	return dao.GetAccountList(s.d, page, row)
+func New(r model.BalanceRepository, state model.ChainState) model.BalanceService {
+	return &Service{
+		sql:   r,
+		state: state,
+	}
 }
 
-func New(d storage.Dao) *Service {
-	return &Service{
-		d: d,
+// RefreshAccounts reads System.Account at the block for the accounts of the event, once per account and block
+func (s *Service) RefreshAccounts(b *m.Block, e *m.Event) error {
+	for _, address := range eventAccounts(e) {
+		refreshed, err := s.sql.HasHistory(b, address)
+		if err != nil {
+			return err
+		}
+		if refreshed {
+			continue
+		}
+		data, err := s.state.AccountData(b.Hash, address)
+		if err != nil {
+			return err
+		}
+		if err = s.sql.SaveBalance(b, address, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventAccounts are the AccountId params of the event, E.g from and to of Balances.Transfer
+func eventAccounts(e *m.Event) []string {
+	var params []m.EventParam
+	util.UnmarshalAny(&params, e.Params)
+	var accounts []string
+	for _, p := range params {
+		if !strings.Contains(p.Type, "AccountId") {
+			continue
+		}
+		address := util.TrimHex(util.ToString(p.Value))
+		if address == "" || util.StringInSlice(address, accounts) {
+			continue
+		}
+		accounts = append(accounts, address)
 	}
+	return accounts
+}
+
+func (s *Service) GetAccountListJson(page, row int) ([]model.Account, error) {
+	return s.sql.GetAccountList(page, row)
+}
+
+func (s *Service) GetHistoryListJson(page, row int, addr string) ([]model.History, error) {
+	return s.sql.GetHistoryListByAddr(page, row, addr)
+}
+
+func (s *Service) Rollback(blockNum int) error {
+	return s.sql.Rollback(blockNum)
 }
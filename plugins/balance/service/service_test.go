@@ -0,0 +1,61 @@
+package service_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/balance/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/balance/model/mocks"
+	"github.com/CoolBitX-Technology/subscan/plugins/balance/service"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	alice = "f4e1f21a11b5b74c2b43f26bff6a046a2e1f16d08bbe0dc9ba7582bdb4745c6b"
+	bob   = "8274c1f83eda177fafc715e5845bcb424a5c5b6939abfedc504c7d72aec18fa8"
+)
+
+func TestRefreshAccounts(t *testing.T) {
+	block := m.Block{BlockNum: 5095844, Hash: "0x78f3105efd294a89bef4034c17587fe0d691843e0dd4158a778dfb8a3a7f8e17"}
+	event := func(params ...m.EventParam) *m.Event {
+		raw, _ := json.Marshal(params)
+		return &m.Event{BlockNum: block.BlockNum, ModuleId: "balances", EventId: "Transfer", Params: raw}
+	}
+	data := new(model.AccountData)
+	data.Data.Free = decimal.New(10, 0)
+
+	t.Run("Transfer", func(t *testing.T) {
+		repo, state := new(mocks.BalanceRepository), new(mocks.ChainState)
+		repo.On("HasHistory", &block, alice).Return(false, nil)
+		repo.On("HasHistory", &block, bob).Return(true, nil)
+		repo.On("SaveBalance", &block, alice, data).Return(nil)
+		state.On("AccountData", block.Hash, alice).Return(data, nil)
+
+		e := event(m.EventParam{Type: "AccountId", Value: "0x" + alice}, m.EventParam{Type: "AccountId", Value: bob},
+			m.EventParam{Type: "Balance", Value: "193309000000000"})
+		assert.NoError(t, service.New(repo, state).RefreshAccounts(&block, e))
+		repo.AssertNumberOfCalls(t, "SaveBalance", 1)
+		state.AssertNotCalled(t, "AccountData", block.Hash, bob)
+	})
+
+	t.Run("Same account twice", func(t *testing.T) {
+		repo, state := new(mocks.BalanceRepository), new(mocks.ChainState)
+		repo.On("HasHistory", &block, alice).Return(false, nil)
+		repo.On("SaveBalance", &block, alice, data).Return(nil)
+		state.On("AccountData", block.Hash, alice).Return(data, nil)
+
+		e := event(m.EventParam{Type: "T::AccountId", Value: alice}, m.EventParam{Type: "T::AccountId", Value: alice},
+			m.EventParam{Type: "BalanceStatus", Value: "Free"})
+		assert.NoError(t, service.New(repo, state).RefreshAccounts(&block, e))
+		state.AssertNumberOfCalls(t, "AccountData", 1)
+	})
+
+	t.Run("No account", func(t *testing.T) {
+		repo, state := new(mocks.BalanceRepository), new(mocks.ChainState)
+		e := event(m.EventParam{Type: "DispatchInfo", Value: map[string]interface{}{"weight": 1}})
+		assert.NoError(t, service.New(repo, state).RefreshAccounts(&block, e))
+		repo.AssertNotCalled(t, "HasHistory")
+	})
+}
@@ -1,10 +1,8 @@
 package bond
 
 import (
-	"fmt"
-	"strings"
-
 	m "github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/bond/http"
 	"github.com/CoolBitX-Technology/subscan/plugins/bond/model"
 	"github.com/CoolBitX-Technology/subscan/plugins/bond/repository"
 	"github.com/CoolBitX-Technology/subscan/plugins/bond/service"
@@ -19,16 +17,28 @@ import (
 var srv model.BondService
 
 type Bond struct {
-	d m.Dao
+	d     m.Dao
+	conf  *model.Config
+	state model.ChainState
 }
 
 func New() *Bond {
-	return &Bond{}
+	return &Bond{conf: model.DefaultConfig(), state: repository.NewRpcChainState()}
+}
+
+// Configure takes unbonding_period in seconds and unbonding_blocks, used when the runtime constants have no
+// unbonding period
+func (b *Bond) Configure(c map[string]interface{}) error {
+	conf := model.DefaultConfig()
+	if err := util.RemarshalAny(conf, c); err != nil {
+		return err
+	}
+	b.conf = conf
+	return nil
 }
 
 func (b *Bond) InitDao(d m.Dao) {
-	s := repository.NewsqlBondRepository(d)
-	srv = service.New(s)
+	srv = service.New(repository.NewsqlBondRepository(d), b.state, d.SpecialMetadata, b.conf)
 	b.d = d
 	b.Migrate()
 }
@@ -38,18 +48,49 @@ func (b *Bond) Migrate() {
 	if e = b.d.AutoMigration(&model.Bond{}); e != nil {
 		log.Error(e)
 	}
-	if e = b.d.AddUniqueIndex(&model.Bond{}, "extrinsic_index", "extrinsic_index"); e != nil {
+	if e = b.d.AutoMigration(&model.Ledger{}); e != nil {
+		log.Error(e)
+	}
+	if e = b.d.AddUniqueIndex(&model.Bond{}, "extrinsic_index_event_idx", "extrinsic_index", "event_idx"); e != nil {
 		log.Error(e)
 	}
 	if e = b.d.AddIndex(&model.Bond{}, "account_w_start_at", "account", "start_at"); e != nil {
 		log.Error(e)
 	}
+	if e = b.d.AddIndex(&model.Bond{}, "block_num", "block_num"); e != nil {
+		log.Error(e)
+	}
+	if e = b.d.AddIndex(&model.Bond{}, "status_unlock_era", "status", "unlock_era"); e != nil {
+		log.Error(e)
+	}
+	if e = b.d.AddUniqueIndex(&model.Ledger{}, "stash_block_num", "stash", "block_num"); e != nil {
+		log.Error(e)
+	}
+	if e = b.d.AddIndex(&model.Ledger{}, "controller", "controller"); e != nil {
+		log.Error(e)
+	}
+	if e = b.d.AddIndex(&model.Ledger{}, "block_num", "block_num"); e != nil {
+		log.Error(e)
+	}
+}
+
+func (b *Bond) Migrations() []m.PluginMigration {
+	return []m.PluginMigration{
+		// an extrinsic, E.g a batch, bonds or unbonds several times, the bonds are keyed by their event
+		{Version: 1, Name: "drop unique extrinsic_index", Up: func(d m.Dao) error {
+			return d.RemoveIndex(&model.Bond{}, "extrinsic_index")
+		}},
+	}
 }
 
 func (b *Bond) InitHttp() []router.Http {
 	return nil
 }
 
+func (b *Bond) Routes() []m.PluginRoute {
+	return http.Routes(srv)
+}
+
 func (b *Bond) BondList(page, row int, addr string, status string, locked int) ([]model.Bond, error) {
 	bondlist, err := srv.GetBondListJson(page, row, addr, status, locked)
 
@@ -64,38 +105,33 @@ func (b *Bond) BondList(page, row int, addr string, status string, locked int) (
 	return bondlist, err
 }
 
-func (b *Bond) ProcessExtrinsic(block *m.Block, e *m.Extrinsic, p []m.Event) error {
-	var err error
-	var paramExtrinsic []m.ExtrinsicParam
-	util.UnmarshalAny(&paramExtrinsic, e.Params)
-	c := fmt.Sprintf("%s-%s", strings.ToLower(e.CallModule), strings.ToLower(e.CallModuleFunction))
-	log.Info(c)
-	switch c {
-	case strings.ToLower("Staking-Bond"), strings.ToLower("Staking-Bond_Extra"), strings.ToLower("Staking-Rebond"):
-		if err = srv.NewBondExtrinsic(block, e, paramExtrinsic, "bonded"); err != nil {
-			log.Error(err)
-		}
-		break
-	case strings.ToLower("Staking-Unbond"):
-		if err = srv.NewBondExtrinsic(block, e, paramExtrinsic, "unbonding"); err != nil {
-			log.Error(err)
-		}
-		break
-	}
+// ProcessExtrinsic handles the staking calls changing a ledger without an event, E.g nominate or chill
+func (b *Bond) ProcessExtrinsic(block *m.Block, e *m.Extrinsic, events []m.Event) error {
+	return srv.BondExtrinsic(block, e, events)
+}
 
-	if err != nil {
-		return err
+// ProcessEvent moves the ledger of a stash on Bonded, Unbonded, Withdrawn and Chilled, EraPaid unlocks the
+// unbonding chunks of the era
+func (b *Bond) ProcessEvent(block *m.Block, event *m.Event, fee decimal.Decimal) error {
+	if event == nil {
+		return nil
 	}
+	return srv.StakingEvent(block, event)
+}
 
-	return nil
+// Rollback drops the bonds and ledgers of the blocks after blockNum, rows stored before block_num was recorded
+// are kept
+func (b *Bond) Rollback(blockNum int) error {
+	return srv.Rollback(blockNum)
 }
 
-func (b *Bond) ProcessEvent(block *m.Block, event *m.Event, fee decimal.Decimal) error {
-	return nil
+// InOrder as a ledger and its unbonding chunks are moved from their state at the previous block
+func (b *Bond) InOrder() bool {
+	return true
 }
 
 func (b *Bond) Version() string {
-	return "0.1"
+	return "0.2"
 }
 
 func (b *Bond) UiConf() *ui.UiConfig {
@@ -107,5 +143,5 @@ func (b *Bond) SubscribeExtrinsic() []string {
 }
 
 func (b *Bond) SubscribeEvent() []string {
-	return []string{"balances"}
+	return []string{"staking"}
 }
@@ -0,0 +1,105 @@
+package bond
+
+import (
+	"fmt"
+	"testing"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/bond/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/bond/model/mocks"
+	"github.com/CoolBitX-Technology/subscan/plugins/testkit"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	stash      = "631495cbcbdf6d04a65863ed55e3e84d94337ad37bbd07d1f77f4fef5bfd9934"
+	controller = "5db6ff3eb16cf438f588cd7e3bc1de9d055a4e3beaa888f078b6ff2ab18ba45a"
+)
+
+func hash(blockNum int) string {
+	return fmt.Sprintf("0x%x", blockNum)
+}
+
+// stakingBlock is a block of one staking extrinsic of the controller, or none, and its events
+func stakingBlock(blockNum int, call string, events ...m.ChainEvent) *testkit.Fixture {
+	f := &testkit.Fixture{Block: &m.ChainBlock{BlockNum: blockNum, BlockTimestamp: 1621234908 + blockNum*6, Hash: hash(blockNum)}}
+	if call != "" {
+		f.Extrinsics = []m.ChainExtrinsic{{ExtrinsicIndex: fmt.Sprintf("%d-1", blockNum), BlockNum: blockNum, CallModule: "staking",
+			CallModuleFunction: call, Params: []m.ExtrinsicParam{}, AccountId: controller, Success: true}}
+	}
+	for i, e := range events {
+		e.EventIndex, e.BlockNum, e.ExtrinsicIdx, e.EventIdx = fmt.Sprintf("%d-1", blockNum), blockNum, 1, i
+		f.Events = append(f.Events, e)
+	}
+	return f
+}
+
+func stakingEvent(event string, params ...interface{}) m.ChainEvent {
+	var p []m.EventParam
+	for _, v := range params {
+		p = append(p, m.EventParam{Value: v})
+	}
+	return m.ChainEvent{ModuleId: "staking", EventId: event, Params: p}
+}
+
+func ledger(active int64, era int) *model.StakingLedger {
+	l := &model.StakingLedger{Stash: stash, Total: decimal.New(100, 0), Active: decimal.New(active, 0)}
+	if era > 0 {
+		l.Unlocking = []model.UnlockChunk{{Value: decimal.New(100-active, 0), Era: era}}
+	}
+	return l
+}
+
+func TestBondLifecycle(t *testing.T) {
+	state := new(mocks.ChainState)
+	h, err := testkit.New(&Bond{conf: model.DefaultConfig(), state: state})
+	assert.NoError(t, err)
+	defer h.Close()
+	d := h.Dao("bond")
+	for blockNum := 100; blockNum <= 105; blockNum++ {
+		state.On("Controller", hash(blockNum), stash).Return(controller, nil)
+	}
+	state.On("Ledger", hash(100), controller).Return(ledger(100, 0), nil)
+	state.On("Ledger", hash(101), controller).Return(ledger(100, 0), nil)
+	state.On("Ledger", hash(102), controller).Return(ledger(60, 30), nil)
+	state.On("Ledger", hash(105), controller).Return(ledger(60, 0), nil)
+
+	bonded := stakingBlock(100, "", stakingEvent("Bonded", stash, "100"))
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, h.Feed(bonded))
+		testkit.AssertNoErrors(t, h)
+		testkit.AssertCount(t, d, "bond_bonds", 1)
+		testkit.AssertCount(t, d, "bond_ledgers", 1)
+	}
+	testkit.AssertRow(t, d, "bond_ledgers", map[string]interface{}{"stash": stash, "controller": controller, "status": model.LedgerIdle, "active": 100})
+
+	assert.NoError(t, h.Feed(
+		stakingBlock(101, "nominate"),
+		stakingBlock(102, "unbond", stakingEvent("Unbonded", stash, "40")),
+		stakingBlock(103, "", stakingEvent("EraPaid", 28, "100", "10")),
+	))
+	testkit.AssertNoErrors(t, h)
+	testkit.AssertRow(t, d, "bond_ledgers", map[string]interface{}{"block_num": 102, "status": model.LedgerNominating, "active": 60, "unlocking": 40})
+	testkit.AssertRow(t, d, "bond_bonds", map[string]interface{}{
+		"account": stash, "extrinsic_index": "102-1", "status": model.StatusUnbonding, "amount": "40", "unlock_era": 30,
+		"unlock": false, "unbonding_block_end": 102 + 403200,
+	})
+
+	assert.NoError(t, h.Feed(stakingBlock(104, "", stakingEvent("EraPaid", 29, "100", "10"))))
+	testkit.AssertRow(t, d, "bond_bonds", map[string]interface{}{"extrinsic_index": "102-1", "status": model.StatusUnbonding, "unlock": true})
+
+	assert.NoError(t, h.Feed(stakingBlock(105, "withdraw_unbonded", stakingEvent("Withdrawn", stash, "40"))))
+	testkit.AssertNoErrors(t, h)
+	testkit.AssertRow(t, d, "bond_bonds", map[string]interface{}{"extrinsic_index": "102-1", "status": model.StatusWithdrawn, "unlock": true})
+	testkit.AssertRow(t, d, "bond_ledgers", map[string]interface{}{"block_num": 105, "status": model.LedgerNominating, "unlocking": 0})
+
+	assert.NoError(t, h.Rollback(104))
+	testkit.AssertRow(t, d, "bond_bonds", map[string]interface{}{"extrinsic_index": "102-1", "status": model.StatusUnbonding, "unlock": true})
+	testkit.AssertNoRow(t, d, "bond_ledgers", map[string]interface{}{"block_num": 105})
+	assert.NoError(t, h.Rollback(103))
+	testkit.AssertRow(t, d, "bond_bonds", map[string]interface{}{"extrinsic_index": "102-1", "status": model.StatusUnbonding, "unlock": false})
+	assert.NoError(t, h.Rollback(99))
+	testkit.AssertCount(t, d, "bond_bonds", 0)
+	testkit.AssertCount(t, d, "bond_ledgers", 0)
+}
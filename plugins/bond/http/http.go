@@ -0,0 +1,80 @@
+package http
+
+import (
+	"net/http"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/bond/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/CoolBitX-Technology/subscan/util/ss58"
+)
+
+var (
+	svc model.BondService
+)
+
+type bondListParams struct {
+	Row     int    `json:"row" validate:"min=1,max=100"`
+	Page    int    `json:"page" validate:"min=0"`
+	Address string `json:"address"`
+	Status  string `json:"status" validate:"omitempty"`
+	Locked  int    `json:"locked" validate:"omitempty"`
+}
+
+type ledgerParams struct {
+	Address string `json:"address" uri:"address"`
+}
+
+// Routes serves POST /api/plugin/bond/bond_list and GET /api/plugin/bond/ledger/:address
+func Routes(s model.BondService) []m.PluginRoute {
+	svc = s
+	return []m.PluginRoute{
+		{
+			Method:  http.MethodPost,
+			Path:    "bond_list",
+			Aliases: []string{"scan/bond_list", "wallet/bond_list"},
+			Params:  func() interface{} { return new(bondListParams) },
+			Handle:  bondList,
+		},
+		{
+			Method: http.MethodGet,
+			Path:   "ledger/:address",
+			Params: func() interface{} { return new(ledgerParams) },
+			Handle: ledger,
+		},
+	}
+}
+
+func bondList(r *m.PluginRequest) (interface{}, error) {
+	p := r.Params.(*bondListParams)
+	if p.Address == "" || ss58.Decode(p.Address, util.StringToInt(util.AddressType)) == "" {
+		return nil, m.NewApiError(http.StatusBadRequest, m.AddressValidateError, "Invalid address")
+	}
+	list, err := svc.GetBondListJson(p.Page, p.Row, p.Address, p.Status, p.Locked)
+	if err != nil {
+		return nil, err
+	}
+	for i, bond := range list {
+		list[i].Account = ss58.Encode(bond.Account, util.StringToInt(util.AddressType))
+	}
+	return map[string]interface{}{
+		"list": list, "count": len(list),
+	}, nil
+}
+
+// ledger is the latest ledger of a stash, null before its first bond
+func ledger(r *m.PluginRequest) (interface{}, error) {
+	p := r.Params.(*ledgerParams)
+	if p.Address == "" || ss58.Decode(p.Address, util.StringToInt(util.AddressType)) == "" {
+		return nil, m.NewApiError(http.StatusBadRequest, m.AddressValidateError, "Invalid address")
+	}
+	l, err := svc.GetLedgerJson(p.Address)
+	if err != nil || l == nil {
+		return nil, err
+	}
+	l.Stash = ss58.Encode(l.Stash, util.StringToInt(util.AddressType))
+	if l.Controller != "" {
+		l.Controller = ss58.Encode(l.Controller, util.StringToInt(util.AddressType))
+	}
+	return l, nil
+}
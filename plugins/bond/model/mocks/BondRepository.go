@@ -36,13 +36,161 @@ func (_m *BondRepository) GetBondListByAddr(page int, row int, addr string, stat
 	return r0, r1
 }
 
-// NewBondExtrinsic provides a mock function with given fields: b, e, params, status
-func (_m *BondRepository) NewBondExtrinsic(b *subscanmodel.Block, e *subscanmodel.Extrinsic, params []subscanmodel.ExtrinsicParam, status string) error {
-	ret := _m.Called(b, e, params, status)
+// GetLedger provides a mock function with given fields: b, stash
+func (_m *BondRepository) GetLedger(b *subscanmodel.Block, stash string) (*model.Ledger, error) {
+	ret := _m.Called(b, stash)
+
+	var r0 *model.Ledger
+	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, string) *model.Ledger); ok {
+		r0 = rf(b, stash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Ledger)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*subscanmodel.Block, string) error); ok {
+		r1 = rf(b, stash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLedgerByAddr provides a mock function with given fields: addr
+func (_m *BondRepository) GetLedgerByAddr(addr string) (*model.Ledger, error) {
+	ret := _m.Called(addr)
+
+	var r0 *model.Ledger
+	if rf, ok := ret.Get(0).(func(string) *model.Ledger); ok {
+		r0 = rf(addr)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Ledger)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(addr)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLedgerByController provides a mock function with given fields: b, controller
+func (_m *BondRepository) GetLedgerByController(b *subscanmodel.Block, controller string) (*model.Ledger, error) {
+	ret := _m.Called(b, controller)
+
+	var r0 *model.Ledger
+	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, string) *model.Ledger); ok {
+		r0 = rf(b, controller)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Ledger)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*subscanmodel.Block, string) error); ok {
+		r1 = rf(b, controller)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// NewBond provides a mock function with given fields: b, bond
+func (_m *BondRepository) NewBond(b *subscanmodel.Block, bond *model.Bond) error {
+	ret := _m.Called(b, bond)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, *model.Bond) error); ok {
+		r0 = rf(b, bond)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Rollback provides a mock function with given fields: blockNum
+func (_m *BondRepository) Rollback(blockNum int) error {
+	ret := _m.Called(blockNum)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(blockNum)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveLedger provides a mock function with given fields: b, ledger
+func (_m *BondRepository) SaveLedger(b *subscanmodel.Block, ledger *model.Ledger) error {
+	ret := _m.Called(b, ledger)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, *model.Ledger) error); ok {
+		r0 = rf(b, ledger)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetBondStatus provides a mock function with given fields: b, ids, status
+func (_m *BondRepository) SetBondStatus(b *subscanmodel.Block, ids []uint, status string) error {
+	ret := _m.Called(b, ids, status)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, []uint, string) error); ok {
+		r0 = rf(b, ids, status)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnbondingBonds provides a mock function with given fields: b, stash
+func (_m *BondRepository) UnbondingBonds(b *subscanmodel.Block, stash string) ([]model.Bond, error) {
+	ret := _m.Called(b, stash)
+
+	var r0 []model.Bond
+	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, string) []model.Bond); ok {
+		r0 = rf(b, stash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Bond)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*subscanmodel.Block, string) error); ok {
+		r1 = rf(b, stash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UnlockBonds provides a mock function with given fields: b, era
+func (_m *BondRepository) UnlockBonds(b *subscanmodel.Block, era int) error {
+	ret := _m.Called(b, era)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, *subscanmodel.Extrinsic, []subscanmodel.ExtrinsicParam, string) error); ok {
-		r0 = rf(b, e, params, status)
+	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, int) error); ok {
+		r0 = rf(b, era)
 	} else {
 		r0 = ret.Error(0)
 	}
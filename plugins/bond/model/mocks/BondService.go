@@ -13,13 +13,27 @@ type BondService struct {
 	mock.Mock
 }
 
-// GetBondListJson provides a mock function with given fields: page, row, addr
-func (_m *BondService) GetBondListJson(page int, row int, addr string) ([]model.Bond, error) {
-	ret := _m.Called(page, row, addr)
+// BondExtrinsic provides a mock function with given fields: b, e, events
+func (_m *BondService) BondExtrinsic(b *subscanmodel.Block, e *subscanmodel.Extrinsic, events []subscanmodel.Event) error {
+	ret := _m.Called(b, e, events)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, *subscanmodel.Extrinsic, []subscanmodel.Event) error); ok {
+		r0 = rf(b, e, events)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetBondListJson provides a mock function with given fields: page, row, addr, status, locked
+func (_m *BondService) GetBondListJson(page int, row int, addr string, status string, locked int) ([]model.Bond, error) {
+	ret := _m.Called(page, row, addr, status, locked)
 
 	var r0 []model.Bond
-	if rf, ok := ret.Get(0).(func(int, int, string) []model.Bond); ok {
-		r0 = rf(page, row, addr)
+	if rf, ok := ret.Get(0).(func(int, int, string, string, int) []model.Bond); ok {
+		r0 = rf(page, row, addr, status, locked)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]model.Bond)
@@ -27,8 +41,31 @@ func (_m *BondService) GetBondListJson(page int, row int, addr string) ([]model.
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(int, int, string) error); ok {
-		r1 = rf(page, row, addr)
+	if rf, ok := ret.Get(1).(func(int, int, string, string, int) error); ok {
+		r1 = rf(page, row, addr, status, locked)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLedgerJson provides a mock function with given fields: addr
+func (_m *BondService) GetLedgerJson(addr string) (*model.Ledger, error) {
+	ret := _m.Called(addr)
+
+	var r0 *model.Ledger
+	if rf, ok := ret.Get(0).(func(string) *model.Ledger); ok {
+		r0 = rf(addr)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Ledger)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(addr)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -36,13 +73,27 @@ func (_m *BondService) GetBondListJson(page int, row int, addr string) ([]model.
 	return r0, r1
 }
 
-// NewBondExtrinsic provides a mock function with given fields: b, e, params, status
-func (_m *BondService) NewBondExtrinsic(b *subscanmodel.Block, e *subscanmodel.Extrinsic, params []subscanmodel.ExtrinsicParam, status string) error {
-	ret := _m.Called(b, e, params, status)
+// Rollback provides a mock function with given fields: blockNum
+func (_m *BondService) Rollback(blockNum int) error {
+	ret := _m.Called(blockNum)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(blockNum)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StakingEvent provides a mock function with given fields: b, e
+func (_m *BondService) StakingEvent(b *subscanmodel.Block, e *subscanmodel.Event) error {
+	ret := _m.Called(b, e)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, *subscanmodel.Extrinsic, []subscanmodel.ExtrinsicParam, string) error); ok {
-		r0 = rf(b, e, params, status)
+	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, *subscanmodel.Event) error); ok {
+		r0 = rf(b, e)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -2,12 +2,35 @@ package model
 
 import (
 	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/shopspring/decimal"
 )
 
+// Bond statuses, a bonded row is an amount added to the ledger of the stash and an unbonding row a chunk of
+// its ledger waiting for its era, withdrawn or rebonded once the chunk left the ledger
+const (
+	StatusBonded    = "bonded"
+	StatusUnbonding = "unbonding"
+	StatusWithdrawn = "withdrawn"
+	StatusRebonded  = "rebonded"
+)
+
+// Ledger statuses, a stash with an active stake is idle until it nominates or validates and after a chill
+const (
+	LedgerIdle       = "idle"
+	LedgerNominating = "nominating"
+	LedgerValidating = "validating"
+	LedgerUnbonding  = "unbonding"
+	LedgerUnbonded   = "unbonded"
+)
+
+// Bond is a staking event of a stash, an unbonding chunk is unlocked when its unlock_era starts. Month is the
+// lock of a Darwinia deposit and stays 0 on substrate staking
 type Bond struct {
 	ID                      uint   `gorm:"primary_key" json:"-"`
 	Account                 string `json:"account"`
 	ExtrinsicIndex          string `json:"extrinsic_index" sql:"default: null;size:100"`
+	EventIdx                int    `json:"event_idx"`
+	BlockNum                int    `json:"block_num"`
 	StartAt                 int64  `json:"start_at"`
 	Month                   int    `json:"month"`
 	Amount                  string `json:"amount" sql:"size:100;"`
@@ -19,6 +42,63 @@ type Bond struct {
 	Currency                string `json:"currency"`
 	Unlock                  bool   `json:"unlock"`
 	UnbondingBlockEnd       int    `json:"unbonding_block_end"`
+	UnlockEra               int    `json:"unlock_era"`
+	// blocks setting unlock and the withdrawn or rebonded status, a rollback before them reverts them
+	UnlockBlockNum int `json:"-"`
+	StatusBlockNum int `json:"-"`
+}
+
+// Ledger is Staking.Ledger of a stash after a block changing it, the latest one is the state of the stash
+type Ledger struct {
+	ID         uint            `gorm:"primary_key" json:"-"`
+	Stash      string          `sql:"default: null;size:100" json:"stash"`
+	Controller string          `sql:"default: null;size:100" json:"controller"`
+	BlockNum   int             `json:"block_num"`
+	Status     string          `sql:"size:20" json:"status"`
+	Total      decimal.Decimal `json:"total" sql:"type:decimal(30,0);"`
+	Active     decimal.Decimal `json:"active" sql:"type:decimal(30,0);"`
+	Unlocking  decimal.Decimal `json:"unlocking" sql:"type:decimal(30,0);"`
+}
+
+// StakingLedger is Staking.Ledger of a controller, the chunks are ordered by era
+type StakingLedger struct {
+	Stash     string          `json:"stash"`
+	Total     decimal.Decimal `json:"total"`
+	Active    decimal.Decimal `json:"active"`
+	Unlocking []UnlockChunk   `json:"unlocking"`
+}
+
+// UnlockChunk is an unbonded value, withdrawable from era
+type UnlockChunk struct {
+	Value decimal.Decimal `json:"value"`
+	Era   int             `json:"era"`
+}
+
+// ChainState reads the chain storage at a block hash, empty or nil once the stash is unbonded
+type ChainState interface {
+	// Staking.Bonded, the controller of a stash
+	Controller(hash, stash string) (string, error)
+	Ledger(hash, controller string) (*StakingLedger, error)
+}
+
+// Constants are the unbonding period of a runtime, BondingDuration in eras and its length in blocks and seconds
+type Constants struct {
+	BondingDuration int
+	UnbondingBlocks int
+	UnbondingPeriod int64
+}
+
+// Config is the bond config of plugins.toml, the unbonding period of the runtimes without Babe or
+// Staking.BondingDuration constants
+type Config struct {
+	// seconds from unbond to withdrawable
+	UnbondingPeriod int64 `json:"unbonding_period"`
+	UnbondingBlocks int   `json:"unbonding_blocks"`
+}
+
+// DefaultConfig is 14 days at 6 second blocks
+func DefaultConfig() *Config {
+	return &Config{UnbondingPeriod: 1209600, UnbondingBlocks: 403200}
 }
 
 type BondDelivery interface {
@@ -26,11 +106,23 @@ type BondDelivery interface {
 }
 
 type BondService interface {
-	NewBondExtrinsic(b *model.Block, e *model.Extrinsic, params []model.ExtrinsicParam, status string) error
+	BondExtrinsic(b *model.Block, e *model.Extrinsic, events []model.Event) error
+	StakingEvent(b *model.Block, e *model.Event) error
 	GetBondListJson(page, row int, addr string, status string, locked int) ([]Bond, error)
+	GetLedgerJson(addr string) (*Ledger, error)
+	Rollback(blockNum int) error
 }
 
 type BondRepository interface {
-	NewBondExtrinsic(b *model.Block, e *model.Extrinsic, params []model.ExtrinsicParam, status string) error
+	NewBond(b *model.Block, bond *Bond) error
+	SaveLedger(b *model.Block, ledger *Ledger) error
+	// the latest ledger of the stash until the block, read in the block transaction
+	GetLedger(b *model.Block, stash string) (*Ledger, error)
+	GetLedgerByController(b *model.Block, controller string) (*Ledger, error)
+	UnbondingBonds(b *model.Block, stash string) ([]Bond, error)
+	SetBondStatus(b *model.Block, ids []uint, status string) error
+	UnlockBonds(b *model.Block, era int) error
 	GetBondListByAddr(page, row int, addr string, status string, locked int) ([]Bond, error)
+	GetLedgerByAddr(addr string) (*Ledger, error)
+	Rollback(blockNum int) error
 }
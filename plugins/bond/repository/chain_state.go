@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"github.com/CoolBitX-Technology/subscan/plugins/bond/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/itering/substrate-api-rpc/rpc"
+)
+
+type rpcChainState struct{}
+
+// NewRpcChainState reads the storage from the nodes of CHAIN_WS_ENDPOINT
+func NewRpcChainState() model.ChainState {
+	return &rpcChainState{}
+}
+
+func (r *rpcChainState) Controller(hash, stash string) (string, error) {
+	raw, err := rpc.ReadStorage(nil, "staking", "bonded", hash, stash)
+	if err != nil {
+		return "", err
+	}
+	return util.TrimHex(raw.ToString()), nil
+}
+
+func (r *rpcChainState) Ledger(hash, controller string) (*model.StakingLedger, error) {
+	raw, err := rpc.ReadStorage(nil, "staking", "ledger", hash, controller)
+	if err != nil {
+		return nil, err
+	}
+	ledger := new(model.StakingLedger)
+	raw.ToAny(ledger)
+	// a removed ledger reads empty
+	if ledger.Stash == "" {
+		return nil, nil
+	}
+	ledger.Stash = util.TrimHex(ledger.Stash)
+	return ledger, nil
+}
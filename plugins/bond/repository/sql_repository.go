@@ -10,6 +10,8 @@ import (
 	"github.com/prometheus/common/log"
 )
 
+const PluginPrefix = "bond"
+
 type sqlBondRepository struct {
 	DB m.Dao
 }
@@ -20,58 +22,168 @@ func NewsqlBondRepository(db m.Dao) model.BondRepository {
 	}
 }
 
-func (s *sqlBondRepository) NewBondExtrinsic(b *m.Block, e *m.Extrinsic, params []m.ExtrinsicParam, status string) error {
-	var amount string
-	var bond *model.Bond
-	accoutId := e.AccountId
-	txn := s.DB.DbBegin()
+func tableName(txn *m.GormDB, record interface{}) string {
+	return fmt.Sprintf("%s_%s", PluginPrefix, txn.DB.Unscoped().NewScope(record).TableName())
+}
+
+func (s *sqlBondRepository) NewBond(b *m.Block, bond *model.Bond) error {
+	txn := s.DB.BlockTxn(b.BlockNum)
 	defer s.DB.DbRollback(txn)
+	if err := txn.DB.Table(tableName(txn, bond)).Create(bond).Error; err != nil {
+		return err
+	}
+	s.DB.DbCommit(txn)
+	log.Info("New a ", bond.Status, " bond of ", bond.Account, " with extrinsicIndex: ", bond.ExtrinsicIndex)
+	return nil
+}
 
-	if len(params) == 3 {
-		amount = params[1].Value.(string)
+// SaveLedger keeps one ledger per stash and block, the last change of the block wins
+func (s *sqlBondRepository) SaveLedger(b *m.Block, ledger *model.Ledger) error {
+	txn := s.DB.BlockTxn(b.BlockNum)
+	defer s.DB.DbRollback(txn)
+	table := tableName(txn, ledger)
+	var count int
+	if err := txn.DB.Table(table).Where("stash = ? AND block_num = ?", ledger.Stash, ledger.BlockNum).Count(&count).Error; err != nil {
+		return err
+	}
+	var err error
+	if count == 0 {
+		err = txn.DB.Table(table).Create(ledger).Error
 	} else {
-		amount = params[0].Value.(string)
-	}
-
-	switch status {
-	case "bonded":
-		bond = &model.Bond{
-			ExtrinsicIndex: e.ExtrinsicIndex,
-			Account:        accoutId,
-			StartAt:        int64(b.BlockTimestamp) * 1000,
-			Status:         status,
-			Amount:         amount,
-			ExpireAt:       int64(b.BlockTimestamp) * 1000,
-		}
-	case "unbonding":
-		bond = &model.Bond{
-			ExtrinsicIndex:          e.ExtrinsicIndex,
-			Account:                 accoutId,
-			StartAt:                 int64(b.BlockTimestamp) * 1000,
-			Status:                  status,
-			Amount:                  amount,
-			ExpireAt:                int64(b.BlockTimestamp) * 1000,
-			UnbondingExtrinsicIndex: e.ExtrinsicIndex,
-			UnbondingAt:             int64(b.BlockTimestamp) * 1000,
-			UnbondingEnd:            int64(b.BlockTimestamp+1209600) * 1000,
-			UnbondingBlockEnd:       b.BlockNum + 403200,
+		err = txn.DB.Table(table).Where("stash = ? AND block_num = ?", ledger.Stash, ledger.BlockNum).Updates(map[string]interface{}{
+			"controller": ledger.Controller,
+			"status":     ledger.Status,
+			"total":      ledger.Total,
+			"active":     ledger.Active,
+			"unlocking":  ledger.Unlocking,
+		}).Error
+	}
+	if err != nil {
+		return err
+	}
+	s.DB.DbCommit(txn)
+	return nil
+}
+
+func (s *sqlBondRepository) GetLedger(b *m.Block, stash string) (*model.Ledger, error) {
+	return s.lastLedger(b, "stash = ? AND block_num <= ?", stash, b.BlockNum)
+}
+
+func (s *sqlBondRepository) GetLedgerByController(b *m.Block, controller string) (*model.Ledger, error) {
+	return s.lastLedger(b, "controller = ? AND block_num <= ?", controller, b.BlockNum)
+}
+
+// lastLedger reads the block transaction as the ledgers of the block are not committed yet
+func (s *sqlBondRepository) lastLedger(b *m.Block, where string, args ...interface{}) (*model.Ledger, error) {
+	txn := s.DB.BlockTxn(b.BlockNum)
+	defer s.DB.DbRollback(txn)
+	var list []model.Ledger
+	if err := txn.DB.Table(tableName(txn, &model.Ledger{})).Where(where, args...).Order("block_num desc").Limit(1).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	s.DB.DbCommit(txn)
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return &list[0], nil
+}
+
+// UnbondingBonds are the unbonding chunks of the stash as of the block, a chunk ended by a later block is still
+// one. The rows stored before unlock_era was recorded are left to their unbonding_end
+func (s *sqlBondRepository) UnbondingBonds(b *m.Block, stash string) ([]model.Bond, error) {
+	txn := s.DB.BlockTxn(b.BlockNum)
+	defer s.DB.DbRollback(txn)
+	var list []model.Bond
+	if err := txn.DB.Table(tableName(txn, &model.Bond{})).
+		Where("account = ? AND block_num <= ? AND unlock_era > 0 AND (status = ? OR status_block_num > ?)", stash, b.BlockNum, model.StatusUnbonding, b.BlockNum).
+		Order("unlock_era asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	s.DB.DbCommit(txn)
+	return list, nil
+}
+
+// SetBondStatus ends unbonding chunks, a withdrawn chunk is unlocked
+func (s *sqlBondRepository) SetBondStatus(b *m.Block, ids []uint, status string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	txn := s.DB.BlockTxn(b.BlockNum)
+	defer s.DB.DbRollback(txn)
+	table := tableName(txn, &model.Bond{})
+	if err := txn.DB.Table(table).Where("id IN (?)", ids).Updates(map[string]interface{}{
+		"status": status, "status_block_num": b.BlockNum,
+	}).Error; err != nil {
+		return err
+	}
+	if status == model.StatusWithdrawn {
+		if err := txn.DB.Table(table).Where("id IN (?) AND unlock = ?", ids, false).Updates(map[string]interface{}{
+			"unlock": true, "unlock_block_num": b.BlockNum,
+		}).Error; err != nil {
+			return err
 		}
 	}
+	s.DB.DbCommit(txn)
+	log.Info("Set ", len(ids), " bonds ", status, " at block ", b.BlockNum)
+	return nil
+}
 
-	tableName := fmt.Sprintf("%s_%s", "bond", txn.DB.Unscoped().NewScope(&bond).TableName())
-	query := txn.DB.Table(tableName).Create(&bond)
-	if query.Error == nil {
-		s.DB.DbCommit(txn)
-		log.Info("New a ", status, " extrinsic ", " with extrinsicIndex: ", e.ExtrinsicIndex)
+// UnlockBonds unlocks the unbonding chunks withdrawable in era, those stored up to the block and still unbonding
+// at it. A chunk unlocked by a later block is unlocked at this one
+func (s *sqlBondRepository) UnlockBonds(b *m.Block, era int) error {
+	txn := s.DB.BlockTxn(b.BlockNum)
+	defer s.DB.DbRollback(txn)
+	if err := txn.DB.Table(tableName(txn, &model.Bond{})).
+		Where("block_num <= ? AND unlock_era > 0 AND unlock_era <= ? AND (status = ? OR status_block_num > ?) AND (unlock = ? OR unlock_block_num > ?)",
+			b.BlockNum, era, model.StatusUnbonding, b.BlockNum, false, b.BlockNum).
+		Updates(map[string]interface{}{"unlock": true, "unlock_block_num": b.BlockNum}).Error; err != nil {
+		return err
 	}
-	return query.Error
+	s.DB.DbCommit(txn)
+	return nil
 }
 
 func (s *sqlBondRepository) GetBondListByAddr(page, row int, addr string, status string, locked int) ([]model.Bond, error) {
 	var bondlist []model.Bond
-	opt := m.Option{PluginPrefix: "bond", Page: page, PageSize: row, Order: "start_at desc"}
+	opt := m.Option{PluginPrefix: PluginPrefix, Page: page, PageSize: row, Order: "start_at desc"}
 	account := ss58.Decode(addr, util.StringToInt(util.AddressType))
 	err := s.DB.FindBy(&bondlist, map[string]interface{}{"account": account, "status": status, "unlock": locked}, &opt)
 
 	return bondlist, err
 }
+
+func (s *sqlBondRepository) GetLedgerByAddr(addr string) (*model.Ledger, error) {
+	var list []model.Ledger
+	opt := m.Option{PluginPrefix: PluginPrefix, PageSize: 1, Order: "block_num desc"}
+	stash := ss58.Decode(addr, util.StringToInt(util.AddressType))
+	if err := s.DB.FindBy(&list, map[string]interface{}{"stash": stash}, &opt); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return &list[0], nil
+}
+
+// Rollback drops the bonds and ledgers after blockNum, the chunks unlocked, withdrawn or rebonded after it are
+// unbonding again. Rows stored before block_num was recorded are kept
+func (s *sqlBondRepository) Rollback(blockNum int) error {
+	if err := s.DB.Delete(&model.Bond{}, fmt.Sprintf("block_num > %d", blockNum)); err != nil {
+		return err
+	}
+	if err := s.DB.Delete(&model.Ledger{}, fmt.Sprintf("block_num > %d", blockNum)); err != nil {
+		return err
+	}
+	txn := s.DB.DbBegin()
+	defer s.DB.DbRollback(txn)
+	if err := s.DB.Update(txn, &model.Bond{}, []string{fmt.Sprintf("status_block_num > %d", blockNum)},
+		map[string]interface{}{"status": model.StatusUnbonding, "status_block_num": 0}).Error; err != nil {
+		return err
+	}
+	if err := s.DB.Update(txn, &model.Bond{}, []string{fmt.Sprintf("unlock_block_num > %d", blockNum)},
+		map[string]interface{}{"unlock": false, "unlock_block_num": 0}).Error; err != nil {
+		return err
+	}
+	s.DB.DbCommit(txn)
+	return nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/CoolBitX-Technology/subscan/plugins/bond/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/itering/scale.go/types"
+	"github.com/itering/substrate-api-rpc/metadata"
+)
+
+// constants reads the unbonding period of the runtime of spec once, the config is kept when the metadata has none
+func (s *Service) constants(spec int) *model.Constants {
+	s.constantsMu.Lock()
+	defer s.constantsMu.Unlock()
+	if c, ok := s.specConstants[spec]; ok {
+		return c
+	}
+	instant := s.metadata.Instant(spec)
+	if instant == nil {
+		return ReadConstants(nil, s.conf)
+	}
+	c := ReadConstants(instant, s.conf)
+	s.specConstants[spec] = c
+	return c
+}
+
+// ReadConstants reads Staking.BondingDuration, an era is Staking.SessionsPerEra sessions of Babe.EpochDuration
+// slots of Babe.ExpectedBlockTime milliseconds. The blocks and seconds of conf are kept without them
+func ReadConstants(instant *metadata.Instant, conf *model.Config) *model.Constants {
+	c := model.Constants{UnbondingBlocks: conf.UnbondingBlocks, UnbondingPeriod: conf.UnbondingPeriod}
+	bondingDuration, ok := constant(instant, "Staking", "BondingDuration")
+	if !ok {
+		return &c
+	}
+	c.BondingDuration = int(bondingDuration)
+	sessionsPerEra, ok := constant(instant, "Staking", "SessionsPerEra")
+	if !ok {
+		return &c
+	}
+	epochDuration, ok := constant(instant, "Babe", "EpochDuration")
+	if !ok {
+		return &c
+	}
+	blockTime, ok := constant(instant, "Babe", "ExpectedBlockTime")
+	if !ok {
+		return &c
+	}
+	blocks := bondingDuration * sessionsPerEra * epochDuration
+	c.UnbondingBlocks = int(blocks)
+	c.UnbondingPeriod = int64(blocks * blockTime / 1000)
+	return &c
+}
+
+// constant decodes a constant of an unsigned integer type, E.g u32 or Moment, with the type registry
+func constant(instant *metadata.Instant, module, name string) (uint64, bool) {
+	if instant == nil {
+		return 0, false
+	}
+	for _, m := range instant.Metadata.Modules {
+		if !strings.EqualFold(m.Name, module) {
+			continue
+		}
+		for _, c := range m.Constants {
+			if c.Name != name {
+				continue
+			}
+			for _, raw := range constantEncodings(instant.MetadataVersion, c.ConstantsValue) {
+				if value, ok := decodeUint(c.Type, raw); ok {
+					return value, true
+				}
+			}
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// constantEncodings are the bytes a constant value may stand for, scale.go keeps them as text since metadata v14
+// when they are printable and as hex otherwise, E.g "0000" is 0x0000 or 0x30303030
+func constantEncodings(metadataVersion int, value string) [][]byte {
+	if metadataVersion < 14 {
+		return [][]byte{util.HexToBytes(util.TrimHex(value))}
+	}
+	var encodings [][]byte
+	if b, err := hex.DecodeString(value); err == nil && !printable(b) {
+		encodings = append(encodings, b)
+	}
+	return append(encodings, []byte(value))
+}
+
+// printable are the bytes scale.go decodes as text
+func printable(b []byte) bool {
+	for _, c := range b {
+		if c > 127 || c < 32 && c != 9 && c != 10 && c != 13 {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeUint decodes raw as typeString when it is exactly one value of an unsigned integer type, the byte
+// appended is read by a value too short
+func decodeUint(typeString string, raw []byte) (value uint64, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			value, ok = 0, false
+		}
+	}()
+	decoder := types.ScaleDecoder{}
+	decoder.Init(types.ScaleBytes{Data: append(append([]byte{}, raw...), 0)}, nil)
+	decoded := decoder.ProcessAndUpdateData(typeString)
+	if decoder.Data.Offset != len(raw) {
+		return 0, false
+	}
+	switch v := decoded.(type) {
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	}
+	return 0, false
+}
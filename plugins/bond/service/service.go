@@ -1,24 +1,267 @@
 package service
 
 import (
+	"fmt"
+	"strings"
+	"sync"
+
 	m "github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/plugins/bond/model"
+	"github.com/CoolBitX-Technology/subscan/util"
 )
 
 type Service struct {
-	sql model.BondRepository
+	sql      model.BondRepository
+	state    model.ChainState
+	metadata *m.SpecMetadata
+	conf     *model.Config
+
+	constantsMu sync.Mutex
+	// specConstants are the constants read from the metadata of each spec
+	specConstants map[int]*model.Constants
 }
 
-func New(b model.BondRepository) model.BondService {
+func New(r model.BondRepository, state model.ChainState, specialMetadata func(int) string, conf *model.Config) model.BondService {
 	return &Service{
-		sql: b,
+		sql:           r,
+		state:         state,
+		metadata:      m.NewSpecMetadata(specialMetadata),
+		conf:          conf,
+		specConstants: make(map[int]*model.Constants),
+	}
+}
+
+// BondExtrinsic handles the staking calls of a controller without an event, nominate and validate set the role
+// of the stash, rebond, chill and withdraw_unbonded emit no Bonded, Chilled or Withdrawn on older runtimes
+func (s *Service) BondExtrinsic(b *m.Block, e *m.Extrinsic, events []m.Event) error {
+	if !e.Success {
+		return nil
+	}
+	controller := util.TrimHex(e.AccountId)
+	switch strings.ToLower(e.CallModuleFunction) {
+	case "nominate":
+		_, err := s.refreshController(b, controller, model.LedgerNominating)
+		return err
+	case "validate":
+		_, err := s.refreshController(b, controller, model.LedgerValidating)
+		return err
+	case "chill":
+		if hasEvent(events, "Chilled") {
+			return nil
+		}
+		_, err := s.refreshController(b, controller, model.LedgerIdle)
+		return err
+	case "withdraw_unbonded":
+		if hasEvent(events, "Withdrawn") {
+			return nil
+		}
+		_, err := s.refreshController(b, controller, "")
+		return err
+	case "rebond":
+		if hasEvent(events, "Bonded") {
+			return nil
+		}
+		ledger, err := s.refreshController(b, controller, "")
+		if err != nil || ledger == nil {
+			return err
+		}
+		var params []m.ExtrinsicParam
+		util.UnmarshalAny(&params, e.Params)
+		if len(params) == 0 {
+			return nil
+		}
+		return s.sql.NewBond(b, &model.Bond{
+			ExtrinsicIndex: e.ExtrinsicIndex,
+			BlockNum:       b.BlockNum,
+			Account:        ledger.Stash,
+			StartAt:        int64(b.BlockTimestamp) * 1000,
+			ExpireAt:       int64(b.BlockTimestamp) * 1000,
+			Status:         model.StatusBonded,
+			Amount:         util.ToString(params[0].Value),
+		})
+	}
+	return nil
+}
+
+func hasEvent(events []m.Event, event string) bool {
+	for _, e := range events {
+		if strings.EqualFold(e.ModuleId, "staking") && e.EventId == event {
+			return true
+		}
+	}
+	return false
+}
+
+// StakingEvent moves the ledger of the stash of Bonded, Unbonded, Withdrawn or Chilled, an unbonding chunk is
+// unlocked by the EraPaid (EraPayout before) ending the era before its unlock era
+func (s *Service) StakingEvent(b *m.Block, e *m.Event) error {
+	var params []m.EventParam
+	util.UnmarshalAny(&params, e.Params)
+	if len(params) == 0 {
+		return nil
+	}
+	switch e.EventId {
+	case "EraPaid", "EraPayout":
+		return s.sql.UnlockBonds(b, util.IntFromInterface(params[0].Value)+1)
+	case "Chilled":
+		_, err := s.refreshStash(b, util.TrimHex(util.ToString(params[0].Value)), model.LedgerIdle)
+		return err
+	case "Withdrawn":
+		_, err := s.refreshStash(b, util.TrimHex(util.ToString(params[0].Value)), "")
+		return err
+	case "Bonded", "Unbonded":
+		if len(params) > 1 {
+			return s.newBond(b, e, util.TrimHex(util.ToString(params[0].Value)), util.ToString(params[1].Value))
+		}
+	}
+	return nil
+}
+
+// newBond adds the amount bonded or unbonded by the event, an unbonding chunk ends after the unbonding period
+// of the runtime
+func (s *Service) newBond(b *m.Block, e *m.Event, stash, amount string) error {
+	ledger, err := s.refreshStash(b, stash, "")
+	if err != nil {
+		return err
+	}
+	extrinsicIndex := fmt.Sprintf("%d-%d", e.BlockNum, e.ExtrinsicIdx)
+	bond := model.Bond{
+		ExtrinsicIndex: extrinsicIndex,
+		EventIdx:       e.EventIdx,
+		BlockNum:       b.BlockNum,
+		Account:        stash,
+		StartAt:        int64(b.BlockTimestamp) * 1000,
+		ExpireAt:       int64(b.BlockTimestamp) * 1000,
+		Status:         model.StatusBonded,
+		Amount:         amount,
+	}
+	if e.EventId == "Unbonded" {
+		c := s.constants(b.SpecVersion)
+		bond.Status = model.StatusUnbonding
+		bond.UnbondingExtrinsicIndex = extrinsicIndex
+		bond.UnbondingAt = int64(b.BlockTimestamp) * 1000
+		bond.UnbondingEnd = (int64(b.BlockTimestamp) + c.UnbondingPeriod) * 1000
+		bond.UnbondingBlockEnd = b.BlockNum + c.UnbondingBlocks
+		bond.ExpireAt = bond.UnbondingEnd
+		// the chunk is the last one, merged into it when unbonded in the same era
+		if ledger != nil && len(ledger.Unlocking) > 0 {
+			bond.UnlockEra = ledger.Unlocking[len(ledger.Unlocking)-1].Era
+		}
+	}
+	return s.sql.NewBond(b, &bond)
+}
+
+// refreshController reads the ledger of the controller, a removed ledger is found by the stored controller
+func (s *Service) refreshController(b *m.Block, controller, role string) (*model.StakingLedger, error) {
+	ledger, err := s.state.Ledger(b.Hash, controller)
+	if err != nil {
+		return nil, err
 	}
+	stash := ""
+	if ledger != nil {
+		stash = ledger.Stash
+	} else {
+		last, err := s.sql.GetLedgerByController(b, controller)
+		if err != nil || last == nil {
+			return nil, err
+		}
+		stash = last.Stash
+	}
+	return ledger, s.saveLedger(b, stash, controller, ledger, role)
 }
 
-func (s *Service) NewBondExtrinsic(b *m.Block, e *m.Extrinsic, params []m.ExtrinsicParam, status string) (err error) {
-	return s.sql.NewBondExtrinsic(b, e, params, status)
+func (s *Service) refreshStash(b *m.Block, stash, role string) (*model.StakingLedger, error) {
+	if stash == "" {
+		return nil, nil
+	}
+	controller, err := s.state.Controller(b.Hash, stash)
+	if err != nil {
+		return nil, err
+	}
+	var ledger *model.StakingLedger
+	if controller != "" {
+		if ledger, err = s.state.Ledger(b.Hash, controller); err != nil {
+			return nil, err
+		}
+	}
+	return ledger, s.saveLedger(b, stash, controller, ledger, role)
+}
+
+// saveLedger stores the ledger of the stash at the block and ends its unbonding chunks no longer in it, the
+// chunks before the first era left are withdrawn and the missing ones after it rebonded
+func (s *Service) saveLedger(b *m.Block, stash, controller string, ledger *model.StakingLedger, role string) error {
+	last, err := s.sql.GetLedger(b, stash)
+	if err != nil || ledger == nil && last == nil {
+		return err
+	}
+	l := model.Ledger{Stash: stash, Controller: controller, BlockNum: b.BlockNum}
+	if ledger != nil {
+		l.Total, l.Active = ledger.Total, ledger.Active
+		for _, chunk := range ledger.Unlocking {
+			l.Unlocking = l.Unlocking.Add(chunk.Value)
+		}
+	} else if last != nil && controller == "" {
+		l.Controller = last.Controller
+	}
+	l.Status = nextStatus(last, ledger, role)
+	if err = s.sql.SaveLedger(b, &l); err != nil {
+		return err
+	}
+
+	chunks, err := s.sql.UnbondingBonds(b, stash)
+	if err != nil {
+		return err
+	}
+	eras := make(map[int]bool)
+	firstEra := -1
+	if ledger != nil {
+		for _, chunk := range ledger.Unlocking {
+			eras[chunk.Era] = true
+			if firstEra == -1 || chunk.Era < firstEra {
+				firstEra = chunk.Era
+			}
+		}
+	}
+	var withdrawn, rebonded []uint
+	for _, chunk := range chunks {
+		switch {
+		case eras[chunk.UnlockEra]:
+		case firstEra == -1 || chunk.UnlockEra < firstEra:
+			withdrawn = append(withdrawn, chunk.ID)
+		default:
+			rebonded = append(rebonded, chunk.ID)
+		}
+	}
+	if err = s.sql.SetBondStatus(b, withdrawn, model.StatusWithdrawn); err != nil {
+		return err
+	}
+	return s.sql.SetBondStatus(b, rebonded, model.StatusRebonded)
+}
+
+// nextStatus is the status of the stash after the block, E.g a stash unbonding all of its active stake is
+// unbonding until its ledger is removed, a rebond makes it idle again
+func nextStatus(last *model.Ledger, ledger *model.StakingLedger, role string) string {
+	switch {
+	case ledger == nil:
+		return model.LedgerUnbonded
+	case ledger.Active.IsZero():
+		return model.LedgerUnbonding
+	case role != "":
+		return role
+	case last != nil && (last.Status == model.LedgerNominating || last.Status == model.LedgerValidating):
+		return last.Status
+	}
+	return model.LedgerIdle
 }
 
 func (s *Service) GetBondListJson(page, row int, addr string, status string, locked int) ([]model.Bond, error) {
 	return s.sql.GetBondListByAddr(page, row, addr, status, locked)
 }
+
+func (s *Service) GetLedgerJson(addr string) (*model.Ledger, error) {
+	return s.sql.GetLedgerByAddr(addr)
+}
+
+func (s *Service) Rollback(blockNum int) error {
+	return s.sql.Rollback(blockNum)
+}
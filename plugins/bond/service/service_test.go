@@ -1,14 +1,23 @@
 package service_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	m "github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/plugins/bond/model"
 	"github.com/CoolBitX-Technology/subscan/plugins/bond/model/mocks"
 	"github.com/CoolBitX-Technology/subscan/plugins/bond/service"
+	"github.com/itering/scale.go/types"
+	"github.com/itering/substrate-api-rpc/metadata"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	stash      = "631495cbcbdf6d04a65863ed55e3e84d94337ad37bbd07d1f77f4fef5bfd9934"
+	controller = "5db6ff3eb16cf438f588cd7e3bc1de9d055a4e3beaa888f078b6ff2ab18ba45a"
 )
 
 type page struct {
@@ -17,6 +26,8 @@ type page struct {
 	Address string
 }
 
+func noMetadata(int) string { return "" }
+
 func TestGetBondList(t *testing.T) {
 	mockBondListRepo := new(mocks.BondRepository)
 
@@ -34,48 +45,162 @@ func TestGetBondList(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		p := page{Row: 10, Page: 0, Address: "12BnVhXxGBZXoq9QAkSv9UtVcdBs1k38yNx6sHUJWasTgYrm"}
 		mockBondListRepo.On("GetBondListByAddr", p.Page, p.Row, p.Address, "bonding", 0).Return(mockBond, nil)
-		s := service.New(mockBondListRepo)
+		s := service.New(mockBondListRepo, new(mocks.ChainState), noMetadata, model.DefaultConfig())
 		bonds, _ := s.GetBondListJson(p.Page, p.Row, p.Address, "bonding", 0)
 		assert.Equal(t, len(bonds), 1)
 		mockBondListRepo.AssertCalled(t, "GetBondListByAddr", p.Page, p.Row, p.Address, "bonding", 0)
 	})
 }
 
-func TestNewBondExtrinsic(t *testing.T) {
-	mockBondListRepo := new(mocks.BondRepository)
+func event(id string, eventIdx int, params ...interface{}) *m.Event {
+	var p []m.EventParam
+	for _, v := range params {
+		p = append(p, m.EventParam{Value: v})
+	}
+	raw, _ := json.Marshal(p)
+	return &m.Event{BlockNum: 5099018, ExtrinsicIdx: 3, EventIdx: eventIdx, ModuleId: "staking", EventId: id, Params: raw}
+}
 
-	mockBlock := m.Block{
-		BlockNum:       5099018,
-		BlockTimestamp: 1621234908,
-		Hash:           "0x51df7d36dd2d9d4e2987da8a3f67d6334e6af0dfa399175e6d581663e196f065",
-		SpecVersion:    30,
-		Validator:      "9c665073980c9bdbd5620ef9a860b9f1efbeda8f10e13ef7431f6970d765a257",
-		Finalized:      true,
+func stakingLedger(active int64, chunks ...int) *model.StakingLedger {
+	l := &model.StakingLedger{Stash: stash, Active: decimal.New(active, 0), Total: decimal.New(active, 0)}
+	for i := 0; i+1 < len(chunks); i += 2 {
+		l.Unlocking = append(l.Unlocking, model.UnlockChunk{Value: decimal.New(int64(chunks[i]), 0), Era: chunks[i+1]})
+		l.Total = l.Total.Add(decimal.New(int64(chunks[i]), 0))
 	}
+	return l
+}
+
+// ledgerOf matches the ledger saved, the decimals by value
+func ledgerOf(status string, active, unlocking int64) interface{} {
+	return mock.MatchedBy(func(l *model.Ledger) bool {
+		return l.Stash == stash && l.Controller == controller && l.BlockNum == 5099018 && l.Status == status &&
+			l.Active.Equal(decimal.New(active, 0)) && l.Unlocking.Equal(decimal.New(unlocking, 0))
+	})
+}
+
+func TestStakingEvent(t *testing.T) {
+	block := m.Block{BlockNum: 5099018, BlockTimestamp: 1621234908, Hash: "0x51df", SpecVersion: 30}
+	chunks := []model.Bond{{ID: 1, UnlockEra: 10}, {ID: 2, UnlockEra: 12}, {ID: 3, UnlockEra: 13}}
+	nominating := &model.Ledger{Stash: stash, Controller: controller, Status: model.LedgerNominating}
+
+	t.Run("Unbonded", func(t *testing.T) {
+		repo, state := new(mocks.BondRepository), new(mocks.ChainState)
+		state.On("Controller", block.Hash, stash).Return(controller, nil)
+		state.On("Ledger", block.Hash, controller).Return(stakingLedger(40, 20, 12, 40, 14), nil)
+		repo.On("GetLedger", &block, stash).Return(nominating, nil)
+		repo.On("SaveLedger", &block, mock.Anything).Return(nil)
+		repo.On("UnbondingBonds", &block, stash).Return(chunks, nil)
+		repo.On("SetBondStatus", &block, mock.Anything, mock.Anything).Return(nil)
+		repo.On("NewBond", &block, mock.Anything).Return(nil)
 
-	mockExtrinsic := m.Extrinsic{
-		ExtrinsicIndex:     "5099018-3",
-		CallModuleFunction: "bond",
-		CallModule:         "staking",
-		AccountId:          "631495cbcbdf6d04a65863ed55e3e84d94337ad37bbd07d1f77f4fef5bfd9934",
-		Signature:          "0x5c313d8a1711708c67bba4e323714b5cc376da7f80f17d208bdaaa692b04245a8444b2b38728d432c1568af6fa4d69e9304bfbf801f5db41f9c57c301c3f130d",
-		Nonce:              2,
-		Era:                "a503",
-		ExtrinsicHash:      "0x837887bc7f1a556a8b5570b32de2e821ec4b84868cce6b1793fa81e3298b0eb1",
-		Success:            true,
-		Fee:                decimal.New(158000047, 0),
+		s := service.New(repo, state, noMetadata, model.DefaultConfig())
+		assert.NoError(t, s.StakingEvent(&block, event("Unbonded", 4, "0x"+stash, "40")))
+		repo.AssertCalled(t, "SaveLedger", &block, ledgerOf(model.LedgerNominating, 40, 60))
+		repo.AssertCalled(t, "SetBondStatus", &block, []uint{1}, model.StatusWithdrawn)
+		repo.AssertCalled(t, "SetBondStatus", &block, []uint{3}, model.StatusRebonded)
+		repo.AssertCalled(t, "NewBond", &block, &model.Bond{
+			Account: stash, ExtrinsicIndex: "5099018-3", EventIdx: 4, BlockNum: 5099018, Amount: "40",
+			Status: model.StatusUnbonding, StartAt: 1621234908000, ExpireAt: 1622444508000,
+			UnbondingExtrinsicIndex: "5099018-3", UnbondingAt: 1621234908000, UnbondingEnd: 1622444508000,
+			UnbondingBlockEnd: 5502218, UnlockEra: 14,
+		})
+	})
+
+	t.Run("Withdrawn all", func(t *testing.T) {
+		repo, state := new(mocks.BondRepository), new(mocks.ChainState)
+		state.On("Controller", block.Hash, stash).Return("", nil)
+		repo.On("GetLedger", &block, stash).Return(&model.Ledger{Stash: stash, Controller: controller, Status: model.LedgerUnbonding}, nil)
+		repo.On("SaveLedger", &block, mock.Anything).Return(nil)
+		repo.On("UnbondingBonds", &block, stash).Return(chunks, nil)
+		repo.On("SetBondStatus", &block, mock.Anything, mock.Anything).Return(nil)
+
+		s := service.New(repo, state, noMetadata, model.DefaultConfig())
+		assert.NoError(t, s.StakingEvent(&block, event("Withdrawn", 2, stash, "60")))
+		repo.AssertCalled(t, "SaveLedger", &block, ledgerOf(model.LedgerUnbonded, 0, 0))
+		repo.AssertCalled(t, "SetBondStatus", &block, []uint{1, 2, 3}, model.StatusWithdrawn)
+		state.AssertNotCalled(t, "Ledger", mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "NewBond", mock.Anything, mock.Anything)
+	})
+
+	t.Run("EraPaid", func(t *testing.T) {
+		repo := new(mocks.BondRepository)
+		repo.On("UnlockBonds", &block, 28).Return(nil)
+		s := service.New(repo, new(mocks.ChainState), noMetadata, model.DefaultConfig())
+		assert.NoError(t, s.StakingEvent(&block, event("EraPaid", 9, 27, "100", "10")))
+		repo.AssertCalled(t, "UnlockBonds", &block, 28)
+	})
+}
+
+func TestBondExtrinsic(t *testing.T) {
+	block := m.Block{BlockNum: 5099018, BlockTimestamp: 1621234908, Hash: "0x51df", SpecVersion: 30}
+	extrinsic := func(function string, params ...m.ExtrinsicParam) *m.Extrinsic {
+		raw, _ := json.Marshal(params)
+		return &m.Extrinsic{ExtrinsicIndex: "5099018-3", CallModule: "staking", CallModuleFunction: function,
+			AccountId: controller, Params: raw, Success: true}
 	}
 
-	mockExtrinsicParam := []m.ExtrinsicParam{
-		{Type: "AccountId", Value: "5db6ff3eb16cf438f588cd7e3bc1de9d055a4e3beaa888f078b6ff2ab18ba45a"},
-		{Type: "Balance", Value: "142408084752"},
+	repo, state := new(mocks.BondRepository), new(mocks.ChainState)
+	state.On("Ledger", block.Hash, controller).Return(stakingLedger(100), nil)
+	repo.On("GetLedger", &block, stash).Return(&model.Ledger{Stash: stash, Controller: controller, Status: model.LedgerNominating}, nil)
+	repo.On("SaveLedger", &block, mock.Anything).Return(nil)
+	repo.On("UnbondingBonds", &block, stash).Return(nil, nil)
+	repo.On("SetBondStatus", &block, mock.Anything, mock.Anything).Return(nil)
+	repo.On("NewBond", &block, mock.Anything).Return(nil)
+	s := service.New(repo, state, noMetadata, model.DefaultConfig())
+
+	// the Chilled event moves the ledger
+	assert.NoError(t, s.BondExtrinsic(&block, extrinsic("chill"), []m.Event{*event("Chilled", 1, stash)}))
+	repo.AssertNotCalled(t, "SaveLedger", mock.Anything, mock.Anything)
+
+	assert.NoError(t, s.BondExtrinsic(&block, extrinsic("chill"), nil))
+	repo.AssertCalled(t, "SaveLedger", &block, ledgerOf(model.LedgerIdle, 100, 0))
+
+	assert.NoError(t, s.BondExtrinsic(&block, extrinsic("validate"), nil))
+	repo.AssertCalled(t, "SaveLedger", &block, ledgerOf(model.LedgerValidating, 100, 0))
+
+	assert.NoError(t, s.BondExtrinsic(&block, extrinsic("rebond", m.ExtrinsicParam{Name: "value", Type: "Compact<BalanceOf>", Value: "20"}), nil))
+	repo.AssertCalled(t, "NewBond", &block, &model.Bond{Account: stash, ExtrinsicIndex: "5099018-3", BlockNum: 5099018, Amount: "20",
+		Status: model.StatusBonded, StartAt: 1621234908000, ExpireAt: 1621234908000})
+
+	failed := extrinsic("nominate")
+	failed.Success = false
+	assert.NoError(t, s.BondExtrinsic(&block, failed, nil))
+	repo.AssertNumberOfCalls(t, "SaveLedger", 3)
+}
+
+func constantsInstant(version int, hex func(string) string) *metadata.Instant {
+	u32, u64, moment := "U32", "U64", "U64"
+	if version < 14 {
+		u32, u64, moment = "EraIndex", "u64", "Moment"
 	}
+	return &metadata.Instant{MetadataVersion: version, Metadata: types.MetadataTag{Modules: []types.MetadataModules{
+		{Name: "Babe", Constants: []types.MetadataConstants{
+			{Name: "EpochDuration", Type: u64, ConstantsValue: hex("6009000000000000")},
+			{Name: "ExpectedBlockTime", Type: moment, ConstantsValue: hex("7017000000000000")},
+		}},
+		{Name: "Staking", Constants: []types.MetadataConstants{
+			{Name: "SessionsPerEra", Type: u32, ConstantsValue: hex("06000000")},
+			{Name: "BondingDuration", Type: u32, ConstantsValue: hex("1c000000")},
+		}},
+	}}}
+}
 
-	t.Run("Sucess", func(t *testing.T) {
-		s := service.New(mockBondListRepo)
-		mockBondListRepo.On("NewBondExtrinsic", &mockBlock, &mockExtrinsic, mockExtrinsicParam, "bonded").Return(nil)
-		e := s.NewBondExtrinsic(&mockBlock, &mockExtrinsic, mockExtrinsicParam, "bonded")
-		assert.NoError(t, e)
-		mockBondListRepo.AssertCalled(t, "NewBondExtrinsic", &mockBlock, &mockExtrinsic, mockExtrinsicParam, "bonded")
-	})
+func TestReadConstants(t *testing.T) {
+	conf := &model.Config{UnbondingPeriod: 60, UnbondingBlocks: 10}
+	polkadot := &model.Constants{BondingDuration: 28, UnbondingBlocks: 403200, UnbondingPeriod: 2419200}
+	assert.Equal(t, polkadot, service.ReadConstants(constantsInstant(12, func(v string) string { return "0x" + v }), conf))
+	assert.Equal(t, polkadot, service.ReadConstants(constantsInstant(14, func(v string) string { return v }), conf))
+
+	noBabe := constantsInstant(14, func(v string) string { return v })
+	noBabe.Metadata.Modules = noBabe.Metadata.Modules[1:]
+	assert.Equal(t, &model.Constants{BondingDuration: 28, UnbondingBlocks: 10, UnbondingPeriod: 60}, service.ReadConstants(noBabe, conf))
+	assert.Equal(t, &model.Constants{UnbondingBlocks: 10, UnbondingPeriod: 60}, service.ReadConstants(nil, conf))
+
+	// scale.go keeps printable bytes as text since v14, 0x30303030 is "0000"
+	printable := constantsInstant(14, func(v string) string { return v })
+	printable.Metadata.Modules[1].Constants[1].ConstantsValue = "0000"
+	assert.Equal(t, 808464432, service.ReadConstants(printable, conf).BondingDuration)
+	unknown := constantsInstant(14, func(v string) string { return v })
+	unknown.Metadata.Modules[1].Constants[1].Type = "Unknown"
+	assert.Equal(t, &model.Constants{UnbondingBlocks: 10, UnbondingPeriod: 60}, service.ReadConstants(unknown, conf))
 }
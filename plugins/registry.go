@@ -1,24 +1,37 @@
 package plugins
 
 import (
+	"fmt"
+	"io"
 	"reflect"
+	"sort"
 	"strings"
 
+	"github.com/CoolBitX-Technology/subscan/configs"
 	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/balance"
 	"github.com/CoolBitX-Technology/subscan/plugins/bond"
+	"github.com/CoolBitX-Technology/subscan/plugins/remote"
 	"github.com/CoolBitX-Technology/subscan/plugins/reward"
+	"github.com/CoolBitX-Technology/subscan/plugins/system"
 	"github.com/CoolBitX-Technology/subscan/plugins/transfers"
+	"github.com/CoolBitX-Technology/subscan/util"
 	"github.com/prometheus/common/log"
 )
 
 type PluginFactory model.Plugin
 
-var RegisteredPlugins = make(map[string]PluginFactory)
+var (
+	// RegisteredPlugins are the enabled plugins
+	RegisteredPlugins = make(map[string]PluginFactory)
+	disabledPlugins   = make(map[string]PluginFactory)
+	pluginConfigs     = make(map[string]map[string]interface{})
+)
 
 // register local plugin
 func init() {
-	// registerNative(balance.New())
-	// registerNative(system.New())
+	registerNative(balance.New())
+	registerNative(system.New())
 	registerNative(transfers.New())
 	registerNative(bond.New())
 	registerNative(reward.New())
@@ -44,16 +57,77 @@ func registerNative(p interface{}) {
 	register(reflect.ValueOf(p).Type().Elem().Name(), p)
 }
 
+// Configure applies plugins.toml, it registers the remote plugins, disabled plugins leave RegisteredPlugins
+// and the enabled ones receive their config map
+func Configure(c *configs.PluginsConf) error {
+	for _, r := range c.Remote {
+		register(r.Name, remote.New(r))
+	}
+	for name, plugin := range RegisteredPlugins {
+		pluginConfigs[name] = c.Config[name]
+		if len(c.Enabled) > 0 && !util.StringInSlice(name, c.Enabled) {
+			log.Info("disable plugins: ", name)
+			delete(RegisteredPlugins, name)
+			disabledPlugins[name] = plugin
+			continue
+		}
+		if p, ok := plugin.(model.PluginConfigure); ok {
+			if err := p.Configure(c.Config[name]); err != nil {
+				return fmt.Errorf("plugin %s config: %v", name, err)
+			}
+		}
+	}
+	for _, name := range c.Enabled {
+		if _, ok := RegisteredPlugins[name]; !ok {
+			log.Warn("enabled plugin ", name, " is not registered")
+		}
+	}
+	return nil
+}
+
 type PluginInfo struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
-	Ui      bool   `json:"ui"`
+	Name    string                 `json:"name"`
+	Version string                 `json:"version"`
+	Ui      bool                   `json:"ui"`
+	Enabled bool                   `json:"enabled"`
+	Config  map[string]interface{} `json:"config"`
 }
 
 func List() []PluginInfo {
-	plugins := make([]PluginInfo, 0, len(RegisteredPlugins))
+	plugins := make([]PluginInfo, 0, len(RegisteredPlugins)+len(disabledPlugins))
 	for name, plugin := range RegisteredPlugins {
-		plugins = append(plugins, PluginInfo{Name: name, Version: plugin.Version()})
+		plugins = append(plugins, PluginInfo{Name: name, Version: plugin.Version(), Enabled: true, Config: pluginConfigs[name]})
 	}
+	for name, plugin := range disabledPlugins {
+		plugins = append(plugins, PluginInfo{Name: name, Version: plugin.Version(), Config: pluginConfigs[name]})
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
 	return plugins
 }
+
+// Disable moves a plugin that cannot run out of RegisteredPlugins, E.g when its schema is newer than its code
+func Disable(name string) {
+	plugin, ok := RegisteredPlugins[name]
+	if !ok {
+		return
+	}
+	log.Warn("disable plugins: ", name)
+	delete(RegisteredPlugins, name)
+	disabledPlugins[name] = plugin
+	if c, ok := plugin.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Error("close plugins ", name, ": ", err)
+		}
+	}
+}
+
+// Close stops the plugins holding processes or connections, E.g the remote ones
+func Close() {
+	for name, plugin := range RegisteredPlugins {
+		if c, ok := plugin.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				log.Error("close plugins ", name, ": ", err)
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package plugins
 import (
 	"testing"
 
+	"github.com/CoolBitX-Technology/subscan/configs"
 	"github.com/CoolBitX-Technology/subscan/model"
 	"github.com/itering/subscan-plugin/router"
 	"github.com/shopspring/decimal"
@@ -39,6 +40,44 @@ func TestRegister(t *testing.T) {
 	assert.Nil(t, RegisteredPlugins["test2"])
 }
 
+type TConfigPlugin struct {
+	TPlugin
+	config map[string]interface{}
+}
+
+func (a *TConfigPlugin) Configure(c map[string]interface{}) error {
+	a.config = c
+	return nil
+}
+
 func TestList(t *testing.T) {
-	assert.Equal(t, len(List()), len(RegisteredPlugins))
+	assert.Equal(t, len(List()), len(RegisteredPlugins)+len(disabledPlugins))
+}
+
+func TestConfigure(t *testing.T) {
+	p := &TConfigPlugin{}
+	register("configured", p)
+	register("disabled", &TPlugin{})
+	enabled := make([]string, 0, len(RegisteredPlugins))
+	for name := range RegisteredPlugins {
+		if name != "disabled" {
+			enabled = append(enabled, name)
+		}
+	}
+	config := map[string]interface{}{"calls": []interface{}{"balances-transfer"}}
+	assert.NoError(t, Configure(&configs.PluginsConf{
+		Enabled: enabled,
+		Config:  map[string]map[string]interface{}{"configured": config},
+	}))
+	assert.Equal(t, config, p.config)
+	assert.Nil(t, RegisteredPlugins["disabled"])
+	for _, info := range List() {
+		switch info.Name {
+		case "disabled":
+			assert.False(t, info.Enabled)
+		case "configured":
+			assert.True(t, info.Enabled)
+			assert.Equal(t, config, info.Config)
+		}
+	}
 }
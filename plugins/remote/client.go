@@ -0,0 +1,280 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/CoolBitX-Technology/subscan/configs"
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/itering/subscan-plugin/router"
+	"github.com/prometheus/common/log"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc"
+)
+
+const (
+	dialTimeout = 30 * time.Second
+	callTimeout = time.Minute
+)
+
+// Plugin is the host side of an out-of-process plugin, it serves model.Plugin over grpc
+type Plugin struct {
+	conf   configs.RemotePluginConf
+	d      model.Dao
+	conn   *grpc.ClientConn
+	cmd    *exec.Cmd
+	socket string
+	info   InfoReply
+	dialer func(ctx context.Context, addr string) (net.Conn, error)
+}
+
+func New(c configs.RemotePluginConf) *Plugin {
+	return &Plugin{conf: c, dialer: dial}
+}
+
+func dial(ctx context.Context, addr string) (net.Conn, error) {
+	network := "tcp"
+	if strings.HasPrefix(addr, "unix://") {
+		network, addr = "unix", strings.TrimPrefix(addr, "unix://")
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, addr)
+}
+
+// InitDao connects the plugin, launching it first when it has a command, and migrates its tables
+func (p *Plugin) InitDao(d model.Dao) {
+	p.d = d
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			log.Error("remote plugin ", p.conf.Name, ": ", err)
+			return
+		}
+	}
+	p.Migrate()
+}
+
+func (p *Plugin) connect() error {
+	addr := p.conf.Addr
+	if p.conf.Command != "" {
+		p.socket = filepath.Join(os.TempDir(), fmt.Sprintf("subscan-plugin-%s-%d.sock", p.conf.Name, os.Getpid()))
+		_ = os.Remove(p.socket)
+		addr = "unix://" + p.socket
+		p.cmd = exec.Command(p.conf.Command, p.conf.Args...)
+		p.cmd.Env = append(os.Environ(), AddrEnv+"="+addr)
+		p.cmd.Stdout, p.cmd.Stderr = os.Stdout, os.Stderr
+		if err := p.cmd.Start(); err != nil {
+			p.cmd = nil
+			return err
+		}
+	}
+	if addr == "" {
+		return errors.New("neither addr nor command is set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr,
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithContextDialer(p.dialer),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(codecName)),
+	)
+	if err != nil {
+		return err
+	}
+	p.conn = conn
+	if err = p.conn.Invoke(ctx, fullMethod("Info"), &InfoRequest{}, &p.info); err != nil {
+		return err
+	}
+	if !strings.EqualFold(p.info.Name, p.conf.Name) {
+		log.Warn("remote plugin ", p.conf.Name, " calls itself ", p.info.Name)
+	}
+	return nil
+}
+
+// Close closes the connection and stops a launched plugin
+func (p *Plugin) Close() error {
+	var err error
+	if p.conn != nil {
+		err = p.conn.Close()
+		p.conn = nil
+	}
+	if p.cmd != nil {
+		_ = p.cmd.Process.Signal(os.Interrupt)
+		exited := make(chan struct{})
+		go func() {
+			_ = p.cmd.Wait()
+			close(exited)
+		}()
+		select {
+		case <-exited:
+		case <-time.After(5 * time.Second):
+			_ = p.cmd.Process.Kill()
+		}
+		p.cmd = nil
+		_ = os.Remove(p.socket)
+	}
+	return err
+}
+
+func (p *Plugin) InitHttp() []router.Http {
+	var routes []router.Http
+	for _, route := range p.info.Routes {
+		route := route
+		routes = append(routes, router.Http{Router: route, Handle: func(w http.ResponseWriter, r *http.Request) error {
+			return p.serveHTTP(route, w, r)
+		}})
+	}
+	return routes
+}
+
+func (p *Plugin) serveHTTP(route string, w http.ResponseWriter, r *http.Request) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	result, err := p.call(nil, "ServeHTTP", &HostMessage{Http: &HttpRequest{Route: route, Header: r.Header, Body: body}})
+	if err == nil && (result.Http == nil || len(result.Writes) > 0) {
+		err = errors.New("http call answered without a response or with writes")
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return err
+	}
+	for k, v := range result.Http.Header {
+		w.Header()[k] = v
+	}
+	if result.Http.Status != 0 {
+		w.WriteHeader(result.Http.Status)
+	}
+	_, err = w.Write(result.Http.Body)
+	return err
+}
+
+func (p *Plugin) ProcessExtrinsic(block *model.Block, extrinsic *model.Extrinsic, events []model.Event) error {
+	return p.process(block.BlockNum, "ProcessExtrinsic", &HostMessage{Extrinsic: &ExtrinsicCall{Block: block, Extrinsic: extrinsic, Events: events}})
+}
+
+func (p *Plugin) ProcessEvent(block *model.Block, event *model.Event, fee decimal.Decimal) error {
+	return p.process(block.BlockNum, "ProcessEvent", &HostMessage{Event: &EventCall{Block: block, Event: event, Fee: fee}})
+}
+
+// process runs a call of block blockNum in its transaction, the queries see the rows written before in the block
+func (p *Plugin) process(blockNum int, method string, m *HostMessage) error {
+	txn := p.d.BlockTxn(blockNum)
+	result, err := p.call(txn, method, m)
+	if err != nil {
+		p.d.DbRollback(txn)
+		return err
+	}
+	return p.apply(txn, result.Writes)
+}
+
+func (p *Plugin) Rollback(blockNum int) error {
+	result, err := p.call(nil, "Rollback", &HostMessage{Rollback: &RollbackCall{BlockNum: blockNum}})
+	if err != nil {
+		return err
+	}
+	return p.apply(p.d.DbBegin(), result.Writes)
+}
+
+func (p *Plugin) Migrate() {
+	if p.conn == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	var reply MigrateReply
+	if err := p.conn.Invoke(ctx, fullMethod("Migrate"), &MigrateRequest{}, &reply); err != nil {
+		log.Error("remote plugin ", p.conf.Name, " migrate: ", err)
+		return
+	}
+	for i := range reply.Tables {
+		if err := p.d.MigrateTable(&reply.Tables[i]); err != nil {
+			log.Error("remote plugin ", p.conf.Name, " migrate: ", err)
+		}
+	}
+}
+
+func (p *Plugin) SubscribeExtrinsic() []string {
+	return p.info.SubscribeExtrinsic
+}
+
+func (p *Plugin) SubscribeEvent() []string {
+	return p.info.SubscribeEvent
+}
+
+func (p *Plugin) Version() string {
+	return p.info.Version
+}
+
+// call runs one call stream, answering the queries of the plugin in txn, nil outside a block, until its result
+func (p *Plugin) call(txn *model.GormDB, method string, m *HostMessage) (*Result, error) {
+	if p.conn == nil {
+		return nil, fmt.Errorf("remote plugin %s is not connected", p.conf.Name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	stream, err := p.conn.NewStream(ctx, &streamDesc, fullMethod(method))
+	if err != nil {
+		return nil, err
+	}
+	if err = stream.SendMsg(m); err != nil {
+		return nil, err
+	}
+	for {
+		var reply PluginMessage
+		if err = stream.RecvMsg(&reply); err != nil {
+			return nil, err
+		}
+		if result := reply.Result; result != nil {
+			_ = stream.CloseSend()
+			if result.Error != "" {
+				return nil, errors.New(result.Error)
+			}
+			return result, nil
+		}
+		if reply.Query == nil {
+			return nil, errors.New("empty plugin message")
+		}
+		rows := &Rows{}
+		q := reply.Query
+		if rows.Rows, err = p.d.FindRows(txn, q.Table, q.Where, &model.Option{Order: q.Order, Page: q.Page, PageSize: q.PageSize}); err != nil {
+			rows.Error = err.Error()
+		}
+		if err = stream.SendMsg(&HostMessage{Rows: rows}); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// apply writes a call result in txn, all or nothing
+func (p *Plugin) apply(txn *model.GormDB, writes []Write) (err error) {
+	defer func() {
+		if err != nil {
+			p.d.DbRollback(txn)
+			return
+		}
+		p.d.DbCommit(txn)
+	}()
+	for _, w := range writes {
+		if w.Row != nil {
+			err = p.d.InsertRow(txn, w.Table, w.Row)
+		} else {
+			err = p.d.DeleteRows(txn, w.Table, w.Where)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package remote
+
+import (
+	"encoding/json"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	// ServiceName is the grpc service an out-of-process plugin serves, described in plugin.proto
+	ServiceName = "subscan.plugin.Plugin"
+	// AddrEnv is the address a launched plugin serves on, host:port or unix:///path
+	AddrEnv = "SUBSCAN_PLUGIN_ADDR"
+	// codecName is the grpc content subtype of the messages, they are JSON encoded
+	codecName = "json"
+)
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+func (jsonCodec) Name() string { return codecName }
+
+type InfoRequest struct{}
+
+type InfoReply struct {
+	Name               string   `json:"name"`
+	Version            string   `json:"version"`
+	SubscribeExtrinsic []string `json:"subscribe_extrinsic"`
+	SubscribeEvent     []string `json:"subscribe_event"`
+	// served under /api/plugin/<name>/<route> by POST
+	Routes []string `json:"routes"`
+}
+
+type MigrateRequest struct{}
+
+type MigrateReply struct {
+	Tables []model.Table `json:"tables"`
+}
+
+type ExtrinsicCall struct {
+	Block     *model.Block     `json:"block"`
+	Extrinsic *model.Extrinsic `json:"extrinsic"`
+	Events    []model.Event    `json:"events"`
+}
+
+type EventCall struct {
+	Block *model.Block    `json:"block"`
+	Event *model.Event    `json:"event"`
+	Fee   decimal.Decimal `json:"fee"`
+}
+
+type RollbackCall struct {
+	BlockNum int `json:"block_num"`
+}
+
+type HttpRequest struct {
+	Route  string              `json:"route"`
+	Header map[string][]string `json:"header"`
+	Body   []byte              `json:"body"`
+}
+
+type HttpResponse struct {
+	Status int                 `json:"status"`
+	Header map[string][]string `json:"header"`
+	Body   []byte              `json:"body"`
+}
+
+// Query reads a table of the plugin, Page is zero based
+type Query struct {
+	Table    string       `json:"table"`
+	Where    []model.Cond `json:"where"`
+	Order    string       `json:"order"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+}
+
+type Rows struct {
+	Rows  []map[string]interface{} `json:"rows"`
+	Error string                   `json:"error,omitempty"`
+}
+
+// Write inserts Row into Table, or deletes the rows matching Where without a Row
+type Write struct {
+	Table string                 `json:"table"`
+	Row   map[string]interface{} `json:"row,omitempty"`
+	Where []model.Cond           `json:"where,omitempty"`
+}
+
+// Result ends a call, the writes are applied in the block transaction when Error is empty
+type Result struct {
+	Writes []Write       `json:"writes,omitempty"`
+	Http   *HttpResponse `json:"http,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// HostMessage is sent by subscan on a call stream, the first one carries the call and the next
+// ones answer the queries of the plugin
+type HostMessage struct {
+	Extrinsic *ExtrinsicCall `json:"extrinsic,omitempty"`
+	Event     *EventCall     `json:"event,omitempty"`
+	Rollback  *RollbackCall  `json:"rollback,omitempty"`
+	Http      *HttpRequest   `json:"http,omitempty"`
+	Rows      *Rows          `json:"rows,omitempty"`
+}
+
+// PluginMessage is sent by the plugin on a call stream, queries until the result
+type PluginMessage struct {
+	Query  *Query  `json:"query,omitempty"`
+	Result *Result `json:"result,omitempty"`
+}
+
+func fullMethod(method string) string {
+	return "/" + ServiceName + "/" + method
+}
@@ -0,0 +1,132 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CoolBitX-Technology/subscan/configs"
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testHandler struct{}
+
+func (testHandler) Info() *InfoReply {
+	return &InfoReply{Name: "stake", Version: "0.1", SubscribeExtrinsic: []string{"staking"}, Routes: []string{"stakes"}}
+}
+
+func (testHandler) Migrate() []model.Table {
+	return []model.Table{{Name: "stake", Columns: []model.TableColumn{{Name: "block_num", Type: "int"}, {Name: "total", Type: "int"}}}}
+}
+
+func (testHandler) ProcessExtrinsic(c *Call, block *model.Block, extrinsic *model.Extrinsic, events []model.Event) error {
+	rows, err := c.Find(&Query{Table: "stake", Order: "block_num desc", PageSize: 1})
+	if err != nil {
+		return err
+	}
+	total := len(events)
+	if len(rows) > 0 {
+		total += int(rows[0]["total"].(float64))
+	}
+	c.Insert("stake", map[string]interface{}{"block_num": block.BlockNum, "total": total})
+	return nil
+}
+
+func (testHandler) ProcessEvent(c *Call, block *model.Block, event *model.Event, fee decimal.Decimal) error {
+	c.Insert("stake", map[string]interface{}{"block_num": block.BlockNum})
+	return errors.New("bad event")
+}
+
+func (testHandler) Rollback(c *Call, blockNum int) error {
+	c.Delete("stake", model.Cond{Column: "block_num", Op: ">", Value: blockNum})
+	return nil
+}
+
+func (testHandler) ServeHTTP(c *Call, r *HttpRequest) (*HttpResponse, error) {
+	return &HttpResponse{Status: http.StatusTeapot, Body: append([]byte(r.Route+":"), r.Body...)}, nil
+}
+
+type testDao struct {
+	model.Dao
+	tables  []string
+	inserts []map[string]interface{}
+	deletes [][]model.Cond
+	queries []*model.GormDB
+	txn     model.GormDB
+	commits int
+}
+
+func (d *testDao) MigrateTable(table *model.Table) error {
+	d.tables = append(d.tables, table.Name)
+	return nil
+}
+
+func (d *testDao) FindRows(c *model.GormDB, table string, where []model.Cond, option *model.Option) ([]map[string]interface{}, error) {
+	d.queries = append(d.queries, c)
+	return []map[string]interface{}{{"block_num": 1, "total": 5}}, nil
+}
+
+func (d *testDao) InsertRow(c *model.GormDB, table string, row map[string]interface{}) error {
+	d.inserts = append(d.inserts, row)
+	return nil
+}
+
+func (d *testDao) DeleteRows(c *model.GormDB, table string, where []model.Cond) error {
+	d.deletes = append(d.deletes, where)
+	return nil
+}
+
+func (d *testDao) BlockTxn(int) *model.GormDB { return &d.txn }
+
+func (d *testDao) DbBegin() *model.GormDB { return &model.GormDB{} }
+
+func (d *testDao) DbCommit(*model.GormDB) { d.commits++ }
+
+func (d *testDao) DbRollback(*model.GormDB) {}
+
+func TestRemotePlugin(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	RegisterServer(s, testHandler{})
+	go func() { _ = s.Serve(lis) }()
+	defer s.Stop()
+
+	p := New(configs.RemotePluginConf{Name: "stake", Addr: "bufnet"})
+	p.dialer = func(context.Context, string) (net.Conn, error) { return lis.Dial() }
+	d := &testDao{}
+	p.InitDao(d)
+	defer p.Close()
+
+	assert.Equal(t, []string{"stake"}, d.tables)
+	assert.Equal(t, "0.1", p.Version())
+	assert.Equal(t, []string{"staking"}, p.SubscribeExtrinsic())
+
+	block := &model.Block{BlockNum: 2}
+	assert.NoError(t, p.ProcessExtrinsic(block, &model.Extrinsic{}, []model.Event{{}, {}}))
+	assert.Equal(t, []map[string]interface{}{{"block_num": float64(2), "total": float64(7)}}, d.inserts)
+	assert.Equal(t, 1, d.commits)
+	// the query sees the rows written before in the block
+	assert.Len(t, d.queries, 1)
+	assert.Same(t, &d.txn, d.queries[0])
+
+	assert.EqualError(t, p.ProcessEvent(block, &model.Event{}, decimal.Zero), "bad event")
+	assert.Len(t, d.inserts, 1)
+
+	assert.NoError(t, p.Rollback(1))
+	assert.Equal(t, [][]model.Cond{{{Column: "block_num", Op: ">", Value: float64(1)}}}, d.deletes)
+
+	routes := p.InitHttp()
+	assert.Len(t, routes, 1)
+	w := httptest.NewRecorder()
+	assert.NoError(t, routes[0].Handle(w, httptest.NewRequest(http.MethodPost, "/api/plugin/stake/stakes", strings.NewReader("{}"))))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, "stakes:{}", w.Body.String())
+}
@@ -0,0 +1,145 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc"
+)
+
+// Handler is implemented by an out-of-process plugin, it mirrors model.Plugin
+type Handler interface {
+	Info() *InfoReply
+	Migrate() []model.Table
+	ProcessExtrinsic(c *Call, block *model.Block, extrinsic *model.Extrinsic, events []model.Event) error
+	ProcessEvent(c *Call, block *model.Block, event *model.Event, fee decimal.Decimal) error
+	// Drop the rows of the blocks after blockNum
+	Rollback(c *Call, blockNum int) error
+	ServeHTTP(c *Call, r *HttpRequest) (*HttpResponse, error)
+}
+
+// Call is one call of subscan, it reads the plugin tables and collects the writes subscan applies
+// once the call succeeds. Reads do not see the writes of the call
+type Call struct {
+	stream grpc.ServerStream
+	writes []Write
+}
+
+// Find asks subscan for rows of a plugin table
+func (c *Call) Find(q *Query) ([]map[string]interface{}, error) {
+	if err := c.stream.SendMsg(&PluginMessage{Query: q}); err != nil {
+		return nil, err
+	}
+	var m HostMessage
+	if err := c.stream.RecvMsg(&m); err != nil {
+		return nil, err
+	}
+	if m.Rows == nil {
+		return nil, errors.New("query answered without rows")
+	}
+	if m.Rows.Error != "" {
+		return nil, errors.New(m.Rows.Error)
+	}
+	return m.Rows.Rows, nil
+}
+
+func (c *Call) Insert(table string, row map[string]interface{}) {
+	c.writes = append(c.writes, Write{Table: table, Row: row})
+}
+
+func (c *Call) Delete(table string, where ...model.Cond) {
+	c.writes = append(c.writes, Write{Table: table, Where: where})
+}
+
+var streamDesc = grpc.StreamDesc{Handler: serveCall, ServerStreams: true, ClientStreams: true}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*Handler)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Info", Handler: unary("Info", new(InfoRequest), func(h Handler) interface{} { return h.Info() })},
+		{MethodName: "Migrate", Handler: unary("Migrate", new(MigrateRequest), func(h Handler) interface{} {
+			return &MigrateReply{Tables: h.Migrate()}
+		})},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "ProcessExtrinsic", Handler: serveCall, ServerStreams: true, ClientStreams: true},
+		{StreamName: "ProcessEvent", Handler: serveCall, ServerStreams: true, ClientStreams: true},
+		{StreamName: "Rollback", Handler: serveCall, ServerStreams: true, ClientStreams: true},
+		{StreamName: "ServeHTTP", Handler: serveCall, ServerStreams: true, ClientStreams: true},
+	},
+	Metadata: "plugin.proto",
+}
+
+func unary(method string, req interface{}, fn func(h Handler) interface{}) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		handle := func(context.Context, interface{}) (interface{}, error) { return fn(srv.(Handler)), nil }
+		if interceptor == nil {
+			return handle(ctx, req)
+		}
+		return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod(method)}, handle)
+	}
+}
+
+// serveCall serves every call stream, the first message tells the call
+func serveCall(srv interface{}, stream grpc.ServerStream) error {
+	h := srv.(Handler)
+	var m HostMessage
+	if err := stream.RecvMsg(&m); err != nil {
+		return err
+	}
+	c := &Call{stream: stream}
+	var (
+		result Result
+		err    error
+	)
+	switch {
+	case m.Extrinsic != nil:
+		err = h.ProcessExtrinsic(c, m.Extrinsic.Block, m.Extrinsic.Extrinsic, m.Extrinsic.Events)
+	case m.Event != nil:
+		err = h.ProcessEvent(c, m.Event.Block, m.Event.Event, m.Event.Fee)
+	case m.Rollback != nil:
+		err = h.Rollback(c, m.Rollback.BlockNum)
+	case m.Http != nil:
+		result.Http, err = h.ServeHTTP(c, m.Http)
+	default:
+		err = errors.New("empty call")
+	}
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Writes = c.writes
+	}
+	return stream.SendMsg(&PluginMessage{Result: &result})
+}
+
+// RegisterServer adds h to a grpc server
+func RegisterServer(s *grpc.Server, h Handler) {
+	s.RegisterService(&serviceDesc, h)
+}
+
+// Serve serves h on the address of env SUBSCAN_PLUGIN_ADDR set when subscan launches the plugin, addr otherwise
+func Serve(h Handler, addr string) error {
+	if env := os.Getenv(AddrEnv); env != "" {
+		addr = env
+	}
+	network := "tcp"
+	if strings.HasPrefix(addr, "unix://") {
+		network, addr = "unix", strings.TrimPrefix(addr, "unix://")
+	}
+	lis, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	s := grpc.NewServer()
+	RegisterServer(s, h)
+	return s.Serve(lis)
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"net/http"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/reward/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/CoolBitX-Technology/subscan/util/ss58"
+)
+
+var (
+	svc model.RewardService
+)
+
+type rewardSlashParams struct {
+	Row     int    `json:"row" validate:"min=1,max=100"`
+	Page    int    `json:"page" validate:"min=0"`
+	Address string `json:"address"`
+}
+
+// Routes serves POST /api/plugin/reward/reward_slash
+func Routes(s model.RewardService) []m.PluginRoute {
+	svc = s
+	return []m.PluginRoute{
+		{
+			Method:  http.MethodPost,
+			Path:    "reward_slash",
+			Aliases: []string{"scan/account/reward_slash"},
+			Params:  func() interface{} { return new(rewardSlashParams) },
+			Handle:  rewardSlash,
+		},
+	}
+}
+
+// rewardSlash lists the rewards and slashes of an address, count is the nonce of the account
+func rewardSlash(r *m.PluginRequest) (interface{}, error) {
+	p := r.Params.(*rewardSlashParams)
+	if p.Address == "" || ss58.Decode(p.Address, util.StringToInt(util.AddressType)) == "" {
+		return nil, m.NewApiError(http.StatusBadRequest, m.AddressValidateError, "Invalid address")
+	}
+	list, err := svc.GetRewardListJson(p.Page, p.Row, p.Address)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := svc.GetAccountNonce(p.Address)
+	if err != nil {
+		return nil, err
+	}
+	for i, reward := range list {
+		list[i].AccountId = ss58.Encode(reward.AccountId, util.StringToInt(util.AddressType))
+	}
+	return map[string]interface{}{
+		"list": list, "count": nonce,
+	}, nil
+}
@@ -23,7 +23,7 @@ func NewsqlRewardRepository(db m.Dao) model.RewardRepository {
 }
 
 func (s *sqlRewardRepository) NewRewardEvent(b *m.Block, e *m.Event, params []m.EventParam) (err error) {
-	txn := s.DB.DbBegin()
+	txn := s.DB.BlockTxn(b.BlockNum)
 	defer s.DB.DbRollback(txn)
 	// opt := m.Option{PluginPrefix: PluginPrefix}
 	r := &model.Reward{
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	m "github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/reward/http"
 	"github.com/CoolBitX-Technology/subscan/plugins/reward/model"
 	"github.com/CoolBitX-Technology/subscan/plugins/reward/repository"
 	"github.com/CoolBitX-Technology/subscan/plugins/reward/service"
@@ -55,6 +56,10 @@ func (r *Reward) InitHttp() []router.Http {
 	return nil
 }
 
+func (r *Reward) Routes() []m.PluginRoute {
+	return http.Routes(srv)
+}
+
 func (r *Reward) RewardList(page int, row int, address string) (rewardList []model.Reward, nonce int, err error) {
 	rewardList, err = srv.GetRewardListJson(page, row, address)
 	nonce, err = srv.GetAccountNonce(address)
@@ -90,6 +95,11 @@ func (r *Reward) ProcessEvent(block *m.Block, e *m.Event, fee decimal.Decimal) e
 	return err
 }
 
+// Rollback drops the rewards of the blocks after blockNum
+func (r *Reward) Rollback(blockNum int) error {
+	return r.d.Delete(&model.Reward{}, fmt.Sprintf("block_num > %d", blockNum))
+}
+
 func (r *Reward) Version() string {
 	return "0.1"
 }
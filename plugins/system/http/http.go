@@ -1,10 +0,0 @@
-package http
-
-import (
-	"github.com/CoolBitX-Technology/subscan/plugins/system/service"
-	"github.com/itering/subscan-plugin/router"
-)
-
-func Router(s *service.Service) []router.Http {
-	return nil
-}
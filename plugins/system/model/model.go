@@ -1,11 +1,18 @@
 package model
 
+import (
+	"github.com/CoolBitX-Technology/subscan/model"
+)
+
+// ExtrinsicError is the decoded DispatchError of System.ExtrinsicFailed
 type ExtrinsicError struct {
-	ID            uint   `gorm:"primary_key" json:"-"`
-	ExtrinsicHash string `json:"-" sql:"size:100;"`
-	Module        string `json:"module"`
-	Name          string `json:"name"`
-	Doc           string `json:"doc"`
+	ID             uint   `gorm:"primary_key" json:"-"`
+	ExtrinsicIndex string `json:"extrinsic_index" sql:"default: null;size:100"`
+	ExtrinsicHash  string `json:"-" sql:"size:100;"`
+	BlockNum       int    `json:"block_num"`
+	Module         string `json:"module" sql:"size:100;"`
+	Name           string `json:"name" sql:"size:100;"`
+	Doc            string `json:"doc" sql:"type:text;"`
 }
 
 type MetadataModuleError struct {
@@ -13,3 +20,17 @@ type MetadataModuleError struct {
 	Name   string   `json:"name"`
 	Doc    []string `json:"doc"`
 }
+
+type SystemService interface {
+	ExtrinsicFailed(b *model.Block, e *model.Event) error
+	GetExtrinsicError(extrinsicIndex string) (*ExtrinsicError, error)
+	ExtrinsicErrorQuery(module, name string) string
+	Rollback(blockNum int) error
+}
+
+type SystemRepository interface {
+	CreateExtrinsicError(b *model.Block, e *ExtrinsicError) error
+	GetExtrinsicError(extrinsicIndex string) (*ExtrinsicError, error)
+	ExtrinsicErrorQuery(module, name string) string
+	Rollback(blockNum int) error
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/system/model"
+	"github.com/prometheus/common/log"
+)
+
+const PluginPrefix = "system"
+
+type sqlSystemRepository struct {
+	DB m.Dao
+}
+
+func NewsqlSystemRepository(db m.Dao) model.SystemRepository {
+	return &sqlSystemRepository{
+		DB: db,
+	}
+}
+
+func (s *sqlSystemRepository) CreateExtrinsicError(b *m.Block, e *model.ExtrinsicError) error {
+	txn := s.DB.BlockTxn(b.BlockNum)
+	defer s.DB.DbRollback(txn)
+	tableName := fmt.Sprintf("%s_%s", PluginPrefix, txn.DB.Unscoped().NewScope(e).TableName())
+	if err := txn.DB.Table(tableName).Create(e).Error; err != nil {
+		return err
+	}
+	s.DB.DbCommit(txn)
+	log.Info("New an extrinsic error with extrinsicIndex: ", e.ExtrinsicIndex, " ", e.Module, ".", e.Name)
+	return nil
+}
+
+func (s *sqlSystemRepository) GetExtrinsicError(extrinsicIndex string) (*model.ExtrinsicError, error) {
+	var list []model.ExtrinsicError
+	opt := m.Option{PluginPrefix: PluginPrefix, PageSize: 1}
+	if err := s.DB.FindBy(&list, map[string]interface{}{"extrinsic_index": extrinsicIndex}, &opt); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return &list[0], nil
+}
+
+var quote = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// ExtrinsicErrorQuery selects the extrinsic indexes of the error in a sub query, E.g
+// extrinsic_index IN (SELECT extrinsic_index FROM system_extrinsic_errors WHERE module = 'Balances')
+func (s *sqlSystemRepository) ExtrinsicErrorQuery(module, name string) string {
+	var where []string
+	if module != "" {
+		where = append(where, fmt.Sprintf("module = '%s'", quote.Replace(module)))
+	}
+	if name != "" {
+		where = append(where, fmt.Sprintf("name = '%s'", quote.Replace(name)))
+	}
+	query := fmt.Sprintf("SELECT extrinsic_index FROM %s_extrinsic_errors", PluginPrefix)
+	if len(where) > 0 {
+		query = fmt.Sprintf("%s WHERE %s", query, strings.Join(where, " AND "))
+	}
+	return fmt.Sprintf("extrinsic_index IN (%s)", query)
+}
+
+// Rollback drops the errors of the blocks after blockNum
+func (s *sqlSystemRepository) Rollback(blockNum int) error {
+	return s.DB.Delete(&model.ExtrinsicError{}, fmt.Sprintf("block_num > %d", blockNum))
+}
@@ -1,71 +1,124 @@
 package service
 
 import (
-	"github.com/CoolBitX-Technology/subscan/plugins/system/dao"
+	"fmt"
+	"strings"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/plugins/system/model"
 	"github.com/CoolBitX-Technology/subscan/util"
-	"github.com/itering/subscan-plugin/storage"
+	"github.com/itering/substrate-api-rpc/metadata"
+	"github.com/prometheus/common/log"
 )
 
 type Service struct {
-	dao storage.Dao
+	sql      model.SystemRepository
+	metadata *m.SpecMetadata
 }
 
-func New(d storage.Dao) *Service {
+// New takes the raw metadata of a spec, E.g Dao.SpecialMetadata, it is processed once per spec
+func New(r model.SystemRepository, specialMetadata func(spec int) string) model.SystemService {
 	return &Service{
-		dao: d,
+		sql:      r,
+		metadata: m.NewSpecMetadata(specialMetadata),
 	}
 }
 
-func (s *Service) GetExtrinsicError(hash string) *model.ExtrinsicError {
-	return dao.ExtrinsicError(s.dao, hash)
+// ExtrinsicFailed stores the DispatchError of System.ExtrinsicFailed(DispatchError, DispatchInfo), a module
+// error is resolved with the metadata of the spec of the block
+func (s *Service) ExtrinsicFailed(b *m.Block, e *m.Event) error {
+	var params []m.EventParam
+	util.UnmarshalAny(&params, e.Params)
+	if len(params) == 0 {
+		return nil
+	}
+	moduleError, err := s.dispatchError(b.SpecVersion, params[0].Value)
+	if err != nil || moduleError == nil {
+		return err
+	}
+	return s.sql.CreateExtrinsicError(b, &model.ExtrinsicError{
+		ExtrinsicIndex: fmt.Sprintf("%d-%d", e.BlockNum, e.ExtrinsicIdx),
+		ExtrinsicHash:  util.AddHex(e.ExtrinsicHash),
+		BlockNum:       b.BlockNum,
+		Module:         moduleError.Module,
+		Name:           moduleError.Name,
+		Doc:            strings.TrimSpace(strings.Join(moduleError.Doc, " ")),
+	})
 }
 
-func (s *Service) ExtrinsicFailed(spec int, event *storage.Event, paramEvent []storage.EventParam) {
-
-	type DispatchErrorModule struct {
-		Index int `json:"index"`
-		Error int `json:"error"`
+// dispatchError reads {"Module": {"index": 5, "error": 2}}, the error is "0x02000000" since metadata v14, the
+// {"Module": 5, "Error": 2} of old runtimes and the other variants, E.g {"BadOrigin": null} or {"Token": "NoFunds"}
+func (s *Service) dispatchError(spec int, v interface{}) (*model.MetadataModuleError, error) {
+	switch dr := v.(type) {
+	case string:
+		return &model.MetadataModuleError{Name: dr}, nil
+	case map[string]interface{}:
+		if errorIndex, ok := dr["Error"]; ok {
+			return s.moduleError(spec, util.IntFromInterface(dr["Module"]), errorIndex)
+		}
+		if module, ok := dr["Module"].(map[string]interface{}); ok {
+			return s.moduleError(spec, util.IntFromInterface(module["index"]), module["error"])
+		}
+		for name, value := range dr {
+			e := model.MetadataModuleError{Name: name}
+			if detail := util.ToString(value); value != nil && detail != "" {
+				e.Doc = []string{detail}
+			}
+			return &e, nil
+		}
 	}
+	return nil, nil
+}
 
-	for _, param := range paramEvent {
-
-		if param.Type == "DispatchError" {
-
-			var dr map[string]interface{}
-			util.UnmarshalAny(&dr, param.Value)
-
-			if _, ok := dr["Error"]; ok {
-				// log.Info("== case 1 ==")
-				_ = dao.CreateExtrinsicError(s.dao,
-					event.ExtrinsicHash,
-					dao.CheckExtrinsicError(spec, s.dao.SpecialMetadata(spec), util.IntFromInterface(dr["Module"]), util.IntFromInterface(dr["Error"])))
-
-			} else if _, ok := dr["Module"]; ok {
-				// log.Info("== case 2 ==")
-				var module DispatchErrorModule
-				util.UnmarshalAny(&module, dr["Module"])
+func (s *Service) moduleError(spec, moduleIndex int, errorIndex interface{}) (*model.MetadataModuleError, error) {
+	instant := s.metadata.Instant(spec)
+	if instant == nil {
+		return nil, fmt.Errorf("no metadata of spec %d", spec)
+	}
+	index := util.IntFromInterface(errorIndex)
+	if v, ok := errorIndex.(string); ok && strings.HasPrefix(v, "0x") {
+		index = -1
+		if b := util.HexToBytes(v); len(b) > 0 {
+			index = int(b[0])
+		}
+	}
+	moduleError := CheckExtrinsicError(instant, moduleIndex, index)
+	if moduleError == nil {
+		log.Warn("unknown error ", index, " of module ", moduleIndex, " in spec ", spec)
+	}
+	return moduleError, nil
+}
 
-				_ = dao.CreateExtrinsicError(s.dao,
-					event.ExtrinsicHash,
-					dao.CheckExtrinsicError(spec, s.dao.SpecialMetadata(spec), module.Index, module.Error))
+// CheckExtrinsicError finds the error in the metadata, a module is found by its index since metadata v12 and by
+// its position before
+func CheckExtrinsicError(instant *metadata.Instant, moduleIndex, errorIndex int) *model.MetadataModuleError {
+	if instant == nil {
+		return nil
+	}
+	for i, module := range instant.Metadata.Modules {
+		if instant.MetadataVersion >= 12 && module.Index != moduleIndex || instant.MetadataVersion < 12 && i != moduleIndex {
+			continue
+		}
+		if errorIndex < 0 || errorIndex >= len(module.Errors) {
+			return nil
+		}
+		return &model.MetadataModuleError{
+			Module: module.Name,
+			Name:   module.Errors[errorIndex].Name,
+			Doc:    module.Errors[errorIndex].Doc,
+		}
+	}
+	return nil
+}
 
-			} else if _, ok := dr["BadOrigin"]; ok {
-				// log.Info("== case 3 ==")
-				_ = dao.CreateExtrinsicError(s.dao, event.ExtrinsicHash,
-					&model.MetadataModuleError{Name: "BadOrigin"})
+func (s *Service) GetExtrinsicError(extrinsicIndex string) (*model.ExtrinsicError, error) {
+	return s.sql.GetExtrinsicError(extrinsicIndex)
+}
 
-			} else if _, ok := dr["CannotLookup"]; ok {
-				// log.Info("== case 4 ==")
-				_ = dao.CreateExtrinsicError(s.dao, event.ExtrinsicHash,
-					&model.MetadataModuleError{Name: "CannotLookup"})
+func (s *Service) ExtrinsicErrorQuery(module, name string) string {
+	return s.sql.ExtrinsicErrorQuery(module, name)
+}
 
-			} else if _, ok := dr["Other"]; ok {
-				// log.Info("== case 5 ==")
-				_ = dao.CreateExtrinsicError(s.dao, event.ExtrinsicHash,
-					&model.MetadataModuleError{Name: "Other"})
-			}
-			break
-		}
-	}
+func (s *Service) Rollback(blockNum int) error {
+	return s.sql.Rollback(blockNum)
 }
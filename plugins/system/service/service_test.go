@@ -0,0 +1,89 @@
+package service_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/system/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/system/model/mocks"
+	"github.com/CoolBitX-Technology/subscan/plugins/system/service"
+	"github.com/itering/scale.go/types"
+	"github.com/itering/substrate-api-rpc/metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+func instant(version int) *metadata.Instant {
+	balances := types.MetadataModules{Name: "Balances", Index: 5, Errors: []types.MetadataModuleError{
+		{Name: "VestingBalance", Doc: []string{" Vesting balance too high to send value"}},
+		{Name: "InsufficientBalance", Doc: []string{" Balance too low to send value"}},
+	}}
+	return &metadata.Instant{MetadataVersion: version, Metadata: types.MetadataTag{Modules: []types.MetadataModules{
+		{Name: "System", Index: 0}, balances,
+	}}}
+}
+
+func TestCheckExtrinsicError(t *testing.T) {
+	assert.Equal(t, &model.MetadataModuleError{Module: "Balances", Name: "InsufficientBalance", Doc: []string{" Balance too low to send value"}},
+		service.CheckExtrinsicError(instant(14), 5, 1))
+	assert.Nil(t, service.CheckExtrinsicError(instant(14), 1, 1))
+	assert.Nil(t, service.CheckExtrinsicError(instant(14), 5, 2))
+	// by position before v12
+	assert.Equal(t, "VestingBalance", service.CheckExtrinsicError(instant(11), 1, 0).Name)
+	assert.Nil(t, service.CheckExtrinsicError(nil, 5, 1))
+}
+
+func TestExtrinsicFailed(t *testing.T) {
+	const spec = 90001
+	metadata.RuntimeMetadata[spec] = instant(14)
+	defer delete(metadata.RuntimeMetadata, spec)
+	specialMetadata := func(s int) string {
+		if s == spec {
+			return "0x00"
+		}
+		return ""
+	}
+	block := m.Block{BlockNum: 100, SpecVersion: spec}
+	event := func(dispatchError interface{}) *m.Event {
+		raw, _ := json.Marshal([]m.EventParam{{Type: "DispatchError", Value: dispatchError}, {Type: "DispatchInfo", Value: map[string]interface{}{"weight": 1}}})
+		return &m.Event{BlockNum: 100, ExtrinsicIdx: 2, ModuleId: "system", EventId: "ExtrinsicFailed", ExtrinsicHash: "ab01", Params: raw}
+	}
+	extrinsicError := func(module, name, doc string) *model.ExtrinsicError {
+		return &model.ExtrinsicError{ExtrinsicIndex: "100-2", ExtrinsicHash: "0xab01", BlockNum: 100, Module: module, Name: name, Doc: doc}
+	}
+
+	for _, c := range []struct {
+		name          string
+		dispatchError interface{}
+		stored        *model.ExtrinsicError
+	}{
+		{name: "module", dispatchError: map[string]interface{}{"Module": map[string]interface{}{"index": 5, "error": 1}},
+			stored: extrinsicError("Balances", "InsufficientBalance", "Balance too low to send value")},
+		{name: "module v14", dispatchError: map[string]interface{}{"Module": map[string]interface{}{"index": 5, "error": "0x00000000"}},
+			stored: extrinsicError("Balances", "VestingBalance", "Vesting balance too high to send value")},
+		{name: "old module", dispatchError: map[string]interface{}{"Module": 5, "Error": 1},
+			stored: extrinsicError("Balances", "InsufficientBalance", "Balance too low to send value")},
+		{name: "unknown module", dispatchError: map[string]interface{}{"Module": map[string]interface{}{"index": 9, "error": 0}}},
+		{name: "unit variant", dispatchError: map[string]interface{}{"BadOrigin": nil}, stored: extrinsicError("", "BadOrigin", "")},
+		{name: "unit variant string", dispatchError: "CannotLookup", stored: extrinsicError("", "CannotLookup", "")},
+		{name: "token", dispatchError: map[string]interface{}{"Token": "NoFunds"}, stored: extrinsicError("", "Token", "NoFunds")},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			repo := new(mocks.SystemRepository)
+			repo.On("CreateExtrinsicError", &block, c.stored).Return(nil)
+			assert.NoError(t, service.New(repo, specialMetadata).ExtrinsicFailed(&block, event(c.dispatchError)))
+			if c.stored == nil {
+				repo.AssertNotCalled(t, "CreateExtrinsicError")
+				return
+			}
+			repo.AssertCalled(t, "CreateExtrinsicError", &block, c.stored)
+		})
+	}
+
+	t.Run("no metadata", func(t *testing.T) {
+		repo := new(mocks.SystemRepository)
+		other := m.Block{BlockNum: 100, SpecVersion: 1}
+		assert.Error(t, service.New(repo, specialMetadata).ExtrinsicFailed(&other, event(map[string]interface{}{"Module": map[string]interface{}{"index": 5, "error": 1}})))
+		repo.AssertNotCalled(t, "CreateExtrinsicError")
+	})
+}
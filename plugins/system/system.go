@@ -1,28 +1,30 @@
 package system
 
 import (
+	"strings"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/plugins/system/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/system/repository"
 	"github.com/CoolBitX-Technology/subscan/plugins/system/service"
-	"github.com/CoolBitX-Technology/subscan/util"
 	ui "github.com/itering/subscan-plugin"
 	"github.com/itering/subscan-plugin/router"
-	"github.com/itering/subscan-plugin/storage"
 	"github.com/prometheus/common/log"
 	"github.com/shopspring/decimal"
 )
 
-var srv *service.Service
+var srv model.SystemService
 
 type System struct {
-	d storage.Dao
+	d m.Dao
 }
 
 func New() *System {
 	return &System{}
 }
 
-func (a *System) InitDao(d storage.Dao) {
-	srv = service.New(d)
+func (a *System) InitDao(d m.Dao) {
+	srv = service.New(repository.NewsqlSystemRepository(d), d.SpecialMetadata)
 	a.d = d
 	a.Migrate()
 }
@@ -31,30 +33,69 @@ func (a *System) InitHttp() (routers []router.Http) {
 	return nil
 }
 
-func (a *System) ProcessExtrinsic(*storage.Block, *storage.Extrinsic, []storage.Event) error {
+func (a *System) ProcessExtrinsic(*m.Block, *m.Extrinsic, []m.Event) error {
 	return nil
 }
 
-func (a *System) ProcessEvent(block *storage.Block, event *storage.Event, _ decimal.Decimal) error {
-	var paramEvent []storage.EventParam
-	util.UnmarshalAny(&paramEvent, event.Params)
-	log.Info("system.ProcessEvent: ", block.BlockNum)
-	log.Info("EventId: ", event.EventId, "ModuleId", event.ModuleId)
+func (a *System) ProcessEvent(block *m.Block, event *m.Event, _ decimal.Decimal) error {
+	if event == nil || !strings.EqualFold(event.ModuleId, "system") {
+		return nil
+	}
 	switch event.EventId {
 	case "ExtrinsicFailed":
-		srv.ExtrinsicFailed(block.SpecVersion, event, paramEvent)
+		return srv.ExtrinsicFailed(block, event)
 	}
 	return nil
 }
 
+// ExtrinsicError is the decoded error of a failed extrinsic
+func (a *System) ExtrinsicError(extrinsicIndex string) *m.ExtrinsicError {
+	e, err := srv.GetExtrinsicError(extrinsicIndex)
+	if err != nil {
+		log.Error(err)
+	}
+	if e == nil {
+		return nil
+	}
+	return &m.ExtrinsicError{Module: e.Module, Name: e.Name, Doc: e.Doc}
+}
+
+func (a *System) ExtrinsicErrorQuery(module, name string) string {
+	return srv.ExtrinsicErrorQuery(module, name)
+}
+
+// Rollback drops the errors of the blocks after blockNum
+func (a *System) Rollback(blockNum int) error {
+	return srv.Rollback(blockNum)
+}
+
 func (a *System) Migrate() {
-	db := a.d
-	_ = db.AutoMigration(&model.ExtrinsicError{})
-	_ = db.AddUniqueIndex(&model.ExtrinsicError{}, "extrinsic_hash", "extrinsic_hash")
+	var e error
+	if e = a.d.AutoMigration(&model.ExtrinsicError{}); e != nil {
+		log.Error(e)
+	}
+	if e = a.d.AddUniqueIndex(&model.ExtrinsicError{}, "extrinsic_index", "extrinsic_index"); e != nil {
+		log.Error(e)
+	}
+	if e = a.d.AddIndex(&model.ExtrinsicError{}, "module_name", "module", "name"); e != nil {
+		log.Error(e)
+	}
+	if e = a.d.AddIndex(&model.ExtrinsicError{}, "block_num", "block_num"); e != nil {
+		log.Error(e)
+	}
+}
+
+func (a *System) Migrations() []m.PluginMigration {
+	return []m.PluginMigration{
+		// unsigned extrinsics have no hash, the errors are keyed by extrinsic_index
+		{Version: 1, Name: "drop unique extrinsic_hash", Up: func(d m.Dao) error {
+			return d.RemoveIndex(&model.ExtrinsicError{}, "extrinsic_hash")
+		}},
+	}
 }
 
 func (a *System) Version() string {
-	return "0.1"
+	return "0.2"
 }
 
 func (a *System) SubscribeExtrinsic() []string {
@@ -0,0 +1,128 @@
+package testkit
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// AssertNoErrors fails when a plugin call failed while feeding
+func AssertNoErrors(t testing.TB, h *Harness) bool {
+	t.Helper()
+	return assert.Empty(t, h.Errors, "plugin calls failed")
+}
+
+// AssertCount checks the number of rows of a table, the name is prefixed E.g transfer_transfers
+func AssertCount(t testing.TB, d *Dao, table string, count int) bool {
+	t.Helper()
+	rows, err := d.Rows(table)
+	if !assert.NoError(t, err) {
+		return false
+	}
+	return assert.Len(t, rows, count, "rows of %s", table)
+}
+
+// AssertRow checks that a row of the table has the column values, numbers compare by value whatever their go
+// type and strings case insensitively like mysql, E.g {"extrinsic_index": "5095844-1", "amount": 193309000000000}
+func AssertRow(t testing.TB, d *Dao, table string, columns map[string]interface{}) bool {
+	t.Helper()
+	rows, err := d.Rows(table)
+	if !assert.NoError(t, err) {
+		return false
+	}
+	for _, row := range rows {
+		if matchRow(row, columns) {
+			return true
+		}
+	}
+	return assert.Fail(t, fmt.Sprintf("no row of %s has %v", table, columns), "rows: %v", rows)
+}
+
+// AssertNoRow checks that no row of the table has the column values
+func AssertNoRow(t testing.TB, d *Dao, table string, columns map[string]interface{}) bool {
+	t.Helper()
+	rows, err := d.Rows(table)
+	if !assert.NoError(t, err) {
+		return false
+	}
+	for _, row := range rows {
+		if matchRow(row, columns) {
+			return assert.Fail(t, fmt.Sprintf("a row of %s has %v", table, columns), "row: %v", row)
+		}
+	}
+	return true
+}
+
+func matchRow(row, columns map[string]interface{}) bool {
+	for c, want := range columns {
+		got, ok := row[c]
+		if !ok {
+			return false
+		}
+		v, err := driver.DefaultParameterConverter.ConvertValue(want)
+		if valuer, ok := want.(driver.Valuer); ok {
+			v, err = valuer.Value()
+		}
+		if err != nil {
+			return false
+		}
+		if got == nil || v == nil {
+			if got != v {
+				return false
+			}
+			continue
+		}
+		if compareValues(got, v) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValues orders numbers numerically, a string against a number as a number like MySQL does,
+// and strings case insensitively like the default collation
+func compareValues(a, b driver.Value) int {
+	_, as := a.(string)
+	_, bs := b.(string)
+	if as && bs {
+		return strings.Compare(strings.ToLower(a.(string)), strings.ToLower(b.(string)))
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			switch {
+			case ta.Before(tb):
+				return -1
+			case ta.After(tb):
+				return 1
+			}
+			return 0
+		}
+	}
+	return toDecimal(a).Cmp(toDecimal(b))
+}
+
+func toDecimal(v driver.Value) decimal.Decimal {
+	switch v := v.(type) {
+	case int64:
+		return decimal.New(v, 0)
+	case float64:
+		return decimal.NewFromFloat(v)
+	case bool:
+		if v {
+			return decimal.New(1, 0)
+		}
+		return decimal.Zero
+	case string:
+		d, _ := decimal.NewFromString(strings.TrimSpace(v))
+		return d
+	case []byte:
+		d, _ := decimal.NewFromString(strings.TrimSpace(string(v)))
+		return d
+	}
+	return decimal.Zero
+}
@@ -0,0 +1,114 @@
+package testkit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/CoolBitX-Technology/subscan/internal/repository"
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/itering/substrate-api-rpc/websocket"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// Dao is the Dao of the daemon, repository.DbStorage, on a sqlite database of its own. Block transactions are
+// isolated like on mysql, a read outside of them only sees the committed blocks. Amounts past int64 lose precision
+type Dao struct {
+	*repository.DbStorage
+	db  *gorm.DB
+	dir string
+}
+
+var _ model.Dao = (*Dao)(nil)
+
+// NewDao is an empty Dao with the table prefix of a plugin, E.g transfer. Close removes its database
+func NewDao(prefix string) (*Dao, error) {
+	dir, err := ioutil.TempDir("", "subscan-testkit")
+	if err != nil {
+		return nil, err
+	}
+	// wal lets the reads outside of a block transaction go on while it writes
+	db, err := gorm.Open("sqlite3", fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000", filepath.Join(dir, "plugins.db")))
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	db.LogMode(false)
+	if err = db.AutoMigrate(&model.RuntimeVersion{}).Error; err != nil {
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	storage := repository.NewDbStorage(db)
+	storage.SetPrefix(prefix)
+	return &Dao{DbStorage: storage, db: db, dir: dir}, nil
+}
+
+// WithPrefix is the Dao of another plugin on the same database and block transactions
+func (d *Dao) WithPrefix(prefix string) *Dao {
+	return &Dao{DbStorage: d.DbStorage.WithPrefix(prefix), db: d.db, dir: d.dir}
+}
+
+// Close closes and removes the database of d and of the Daos sharing it
+func (d *Dao) Close() error {
+	err := d.db.Close()
+	if rmErr := os.RemoveAll(d.dir); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// SetMetadata stores the raw metadata SpecialMetadata returns for spec, as a runtime version
+func (d *Dao) SetMetadata(spec int, raw string) error {
+	return d.db.Where(model.RuntimeVersion{SpecVersion: spec}).Assign(model.RuntimeVersion{RawData: raw}).
+		FirstOrCreate(&model.RuntimeVersion{}).Error
+}
+
+// RPCPool is nil, plugins under test read no chain state
+func (d *Dao) RPCPool() *websocket.PoolConn {
+	return nil
+}
+
+// DB is the gorm handle on the tables, E.g to seed the tables of another plugin
+func (d *Dao) DB() *gorm.DB {
+	return d.db
+}
+
+// Rows are the rows of a table in insert order, the table name is prefixed E.g transfer_transfers
+func (d *Dao) Rows(table string) ([]map[string]interface{}, error) {
+	if !model.ValidIdentifier(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+	rows, err := d.db.Raw(fmt.Sprintf("SELECT * FROM `%s` ORDER BY rowid", table)).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var list []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err = rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		list = append(list, row)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,265 @@
+package testkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/shopspring/decimal"
+)
+
+// Fixture is a stored block with its extrinsics and events, as the daemon reads them from the chain tables
+// before feeding the plugins. E.g testdata/block_5095844.json
+type Fixture struct {
+	Block      *model.ChainBlock      `json:"block"`
+	Extrinsics []model.ChainExtrinsic `json:"extrinsics"`
+	Events     []model.ChainEvent     `json:"events"`
+}
+
+// LoadFixture reads a JSON fixture, extrinsic and event indexes default to block_num-extrinsic_idx
+func LoadFixture(path string) (*Fixture, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f Fixture
+	if err = json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("fixture %s: %v", path, err)
+	}
+	if f.Block == nil {
+		return nil, fmt.Errorf("fixture %s has no block", path)
+	}
+	for i, e := range f.Extrinsics {
+		if e.BlockNum == 0 {
+			f.Extrinsics[i].BlockNum = f.Block.BlockNum
+		}
+		if e.ExtrinsicIndex == "" {
+			f.Extrinsics[i].ExtrinsicIndex = fmt.Sprintf("%d-%d", f.Block.BlockNum, i)
+		}
+	}
+	for i, e := range f.Events {
+		if e.BlockNum == 0 {
+			f.Events[i].BlockNum = f.Block.BlockNum
+		}
+		if e.EventIndex == "" {
+			f.Events[i].EventIndex = fmt.Sprintf("%d-%d", f.Block.BlockNum, e.ExtrinsicIdx)
+		}
+	}
+	return &f, nil
+}
+
+// MustLoadFixture is LoadFixture panicking on error, for table driven tests
+func MustLoadFixture(path string) *Fixture {
+	f, err := LoadFixture(path)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// PluginBlock is the block handed to the plugins
+func (f *Fixture) PluginBlock() *model.Block {
+	return f.Block.AsPlugin()
+}
+
+// PluginExtrinsics are the extrinsics handed to the plugins
+func (f *Fixture) PluginExtrinsics() []model.Extrinsic {
+	list := make([]model.Extrinsic, 0, len(f.Extrinsics))
+	for _, e := range f.Extrinsics {
+		list = append(list, *e.AsPlugin())
+	}
+	return list
+}
+
+// PluginEvents are the events handed to the plugins, all of them or those of the extrinsic index
+func (f *Fixture) PluginEvents(extrinsicIndex ...string) []model.Event {
+	var list []model.Event
+	for _, e := range f.Events {
+		if len(extrinsicIndex) > 0 && fmt.Sprintf("%d-%d", e.BlockNum, e.ExtrinsicIdx) != extrinsicIndex[0] {
+			continue
+		}
+		list = append(list, *e.AsPlugin())
+	}
+	return list
+}
+
+// Harness feeds fixtures to plugins the way the daemon does: a block in one transaction of the daemon's
+// DbStorage, each plugin call in a savepoint, extrinsics and events routed by the exact module name, duplicate
+// key errors ignored
+type Harness struct {
+	dao     *Dao
+	names   []string
+	plugins map[string]model.Plugin
+	// Errors are the failed plugin calls the daemon records as plugin failures
+	Errors []error
+}
+
+// New is a harness on a new sqlite Dao, each plugin is named and prefixed like plugins.registerNative names it
+// E.g transfer for *transfers.Transfer. Configure the plugins before, InitDao and the schema migrations
+// are run here. Close removes the database
+func New(plugins ...model.Plugin) (*Harness, error) {
+	d, err := NewDao("")
+	if err != nil {
+		return nil, err
+	}
+	h := &Harness{dao: d, plugins: make(map[string]model.Plugin)}
+	for _, p := range plugins {
+		name := strings.ToLower(reflect.ValueOf(p).Type().Elem().Name())
+		if _, ok := h.plugins[name]; ok {
+			_ = d.Close()
+			return nil, fmt.Errorf("plugin %s is fed twice", name)
+		}
+		h.plugins[name] = p
+		h.names = append(h.names, name)
+	}
+	sort.Strings(h.names)
+	for _, name := range h.names {
+		storage := d.WithPrefix(name)
+		h.plugins[name].InitDao(storage)
+		if err = migrate(storage, name, h.plugins[name]); err != nil {
+			_ = d.Close()
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// migrate runs every schema step of a plugin implementing model.PluginMigrations, like a first start
+func migrate(d model.Dao, name string, plugin model.Plugin) error {
+	m, ok := plugin.(model.PluginMigrations)
+	if !ok {
+		return nil
+	}
+	steps, err := model.PendingMigrations(name, m.Migrations(), 0)
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		if err = step.Up(d); err != nil {
+			return fmt.Errorf("plugin %s migration %d %s: %v", name, step.Version, step.Name, err)
+		}
+	}
+	return nil
+}
+
+// Dao is the Dao of the named plugin, E.g transfer
+func (h *Harness) Dao(name string) *Dao {
+	return h.dao.WithPrefix(name)
+}
+
+// Close closes and removes the database of the harness
+func (h *Harness) Close() error {
+	return h.dao.Close()
+}
+
+// Feed feeds the fixtures in order, a block fails as a whole when its transaction cannot be opened or committed
+func (h *Harness) Feed(fixtures ...*Fixture) error {
+	for _, f := range fixtures {
+		if err := h.feed(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Harness) feed(f *Fixture) error {
+	blockNum := f.Block.BlockNum
+	if _, err := h.dao.BeginBlock(blockNum); err != nil {
+		return err
+	}
+	if err := h.emit(f); err != nil {
+		_ = h.dao.EndBlock(blockNum, false)
+		return err
+	}
+	return h.dao.EndBlock(blockNum, true)
+}
+
+func (h *Harness) emit(f *Fixture) error {
+	block := f.PluginBlock()
+	eventMap := make(map[string][]model.Event)
+	for _, e := range f.Events {
+		extrinsicIndex := fmt.Sprintf("%d-%d", e.BlockNum, e.ExtrinsicIdx)
+		eventMap[extrinsicIndex] = append(eventMap[extrinsicIndex], *e.AsPlugin())
+	}
+	feeMap := make(map[string]decimal.Decimal)
+	for i := range f.Extrinsics {
+		extrinsic := f.Extrinsics[i].AsPlugin()
+		if extrinsic.ExtrinsicHash != "" {
+			feeMap[extrinsic.ExtrinsicIndex] = extrinsic.Fee
+		}
+		for _, name := range h.names {
+			p := h.plugins[name]
+			if !util.StringInSlice(f.Extrinsics[i].CallModule, p.SubscribeExtrinsic()) {
+				continue
+			}
+			if err := h.call(block.BlockNum, name, extrinsic.ExtrinsicIndex, func() error {
+				return p.ProcessExtrinsic(block, extrinsic, eventMap[extrinsic.ExtrinsicIndex])
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	for i := range f.Events {
+		event := f.Events[i].AsPlugin()
+		fee := feeMap[f.Events[i].EventIndex]
+		for _, name := range h.names {
+			p := h.plugins[name]
+			if !util.StringInSlice(f.Events[i].ModuleId, p.SubscribeEvent()) {
+				continue
+			}
+			index := fmt.Sprintf("%d-%d", event.BlockNum, event.EventIdx)
+			if err := h.call(block.BlockNum, name, index, func() error {
+				return p.ProcessEvent(block, event, fee)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// call runs a plugin call in a savepoint, the writes of a failing call are undone and the error kept in Errors
+func (h *Harness) call(blockNum int, name, index string, fn func() error) error {
+	savepoint, err := h.dao.Savepoint(blockNum)
+	if err != nil {
+		return err
+	}
+	if err = recoverCall(fn); err != nil {
+		h.dao.DbRollback(savepoint)
+		if !isDuplicate(err) {
+			h.Errors = append(h.Errors, fmt.Errorf("plugin %s at %s: %v", name, index, err))
+		}
+	}
+	return nil
+}
+
+// Rollback calls Rollback of the plugins implementing model.PluginRollback, like a reorg to blockNum
+func (h *Harness) Rollback(blockNum int) error {
+	for _, name := range h.names {
+		if p, ok := h.plugins[name].(model.PluginRollback); ok {
+			if err := p.Rollback(blockNum); err != nil {
+				return fmt.Errorf("plugin %s rollback to %d: %v", name, blockNum, err)
+			}
+		}
+	}
+	return nil
+}
+
+// isDuplicate is a duplicate key error of mysql or sqlite
+func isDuplicate(err error) bool {
+	return strings.Contains(err.Error(), "Duplicate entry") || strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+func recoverCall(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
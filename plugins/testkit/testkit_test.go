@@ -0,0 +1,170 @@
+package testkit
+
+import (
+	"testing"
+
+	"github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/transfers"
+	tModel "github.com/CoolBitX-Technology/subscan/plugins/transfers/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeedTransfers(t *testing.T) {
+	h, err := New(transfers.New())
+	assert.NoError(t, err)
+	defer h.Close()
+	block := MustLoadFixture("testdata/block_5095844.json")
+
+	for _, c := range []struct {
+		name     string
+		fixtures []*Fixture
+		rows     int
+	}{
+		{name: "block", fixtures: []*Fixture{block}, rows: 1},
+		{name: "fed again", fixtures: []*Fixture{block}, rows: 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			assert.NoError(t, h.Feed(c.fixtures...))
+			AssertNoErrors(t, h)
+			d := h.Dao("transfer")
+			AssertCount(t, d, "transfer_transfers", c.rows)
+			AssertRow(t, d, "transfer_transfers", map[string]interface{}{
+				"extrinsic_index": "5095844-1",
+				"batch_index":     "",
+				"block_num":       5095844,
+				"amount":          "193309000000000",
+				"fee":             decimal.New(156000015, 0),
+				"success":         true,
+				"from_addr":       "f4e1f21a11b5b74c2b43f26bff6a046a2e1f16d08bbe0dc9ba7582bdb4745c6b",
+				"to_addr":         "8274c1f83eda177fafc715e5845bcb424a5c5b6939abfedc504c7d72aec18fa8",
+				"source":          "call",
+				"event_idx":       1,
+			})
+
+			var list []tModel.Transfer
+			assert.NoError(t, d.FindBy(&list, map[string]interface{}{"block_num": 5095844}, &model.Option{PluginPrefix: "transfer"}))
+			if assert.Len(t, list, 1) {
+				assert.Equal(t, "0x0240a7f02414712568d4d0a6ae360b9ed9d9d8b9b39e788d0b76d8fde8451f14", list[0].ExtrinsicHash)
+				assert.True(t, list[0].Fee.Equal(decimal.New(156000015, 0)))
+			}
+		})
+	}
+
+	// a transfer only seen as an event, E.g paid by a pallet, is stored once with its own source
+	withEvent := MustLoadFixture("testdata/block_5095844.json")
+	withEvent.Events = append(withEvent.Events, model.ChainEvent{EventIndex: "5095844-0", BlockNum: 5095844, ModuleId: "balances", EventId: "Transfer",
+		Params: []model.EventParam{{Value: "6d6f646c70792f74727372790000000000000000000000000000000000000000"},
+			{Value: "8274c1f83eda177fafc715e5845bcb424a5c5b6939abfedc504c7d72aec18fa8"}, {Value: "100"}}, EventIdx: 4})
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, h.Feed(withEvent))
+		AssertNoErrors(t, h)
+		AssertCount(t, h.Dao("transfer"), "transfer_transfers", 2)
+	}
+	AssertRow(t, h.Dao("transfer"), "transfer_transfers", map[string]interface{}{
+		"extrinsic_index": "5095844-0",
+		"block_num":       5095844,
+		"amount":          "100",
+		"success":         true,
+		"source":          "event",
+		"event_idx":       4,
+	})
+
+	assert.NoError(t, h.Rollback(5095843))
+	AssertCount(t, h.Dao("transfer"), "transfer_transfers", 0)
+}
+
+func TestFixture(t *testing.T) {
+	f, err := LoadFixture("testdata/block_5095844.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 5095844, f.PluginBlock().BlockNum)
+	extrinsics := f.PluginExtrinsics()
+	assert.Len(t, extrinsics, 2)
+	assert.Equal(t, "balances", extrinsics[1].CallModule)
+	assert.JSONEq(t, `[{"name":"dest","type":"Address","value":{"Id":"8274c1f83eda177fafc715e5845bcb424a5c5b6939abfedc504c7d72aec18fa8"}},{"name":"value","type":"Compact<Balance>","value":"193309000000000"}]`, string(extrinsics[1].Params))
+	assert.Len(t, f.PluginEvents(), 4)
+	assert.Len(t, f.PluginEvents("5095844-1"), 3)
+
+	_, err = LoadFixture("testdata/missing.json")
+	assert.Error(t, err)
+}
+
+type record struct {
+	ID       uint
+	Name     string `sql:"size:100"`
+	BlockNum int
+	Amount   decimal.Decimal `sql:"type:decimal(30,0);"`
+}
+
+func TestDao(t *testing.T) {
+	d, err := NewDao("test")
+	assert.NoError(t, err)
+	defer d.Close()
+	assert.NoError(t, d.AutoMigration(&record{}))
+	assert.NoError(t, d.AddUniqueIndex(&record{}, "name", "name"))
+
+	txn := d.DbBegin()
+	for i, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, d.Create(txn, &record{Name: name, BlockNum: 10 + i, Amount: decimal.New(int64(i), 0)}).Error)
+	}
+	assert.True(t, isDuplicate(d.Create(txn, &record{Name: "a"}).Error))
+	d.DbCommit(txn)
+	AssertCount(t, d, "test_records", 3)
+
+	// a rolled back transaction leaves nothing
+	txn = d.DbBegin()
+	assert.NoError(t, d.Create(txn, &record{Name: "d", BlockNum: 13}).Error)
+	d.DbRollback(txn)
+	AssertNoRow(t, d, "test_records", map[string]interface{}{"name": "d"})
+
+	// a failing plugin call only undoes its own writes, the block is only seen once committed
+	_, err = d.BeginBlock(20)
+	assert.NoError(t, err)
+	sp, err := d.Savepoint(20)
+	assert.NoError(t, err)
+	assert.NoError(t, d.Create(d.BlockTxn(20), &record{Name: "e", BlockNum: 20}).Error)
+	d.DbCommit(sp)
+	sp, err = d.Savepoint(20)
+	assert.NoError(t, err)
+	assert.NoError(t, d.Create(d.BlockTxn(20), &record{Name: "f", BlockNum: 20}).Error)
+	d.DbRollback(sp)
+	AssertNoRow(t, d, "test_records", map[string]interface{}{"name": "e"})
+	assert.NoError(t, d.EndBlock(20, true))
+	AssertRow(t, d, "test_records", map[string]interface{}{"name": "e", "block_num": 20})
+	AssertNoRow(t, d, "test_records", map[string]interface{}{"name": "f"})
+
+	txn = d.DbBegin()
+	assert.NoError(t, d.Update(txn, &record{}, "name = 'b'", map[string]interface{}{"amount": "42"}).Error)
+	d.DbCommit(txn)
+	AssertRow(t, d, "test_records", map[string]interface{}{"name": "b", "amount": 42})
+
+	var list []record
+	assert.NoError(t, d.FindBy(&list, []string{"block_num < 11", "name = 'e'"}, &model.Option{PluginPrefix: "test", Order: "block_num desc"}))
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "e", list[0].Name)
+		assert.Equal(t, "a", list[1].Name)
+	}
+	var count int
+	assert.NoError(t, d.DB().Table("test_records").Where("block_num BETWEEN ? AND ?", 11, 20).Count(&count).Error)
+	assert.Equal(t, 3, count)
+
+	assert.NoError(t, d.Delete(&record{}, "block_num > 11"))
+	AssertCount(t, d, "test_records", 2)
+	assert.True(t, isDuplicate(d.InsertRow(nil, "records", map[string]interface{}{"name": "a"})))
+
+	assert.NoError(t, d.MigrateTable(&model.Table{Name: "rows", Columns: []model.TableColumn{{Name: "who", Type: "string"}},
+		Indexes: []model.TableIndex{{Name: "who", Columns: []string{"who"}, Unique: true}}}))
+	assert.NoError(t, d.MigrateTable(&model.Table{Name: "rows", Columns: []model.TableColumn{{Name: "who", Type: "string"}},
+		Indexes: []model.TableIndex{{Name: "who", Columns: []string{"who"}, Unique: true}}}))
+	assert.NoError(t, d.InsertRow(nil, "rows", map[string]interface{}{"who": "x"}))
+	assert.Error(t, d.InsertRow(nil, "rows", map[string]interface{}{"who": "x"}))
+	rows, err := d.FindRows(nil, "rows", []model.Cond{{Column: "who", Op: "=", Value: "x"}}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{"id": int64(1), "who": "x"}}, rows)
+	assert.NoError(t, d.DeleteRows(nil, "rows", []model.Cond{{Column: "id", Op: ">=", Value: 1}}))
+	AssertCount(t, d, "test_rows", 0)
+
+	assert.NoError(t, d.SetMetadata(30, "0x6d657461"))
+	assert.Equal(t, "0x6d657461", d.SpecialMetadata(30))
+	assert.Equal(t, "", d.SpecialMetadata(31))
+}
@@ -80,13 +80,27 @@ func (_m *TransferRepository) GetTransfersList(page int, row int) ([]model.Trans
 	return r0, r1
 }
 
-// NewTransferExtrinsic provides a mock function with given fields: b, e, params
-func (_m *TransferRepository) NewTransferExtrinsic(b *subscanmodel.Block, e *subscanmodel.Extrinsic, params []subscanmodel.ExtrinsicParam) error {
-	ret := _m.Called(b, e, params)
+// NewEventTransfer provides a mock function with given fields: b, transfer
+func (_m *TransferRepository) NewEventTransfer(b *subscanmodel.Block, transfer *model.Transfer) error {
+	ret := _m.Called(b, transfer)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, *subscanmodel.Extrinsic, []subscanmodel.ExtrinsicParam) error); ok {
-		r0 = rf(b, e, params)
+	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, *model.Transfer) error); ok {
+		r0 = rf(b, transfer)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// NewTransfers provides a mock function with given fields: b, transfers
+func (_m *TransferRepository) NewTransfers(b *subscanmodel.Block, transfers []model.Transfer) error {
+	ret := _m.Called(b, transfers)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*subscanmodel.Block, []model.Transfer) error); ok {
+		r0 = rf(b, transfers)
 	} else {
 		r0 = ret.Error(0)
 	}
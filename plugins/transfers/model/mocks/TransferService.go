@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	decimal "github.com/shopspring/decimal"
+
 	model "github.com/CoolBitX-Technology/subscan/model"
 	mock "github.com/stretchr/testify/mock"
 
@@ -14,13 +16,27 @@ type TransferService struct {
 	mock.Mock
 }
 
-// BalancesTransaction provides a mock function with given fields: b, e, params
-func (_m *TransferService) BalancesTransaction(b *model.Block, e *model.Extrinsic, params []model.ExtrinsicParam) error {
-	ret := _m.Called(b, e, params)
+// BalancesTransaction provides a mock function with given fields: b, e, events, calls
+func (_m *TransferService) BalancesTransaction(b *model.Block, e *model.Extrinsic, events []model.Event, calls []string) error {
+	ret := _m.Called(b, e, events, calls)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*model.Block, *model.Extrinsic, []model.Event, []string) error); ok {
+		r0 = rf(b, e, events, calls)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EventTransfer provides a mock function with given fields: b, e, fee
+func (_m *TransferService) EventTransfer(b *model.Block, e *model.Event, fee decimal.Decimal) error {
+	ret := _m.Called(b, e, fee)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*model.Block, *model.Extrinsic, []model.ExtrinsicParam) error); ok {
-		r0 = rf(b, e, params)
+	if rf, ok := ret.Get(0).(func(*model.Block, *model.Event, decimal.Decimal) error); ok {
+		r0 = rf(b, e, fee)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -6,29 +6,51 @@ import (
 )
 
 type Transfer struct {
-	ID             uint            `gorm:"primary_key" json:"-"`
-	ExtrinsicIndex string          `json:"extrinsic_index" sql:"default: null;size:100"`
+	ID             uint   `gorm:"primary_key" json:"-"`
+	ExtrinsicIndex string `json:"extrinsic_index" sql:"default: null;size:100"`
+	// Position of the call in the utility batches nesting it, E.g 2.0 for the first call of a batch third in a
+	// batch, empty for a call not in a batch
+	BatchIndex     string          `json:"batch_index" sql:"size:100"`
 	ExtrinsicHash  string          `json:"extrinsic_hash" sql:"size:100;"`
 	BlockNum       int             `json:"block_num"`
 	BlockTimestamp int             `json:"block_timestamp"`
 	Amount         string          `json:"amount" sql:"size:100;"`
 	Success        bool            `json:"success"`
 	Fee            decimal.Decimal `json:"fee" sql:"type:decimal(30,0);"`
-	FromAddr       string          `json:"from_addr"`
-	ToAddr         string          `json:"to_addr"`
+	// Effective origin, E.g the proxied account of proxy.proxy or the multisig account of multisig.as_multi
+	FromAddr string `json:"from_addr"`
+	ToAddr   string `json:"to_addr"`
+	// SourceCall or SourceEvent
+	Source string `json:"source" sql:"size:10"`
+	// Balances.Transfer event of the transfer in the block, nil for a call transfer without one, E.g a failed one
+	EventIdx *int `json:"event_idx"`
 }
 
+const (
+	// SourceCall is a transfer call, of the extrinsic or nested in it
+	SourceCall = "call"
+	// SourceEvent is a Balances.Transfer event no transfer call matches, E.g one of a pallet hook or of xcm
+	SourceEvent = "event"
+)
+
+// DefaultCalls are the module-call names stored as transfers without a calls config
+var DefaultCalls = []string{"balances-transfer", "balances-transfer_keep_alive", "balances-transfer_all"}
+
 type TransferDelivery interface {
 	TransferList(page int, row int, address string) ([]Transfer, error)
 }
 
 type TransferService interface {
 	GetTransfersListJson(page, row int, addr string) ([]Transfer, error)
-	BalancesTransaction(b *model.Block, e *model.Extrinsic, params []model.ExtrinsicParam) error
+	// Store the transfers of the calls, the extrinsic or the ones nested in it, events are the ones of the extrinsic
+	BalancesTransaction(b *model.Block, e *model.Extrinsic, events []model.Event, calls []string) error
+	// Store a Balances.Transfer event unless a transfer call has it
+	EventTransfer(b *model.Block, e *model.Event, fee decimal.Decimal) error
 }
 
 type TransferRepository interface {
-	NewTransferExtrinsic(b *model.Block, e *model.Extrinsic, params []model.ExtrinsicParam) error
+	NewTransfers(b *model.Block, transfers []Transfer) error
+	NewEventTransfer(b *model.Block, transfer *Transfer) error
 	GetExtrinsicByIndex(ei string) (Transfer, error)
 	GetTransfersList(page, row int) ([]Transfer, int)
 	GetTransfersByAddr(page, row int, addr string) ([]Transfer, error)
@@ -2,7 +2,6 @@ package repository
 
 import (
 	"fmt"
-	"reflect"
 
 	m "github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/plugins/transfers/model"
@@ -21,45 +20,40 @@ func NewsqlTransferRepository(db m.Dao) model.TransferRepository {
 	}
 }
 
-func (s *sqlTransferRepository) NewTransferExtrinsic(b *m.Block, e *m.Extrinsic, params []m.ExtrinsicParam) error {
-	txn := s.DB.DbBegin()
+func (s *sqlTransferRepository) NewTransfers(b *m.Block, transfers []model.Transfer) error {
+	txn := s.DB.BlockTxn(b.BlockNum)
 	defer s.DB.DbRollback(txn)
-	t := &model.Transfer{
-		ExtrinsicIndex: e.ExtrinsicIndex,
-		ExtrinsicHash:  e.ExtrinsicHash,
-		BlockNum:       b.BlockNum,
-		BlockTimestamp: b.BlockTimestamp,
-		Amount:         params[1].Value.(string),
-		Success:        e.Success,
-		Fee:            e.Fee,
-		FromAddr:       e.AccountId,
-		// ToAddr:         params[0].Value.(map[string]interface{})["Id"].(string), // TODO on block 7157677 cause error
-		// [{"name":"dest","type":"Address","value":{"Id":"86adbd5205188b0176be681b6a153adb4dd7eb37b3c8766a82cfe3bfdef8ee3d"}},{"name":"value","type":"Compact\u003cBalance\u003e","value":"12447772443"}]
-		// [{"name":"dest","type":"Address","value":{"Address20":"0x60e5f32c0353b56cdb7697383e9ef4678c040e77"}},{"name":"value","type":"Compact\u003cBalance\u003e","value":"43000000000"}]
-		//    [{"name": "dest","type": "Address","value": "d6b71ad01f548464adebb5f206d5223f3e1997e85e37fcf1a2b07d8650b82301","value_raw": "d6b71ad01f548464adebb5f206d5223f3e1997e85e37fcf1a2b07d8650b82301"},{"name": "value","type": "Compact<Balance>","value": "69000000000","value_raw": "070072b81010"}]
-		// [{"name":"dest","type":"Address","value":"2e92f5f2de7a56893a04e3460be0d83d856ac2871bb1779dbb12c767eaddd461"},{"name":"value","type":"Compact\u003cBalance\u003e","value":"100000000000000"}]
-	}
-
-	v := reflect.ValueOf(params[0].Value)
-
-	switch v.Kind() {
-	case reflect.Map:
-		if val, ok := params[0].Value.(map[string]interface{})["Id"].(string); ok {
-			t.ToAddr = val
-		} else {
-			t.ToAddr = params[0].Value.(map[string]interface{})["Address20"].(string)
+	for i := range transfers {
+		t := &transfers[i]
+		tableName := fmt.Sprintf("%s_%s", "transfer", txn.DB.Unscoped().NewScope(t).TableName())
+		if err := txn.DB.Table(tableName).Create(t).Error; err != nil {
+			return err
 		}
-	case reflect.String:
-		t.ToAddr = params[0].Value.(string)
+		log.Info("New a tranfer extrinsic with extrinsicIndex: ", t.ExtrinsicIndex, " batchIndex: ", t.BatchIndex)
 	}
+	s.DB.DbCommit(txn)
+	return nil
+}
 
-	tableName := fmt.Sprintf("%s_%s", "transfer", txn.DB.Unscoped().NewScope(&t).TableName())
-	query := txn.DB.Table(tableName).Create(&t)
-	if query.Error == nil {
-		s.DB.DbCommit(txn)
-		log.Info("New a tranfer extrinsic with extrinsicIndex: ", e.ExtrinsicIndex)
+// NewEventTransfer stores an event transfer unless a call transfer stored in the block has its event, the block
+// transaction is read as the calls of the block are not committed yet
+func (s *sqlTransferRepository) NewEventTransfer(b *m.Block, t *model.Transfer) error {
+	txn := s.DB.BlockTxn(b.BlockNum)
+	defer s.DB.DbRollback(txn)
+	tableName := fmt.Sprintf("%s_%s", "transfer", txn.DB.Unscoped().NewScope(t).TableName())
+	var count int
+	if err := txn.DB.Table(tableName).Where("block_num = ? AND event_idx = ?", t.BlockNum, *t.EventIdx).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		// not in a call, a null batch_index keeps the events of an extrinsic apart
+		if err := txn.DB.Table(tableName).Omit("batch_index").Create(t).Error; err != nil {
+			return err
+		}
+		log.Info("New a tranfer event with extrinsicIndex: ", t.ExtrinsicIndex, " eventIdx: ", *t.EventIdx)
 	}
-	return query.Error
+	s.DB.DbCommit(txn)
+	return nil
 }
 
 func (s *sqlTransferRepository) GetExtrinsicByIndex(ei string) (model.Transfer, error) {
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/transfers/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+	"github.com/shopspring/decimal"
+)
+
+// EventTransfer stores a Balances.Transfer event, the ones a transfer call of the block has are skipped by the
+// repository. Events outside of an extrinsic, E.g of a pallet hook, are stored too
+func (s *Service) EventTransfer(b *m.Block, e *m.Event, fee decimal.Decimal) error {
+	t := eventTransfer(e)
+	if t == nil {
+		return nil
+	}
+	t.ExtrinsicIndex = fmt.Sprintf("%d-%d", e.BlockNum, e.ExtrinsicIdx)
+	t.ExtrinsicHash = e.ExtrinsicHash
+	t.BlockNum = b.BlockNum
+	t.BlockTimestamp = b.BlockTimestamp
+	t.Fee = fee
+	return s.sql.NewEventTransfer(b, t)
+}
+
+// eventTransfer reads Balances.Transfer(from, to, amount), nil for another event
+func eventTransfer(e *m.Event) *model.Transfer {
+	if !strings.EqualFold(e.ModuleId, "balances") || e.EventId != "Transfer" {
+		return nil
+	}
+	var params []m.EventParam
+	util.UnmarshalAny(&params, e.Params)
+	if len(params) < 3 {
+		return nil
+	}
+	eventIdx := e.EventIdx
+	return &model.Transfer{
+		FromAddr: account(params[0].Value),
+		ToAddr:   account(params[1].Value),
+		Amount:   util.ToString(params[2].Value),
+		Success:  true,
+		Source:   model.SourceEvent,
+		EventIdx: &eventIdx,
+	}
+}
+
+// matchEvents gives every successful call transfer the first Balances.Transfer event of the extrinsic with its
+// accounts and amount not given to another one, the event amount fills the one of a balances.transfer_all
+func matchEvents(transfers []model.Transfer, events []m.Event) {
+	var candidates []*model.Transfer
+	sorted := append([]m.Event(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].EventIdx < sorted[j].EventIdx })
+	for i := range sorted {
+		if t := eventTransfer(&sorted[i]); t != nil {
+			candidates = append(candidates, t)
+		}
+	}
+	for i := range transfers {
+		t := &transfers[i]
+		if !t.Success {
+			continue
+		}
+		for j, c := range candidates {
+			if c == nil || !sameAccount(c.FromAddr, t.FromAddr) || !sameAccount(c.ToAddr, t.ToAddr) || (t.Amount != "" && t.Amount != c.Amount) {
+				continue
+			}
+			t.EventIdx = c.EventIdx
+			if t.Amount == "" {
+				t.Amount = c.Amount
+			}
+			candidates[j] = nil
+			break
+		}
+	}
+}
+
+// account reads an account of an event or a call, the account id or {"Id": "..."} or {"Address20": "0x..."}
+func account(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if id, ok := v["Id"].(string); ok {
+			return id
+		}
+		if id, ok := v["Address20"].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+func sameAccount(a, b string) bool {
+	return a != "" && strings.EqualFold(strings.TrimPrefix(a, "0x"), strings.TrimPrefix(b, "0x"))
+}
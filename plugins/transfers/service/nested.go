@@ -0,0 +1,262 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/transfers/model"
+	"github.com/CoolBitX-Technology/subscan/util"
+)
+
+// call is the extrinsic call or a call nested in it, E.g an item of utility.batch
+type call struct {
+	module   string
+	function string
+	params   []m.ExtrinsicParam
+}
+
+// newCall reads a decoded Call param, {"call_module": "Balances", "call_name": "transfer", "params": [...]}.
+// Nil for an opaque call, E.g the encoded call of an old multisig.as_multi
+func newCall(v interface{}) *call {
+	raw, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	c := call{module: strings.ToLower(util.ToString(raw["call_module"])), function: strings.ToLower(util.ToString(raw["call_name"]))}
+	if c.module == "" || c.function == "" {
+		return nil
+	}
+	util.UnmarshalAny(&c.params, raw["params"])
+	return &c
+}
+
+func (c *call) param(name string) interface{} {
+	for _, p := range c.params {
+		if p.Name == name {
+			return p.Value
+		}
+	}
+	return nil
+}
+
+func (c *call) address(name string) string {
+	return account(c.param(name))
+}
+
+func (c *call) calls(name string) []*call {
+	items, _ := c.param(name).([]interface{})
+	var calls []*call
+	for _, item := range items {
+		calls = append(calls, newCall(item))
+	}
+	return calls
+}
+
+// walker replays the dispatch of the extrinsic, the events telling if a nested call succeeded follow the events
+// of the call, E.g the ItemCompleted of a batch item, they are read in order by cursor
+type walker struct {
+	calls  []string
+	events []m.Event
+	cursor int
+}
+
+func newWalker(events []m.Event, calls []string) *walker {
+	w := walker{calls: calls}
+	for _, e := range events {
+		switch strings.ToLower(e.ModuleId) {
+		case "utility", "proxy", "multisig", "sudo":
+			w.events = append(w.events, e)
+		}
+	}
+	sort.SliceStable(w.events, func(i, j int) bool { return w.events[i].EventIdx < w.events[j].EventIdx })
+	return &w
+}
+
+// walk returns the transfers of c and of the calls nested in it, dispatched by origin. ok is false when c
+// failed or was not run, nothing is read then
+func (w *walker) walk(c *call, origin, position string, ok bool) []model.Transfer {
+	if c == nil {
+		return nil
+	}
+	key := fmt.Sprintf("%s-%s", c.module, c.function)
+	switch {
+	case util.StringInSlice(key, w.calls):
+		t := model.Transfer{BatchIndex: position, Success: ok, FromAddr: origin, ToAddr: c.address("dest")}
+		if source := c.address("source"); source != "" {
+			t.FromAddr = source
+		}
+		if value := c.param("value"); value != nil {
+			t.Amount = util.ToString(value)
+		}
+		return []model.Transfer{t}
+	case key == "utility-batch", key == "utility-batch_all", key == "utility-force_batch":
+		return w.batch(c, origin, position, ok)
+	case key == "proxy-proxy":
+		return w.dispatch(newCall(c.param("call")), c.address("real"), position, ok, "proxy", "ProxyExecuted")
+	case key == "sudo-sudo_as":
+		return w.dispatch(newCall(c.param("call")), c.address("who"), position, ok, "sudo", "SudoAsDone")
+	case key == "multisig-as_multi":
+		return w.multisig(newCall(c.param("call")), position, ok)
+	}
+	return nil
+}
+
+// batch walks the items, an item succeeded unless an ItemFailed or a BatchInterrupted at its index follows it,
+// the items after a BatchInterrupted were not run
+func (w *walker) batch(c *call, origin, position string, ok bool) []model.Transfer {
+	var transfers []model.Transfer
+	stopped := !ok
+	for i, item := range c.calls("calls") {
+		itemPosition := fmt.Sprint(i)
+		if position != "" {
+			itemPosition = fmt.Sprintf("%s.%d", position, i)
+		}
+		if stopped {
+			transfers = append(transfers, w.walk(item, origin, itemPosition, false)...)
+			continue
+		}
+		start := w.cursor
+		itemTransfers := w.walk(item, origin, itemPosition, true)
+		if w.next("utility", "ItemCompleted") != nil {
+			transfers = append(transfers, itemTransfers...)
+			continue
+		}
+		// the events of a failed item are reverted, its failure directly follows the events before it
+		end := w.cursor
+		w.cursor = start
+		if w.next("utility", "ItemFailed") != nil {
+			transfers = append(transfers, w.walk(item, origin, itemPosition, false)...)
+			continue
+		}
+		if e := w.peek("utility", "BatchInterrupted"); e != nil && interruptedAt(e) == i {
+			w.cursor++
+			stopped = true
+			transfers = append(transfers, w.walk(item, origin, itemPosition, false)...)
+			continue
+		}
+		// runtimes before ItemCompleted
+		w.cursor = end
+		transfers = append(transfers, itemTransfers...)
+	}
+	if w.next("utility", "BatchCompleted") == nil {
+		w.next("utility", "BatchCompletedWithErrors")
+	}
+	return transfers
+}
+
+// dispatch walks the call run as origin, the result of event following it tells if it succeeded
+func (w *walker) dispatch(c *call, origin, position string, ok bool, module, event string) []model.Transfer {
+	if !ok {
+		return w.walk(c, origin, position, false)
+	}
+	start := w.cursor
+	transfers := w.walk(c, origin, position, true)
+	if e := w.next(module, event); e != nil {
+		if !dispatched(e) {
+			setSuccess(transfers, false)
+		}
+		return transfers
+	}
+	end := w.cursor
+	w.cursor = start
+	if e := w.next(module, event); e != nil {
+		transfers = w.walk(c, origin, position, false)
+		setSuccess(transfers, dispatched(e))
+		return transfers
+	}
+	w.cursor = end
+	return transfers
+}
+
+// multisig walks the call run by the multisig account of MultisigExecuted, a call only approved by
+// NewMultisig or MultisigApproval was not run and has no transfer
+func (w *walker) multisig(c *call, position string, ok bool) []model.Transfer {
+	if !ok || c == nil {
+		return nil
+	}
+	if w.next("multisig", "NewMultisig") != nil || w.next("multisig", "MultisigApproval") != nil {
+		return nil
+	}
+	start := w.cursor
+	transfers := w.walk(c, "", position, true)
+	e := w.next("multisig", "MultisigExecuted")
+	if e == nil {
+		w.cursor = start
+		if e = w.next("multisig", "MultisigExecuted"); e == nil {
+			return nil
+		}
+		transfers = w.walk(c, "", position, false)
+	}
+	var params []m.EventParam
+	util.UnmarshalAny(&params, e.Params)
+	if len(params) < 3 {
+		return nil
+	}
+	multisig := util.ToString(params[2].Value)
+	for i := range transfers {
+		if transfers[i].FromAddr == "" {
+			transfers[i].FromAddr = multisig
+		}
+	}
+	if !dispatched(e) {
+		setSuccess(transfers, false)
+	}
+	return transfers
+}
+
+func (w *walker) peek(module, event string) *m.Event {
+	if w.cursor >= len(w.events) {
+		return nil
+	}
+	e := &w.events[w.cursor]
+	if !strings.EqualFold(e.ModuleId, module) || e.EventId != event {
+		return nil
+	}
+	return e
+}
+
+// next reads the event at the cursor when it is module.event
+func (w *walker) next(module, event string) *m.Event {
+	e := w.peek(module, event)
+	if e != nil {
+		w.cursor++
+	}
+	return e
+}
+
+// dispatched reads the result of ProxyExecuted, SudoAsDone or MultisigExecuted, the last param, E.g
+// {"Ok": []}, {"Error": {...}} or a bool
+func dispatched(e *m.Event) bool {
+	var params []m.EventParam
+	util.UnmarshalAny(&params, e.Params)
+	if len(params) == 0 {
+		return true
+	}
+	switch v := params[len(params)-1].Value.(type) {
+	case bool:
+		return v
+	case map[string]interface{}:
+		_, failed := v["Error"]
+		_, err := v["Err"]
+		return !failed && !err
+	}
+	return true
+}
+
+// interruptedAt is the index of the item failing a batch, BatchInterrupted(u32, DispatchError)
+func interruptedAt(e *m.Event) int {
+	var params []m.EventParam
+	util.UnmarshalAny(&params, e.Params)
+	if len(params) == 0 {
+		return -1
+	}
+	return util.IntFromInterface(params[0].Value)
+}
+
+func setSuccess(transfers []model.Transfer, success bool) {
+	for i := range transfers {
+		transfers[i].Success = success
+	}
+}
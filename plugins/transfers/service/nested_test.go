@@ -0,0 +1,238 @@
+package service_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	m "github.com/CoolBitX-Technology/subscan/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/transfers/model"
+	"github.com/CoolBitX-Technology/subscan/plugins/transfers/model/mocks"
+	"github.com/CoolBitX-Technology/subscan/plugins/transfers/service"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	signer   = "f4e1f21a11b5b74c2b43f26bff6a046a2e1f16d08bbe0dc9ba7582bdb4745c6b"
+	alice    = "8274c1f83eda177fafc715e5845bcb424a5c5b6939abfedc504c7d72aec18fa8"
+	bob      = "d6b71ad01f548464adebb5f206d5223f3e1997e85e37fcf1a2b07d8650b82301"
+	multisig = "2e92f5f2de7a56893a04e3460be0d83d856ac2871bb1779dbb12c767eaddd461"
+)
+
+func transferCall(dest, value string) map[string]interface{} {
+	return map[string]interface{}{"call_index": "0500", "call_module": "Balances", "call_name": "transfer", "params": []m.ExtrinsicParam{
+		{Name: "dest", Type: "LookupSource", Value: map[string]interface{}{"Id": dest}},
+		{Name: "value", Type: "Compact<Balance>", Value: value},
+	}}
+}
+
+func remarkCall() map[string]interface{} {
+	return map[string]interface{}{"call_index": "0001", "call_module": "System", "call_name": "remark", "params": []m.ExtrinsicParam{
+		{Name: "_remark", Type: "Bytes", Value: "0x00"},
+	}}
+}
+
+func nestingCall(module, function string, params ...m.ExtrinsicParam) map[string]interface{} {
+	return map[string]interface{}{"call_module": module, "call_name": function, "params": params}
+}
+
+func event(module, event string, params ...interface{}) m.Event {
+	var p []m.EventParam
+	for _, v := range params {
+		p = append(p, m.EventParam{Value: v})
+	}
+	raw, _ := json.Marshal(p)
+	return m.Event{ModuleId: module, EventId: event, Params: raw}
+}
+
+func transferEvent(eventIdx int, from, to interface{}, amount string) m.Event {
+	e := event("balances", "Transfer", from, to, amount)
+	e.EventIdx = eventIdx
+	return e
+}
+
+var (
+	itemCompleted  = event("utility", "ItemCompleted")
+	batchCompleted = event("utility", "BatchCompleted")
+	dispatchOk     = map[string]interface{}{"Ok": []interface{}{}}
+	dispatchErr    = map[string]interface{}{"Error": map[string]interface{}{"Module": map[string]interface{}{"index": 5, "error": 2}}}
+)
+
+func TestNestedTransfers(t *testing.T) {
+	block := m.Block{BlockNum: 100, BlockTimestamp: 1621215588}
+	transfer := func(batchIndex, from, to, amount string, success bool) model.Transfer {
+		return model.Transfer{ExtrinsicIndex: "100-1", BlockNum: 100, BlockTimestamp: 1621215588, BatchIndex: batchIndex,
+			FromAddr: from, ToAddr: to, Amount: amount, Success: success, Source: model.SourceCall}
+	}
+	matched := func(t model.Transfer, eventIdx int) model.Transfer {
+		t.EventIdx = &eventIdx
+		return t
+	}
+	batch := func(function string, calls ...interface{}) map[string]interface{} {
+		return nestingCall("Utility", function, m.ExtrinsicParam{Name: "calls", Type: "Vec<Call>", Value: calls})
+	}
+	proxy := func(real string, call interface{}) map[string]interface{} {
+		return nestingCall("Proxy", "proxy", m.ExtrinsicParam{Name: "real", Type: "AccountId", Value: real},
+			m.ExtrinsicParam{Name: "force_proxy_type", Type: "Option<ProxyType>"}, m.ExtrinsicParam{Name: "call", Type: "Call", Value: call})
+	}
+	asMulti := func(call interface{}) map[string]interface{} {
+		return nestingCall("Multisig", "as_multi", m.ExtrinsicParam{Name: "threshold", Type: "u16", Value: 2},
+			m.ExtrinsicParam{Name: "call", Type: "OpaqueCall", Value: call})
+	}
+
+	for _, c := range []struct {
+		name      string
+		call      map[string]interface{}
+		success   bool
+		events    []m.Event
+		transfers []model.Transfer
+	}{
+		{
+			name:    "batch",
+			call:    batch("batch", transferCall(alice, "10"), remarkCall(), transferCall(bob, "20")),
+			success: true,
+			events:  []m.Event{itemCompleted, itemCompleted, itemCompleted, batchCompleted},
+			transfers: []model.Transfer{
+				transfer("0", signer, alice, "10", true),
+				transfer("2", signer, bob, "20", true),
+			},
+		},
+		{
+			name: "batch with transfer events",
+			call: batch("batch", transferCall(alice, "10"), transferCall(alice, "10"),
+				nestingCall("Balances", "transfer_all", m.ExtrinsicParam{Name: "dest", Type: "LookupSource", Value: map[string]interface{}{"Id": bob}},
+					m.ExtrinsicParam{Name: "keep_alive", Type: "bool", Value: false})),
+			success: true,
+			events: []m.Event{
+				transferEvent(1, signer, alice, "10"), itemCompleted,
+				transferEvent(3, signer, alice, "10"), itemCompleted,
+				transferEvent(5, signer, bob, "99"), itemCompleted, batchCompleted,
+			},
+			transfers: []model.Transfer{
+				matched(transfer("0", signer, alice, "10", true), 1),
+				matched(transfer("1", signer, alice, "10", true), 3),
+				matched(transfer("2", signer, bob, "99", true), 5),
+			},
+		},
+		{
+			name:    "batch interrupted",
+			call:    batch("batch", transferCall(alice, "10"), transferCall(bob, "20"), transferCall(alice, "30")),
+			success: true,
+			events:  []m.Event{itemCompleted, event("utility", "BatchInterrupted", 1, dispatchErr)},
+			transfers: []model.Transfer{
+				transfer("0", signer, alice, "10", true),
+				transfer("1", signer, bob, "20", false),
+				transfer("2", signer, alice, "30", false),
+			},
+		},
+		{
+			name:    "batch interrupted without ItemCompleted",
+			call:    batch("batch", transferCall(alice, "10"), transferCall(bob, "20")),
+			success: true,
+			events:  []m.Event{event("utility", "BatchInterrupted", 1, dispatchErr)},
+			transfers: []model.Transfer{
+				transfer("0", signer, alice, "10", true),
+				transfer("1", signer, bob, "20", false),
+			},
+		},
+		{
+			name:    "force_batch",
+			call:    batch("force_batch", transferCall(alice, "10"), transferCall(bob, "20")),
+			success: true,
+			events:  []m.Event{event("utility", "ItemFailed", dispatchErr), itemCompleted, event("utility", "BatchCompletedWithErrors")},
+			transfers: []model.Transfer{
+				transfer("0", signer, alice, "10", false),
+				transfer("1", signer, bob, "20", true),
+			},
+		},
+		{
+			name:    "failed batch_all",
+			call:    batch("batch_all", transferCall(alice, "10"), transferCall(bob, "20")),
+			success: false,
+			transfers: []model.Transfer{
+				transfer("0", signer, alice, "10", false),
+				transfer("1", signer, bob, "20", false),
+			},
+		},
+		{
+			name:    "nested batch",
+			call:    batch("batch", batch("batch_all", transferCall(alice, "10")), proxy(bob, transferCall(alice, "20"))),
+			success: true,
+			events: []m.Event{itemCompleted, batchCompleted, itemCompleted,
+				event("proxy", "ProxyExecuted", dispatchOk), itemCompleted, batchCompleted},
+			transfers: []model.Transfer{
+				transfer("0.0", signer, alice, "10", true),
+				transfer("1", bob, alice, "20", true),
+			},
+		},
+		{
+			name:      "proxy failed",
+			call:      proxy(bob, transferCall(alice, "10")),
+			success:   true,
+			events:    []m.Event{event("proxy", "ProxyExecuted", dispatchErr)},
+			transfers: []model.Transfer{transfer("", bob, alice, "10", false)},
+		},
+		{
+			name:    "sudo_as",
+			call:    nestingCall("Sudo", "sudo_as", m.ExtrinsicParam{Name: "who", Type: "LookupSource", Value: bob}, m.ExtrinsicParam{Name: "call", Type: "Call", Value: transferCall(alice, "10")}),
+			success: true,
+			events:  []m.Event{event("sudo", "SudoAsDone", true)},
+			transfers: []model.Transfer{
+				transfer("", bob, alice, "10", true),
+			},
+		},
+		{
+			name:    "as_multi executed",
+			call:    asMulti(batch("batch", transferCall(alice, "10"))),
+			success: true,
+			events: []m.Event{itemCompleted, batchCompleted,
+				event("multisig", "MultisigExecuted", signer, map[string]interface{}{"height": 99, "index": 1}, multisig, "0x01", dispatchOk)},
+			transfers: []model.Transfer{transfer("0", multisig, alice, "10", true)},
+		},
+		{
+			name:    "as_multi approved",
+			call:    asMulti(transferCall(alice, "10")),
+			success: true,
+			events:  []m.Event{event("multisig", "NewMultisig", signer, multisig, "0x01")},
+		},
+		{
+			name:    "opaque as_multi",
+			call:    asMulti("0x0500"),
+			success: true,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			repo := new(mocks.TransferRepository)
+			repo.On("NewTransfers", &block, mock.Anything).Return(nil)
+			params, _ := json.Marshal(c.call["params"])
+			e := m.Extrinsic{ExtrinsicIndex: "100-1", CallModule: c.call["call_module"].(string), CallModuleFunction: c.call["call_name"].(string),
+				Params: params, AccountId: signer, Success: c.success}
+			assert.NoError(t, service.New(repo).BalancesTransaction(&block, &e, c.events, model.DefaultCalls))
+			if c.transfers == nil {
+				repo.AssertNotCalled(t, "NewTransfers", mock.Anything, mock.Anything)
+				return
+			}
+			repo.AssertCalled(t, "NewTransfers", &block, c.transfers)
+		})
+	}
+}
+
+func TestEventTransfer(t *testing.T) {
+	block := m.Block{BlockNum: 100, BlockTimestamp: 1621215588}
+	repo := new(mocks.TransferRepository)
+	repo.On("NewEventTransfer", &block, mock.Anything).Return(nil)
+	s := service.New(repo)
+
+	e := transferEvent(4, signer, map[string]interface{}{"Id": alice}, "10")
+	e.BlockNum, e.ExtrinsicIdx, e.ExtrinsicHash = 100, 2, "0x01"
+	assert.NoError(t, s.EventTransfer(&block, &e, decimal.New(7, 0)))
+	eventIdx := 4
+	repo.AssertCalled(t, "NewEventTransfer", &block, &model.Transfer{ExtrinsicIndex: "100-2", ExtrinsicHash: "0x01", BlockNum: 100,
+		BlockTimestamp: 1621215588, Amount: "10", Success: true, Fee: decimal.New(7, 0), FromAddr: signer, ToAddr: alice,
+		Source: model.SourceEvent, EventIdx: &eventIdx})
+
+	deposit := event("balances", "Deposit", alice, "10")
+	assert.NoError(t, s.EventTransfer(&block, &deposit, decimal.Zero))
+	repo.AssertNumberOfCalls(t, "NewEventTransfer", 1)
+}
@@ -1,8 +1,11 @@
 package service
 
 import (
+	"strings"
+
 	m "github.com/CoolBitX-Technology/subscan/model"
 	"github.com/CoolBitX-Technology/subscan/plugins/transfers/model"
+	"github.com/CoolBitX-Technology/subscan/util"
 )
 
 type Service struct {
@@ -19,6 +22,24 @@ func (s *Service) GetTransfersListJson(page, row int, addr string) ([]model.Tran
 	return s.sql.GetTransfersByAddr(page, row, addr)
 }
 
-func (s *Service) BalancesTransaction(b *m.Block, e *m.Extrinsic, params []m.ExtrinsicParam) (err error) {
-	return s.sql.NewTransferExtrinsic(b, e, params)
+// BalancesTransaction stores the transfer calls of the extrinsic, the top one or the ones nested in utility
+// batches, proxy.proxy, multisig.as_multi and sudo.sudo_as. A nested transfer is successful when the extrinsic
+// and the events of the calls nesting it are
+func (s *Service) BalancesTransaction(b *m.Block, e *m.Extrinsic, events []m.Event, calls []string) error {
+	c := &call{module: strings.ToLower(e.CallModule), function: strings.ToLower(e.CallModuleFunction)}
+	util.UnmarshalAny(&c.params, e.Params)
+	transfers := newWalker(events, calls).walk(c, e.AccountId, "", e.Success)
+	if len(transfers) == 0 {
+		return nil
+	}
+	for i := range transfers {
+		transfers[i].ExtrinsicIndex = e.ExtrinsicIndex
+		transfers[i].ExtrinsicHash = e.ExtrinsicHash
+		transfers[i].BlockNum = b.BlockNum
+		transfers[i].BlockTimestamp = b.BlockTimestamp
+		transfers[i].Fee = e.Fee
+		transfers[i].Source = model.SourceCall
+	}
+	matchEvents(transfers, events)
+	return s.sql.NewTransfers(b, transfers)
 }
@@ -1,6 +1,7 @@
 package service_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	m "github.com/CoolBitX-Technology/subscan/model"
@@ -70,16 +71,28 @@ func TestNewTransferExtrinsic(t *testing.T) {
 	}
 
 	mockExtrinsicParam := []m.ExtrinsicParam{
-		{Type: "Address", Value: map[string]interface{}{"Id": "8274c1f83eda177fafc715e5845bcb424a5c5b6939abfedc504c7d72aec18fa8"}},
-		{Type: "Compact<Balance>", Value: "193309000000000"},
-		{Type: "Balance", Value: "142408084752"},
+		{Name: "dest", Type: "Address", Value: map[string]interface{}{"Id": "8274c1f83eda177fafc715e5845bcb424a5c5b6939abfedc504c7d72aec18fa8"}},
+		{Name: "value", Type: "Compact<Balance>", Value: "193309000000000"},
 	}
+	mockExtrinsic.Params, _ = json.Marshal(mockExtrinsicParam)
 
 	t.Run("Sucess", func(t *testing.T) {
 		s := service.New(mockTransferRepo)
-		mockTransferRepo.On("NewTransferExtrinsic", &mockBlock, &mockExtrinsic, mockExtrinsicParam).Return(nil)
-		e := s.BalancesTransaction(&mockBlock, &mockExtrinsic, mockExtrinsicParam)
+		transfers := []model.Transfer{{
+			ExtrinsicIndex: mockExtrinsic.ExtrinsicIndex,
+			ExtrinsicHash:  mockExtrinsic.ExtrinsicHash,
+			BlockNum:       mockBlock.BlockNum,
+			BlockTimestamp: mockBlock.BlockTimestamp,
+			Amount:         "193309000000000",
+			Success:        true,
+			Fee:            mockExtrinsic.Fee,
+			FromAddr:       mockExtrinsic.AccountId,
+			ToAddr:         "8274c1f83eda177fafc715e5845bcb424a5c5b6939abfedc504c7d72aec18fa8",
+			Source:         model.SourceCall,
+		}}
+		mockTransferRepo.On("NewTransfers", &mockBlock, transfers).Return(nil)
+		e := s.BalancesTransaction(&mockBlock, &mockExtrinsic, nil, model.DefaultCalls)
 		assert.NoError(t, e)
-		mockTransferRepo.AssertCalled(t, "NewTransferExtrinsic", &mockBlock, &mockExtrinsic, mockExtrinsicParam)
+		mockTransferRepo.AssertCalled(t, "NewTransfers", &mockBlock, transfers)
 	})
 }
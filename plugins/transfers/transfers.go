@@ -19,11 +19,27 @@ import (
 var srv model.TransferService
 
 type Transfer struct {
-	d m.Dao
+	d     m.Dao
+	calls []string
 }
 
 func New() *Transfer {
-	return &Transfer{}
+	return &Transfer{calls: model.DefaultCalls}
+}
+
+// Configure takes calls, the module-call names stored as transfers
+func (a *Transfer) Configure(c map[string]interface{}) error {
+	conf := struct {
+		Calls []string `json:"calls"`
+	}{Calls: model.DefaultCalls}
+	if err := util.RemarshalAny(&conf, c); err != nil {
+		return err
+	}
+	a.calls = make([]string, len(conf.Calls))
+	for i, call := range conf.Calls {
+		a.calls[i] = strings.ToLower(call)
+	}
+	return nil
 }
 
 func (a *Transfer) InitDao(d m.Dao) {
@@ -34,40 +50,31 @@ func (a *Transfer) InitDao(d m.Dao) {
 }
 
 func (a *Transfer) InitHttp() []router.Http {
-	return http.Router(srv)
+	return nil
 }
 
-func (a *Transfer) ProcessExtrinsic(b *m.Block, e *m.Extrinsic, p []m.Event) error {
-	log.Info("=== Transfer ProcessExtrinsic ===")
-	var err error
-	var paramExtrinsic []m.ExtrinsicParam
-	util.UnmarshalAny(&paramExtrinsic, e.Params)
-	c := fmt.Sprintf("%s-%s", strings.ToLower(e.CallModule), strings.ToLower(e.CallModuleFunction))
-	log.Info(c)
-	switch c {
-	case strings.ToLower("Balances-Transfer_Keep_Alive"), strings.ToLower("Balances-Transfer"), strings.ToLower("Balances-Transfer_All"):
-		log.Info(e.Success)
-		// TODO e.Fee == 0 case
-		if err = srv.BalancesTransaction(b, e, paramExtrinsic); err != nil {
-			log.Error(err)
-		}
-		break
-	}
-
-	if err != nil {
-		return err
-	}
+func (a *Transfer) Routes() []m.PluginRoute {
+	return http.Routes(srv)
+}
 
-	return nil
+// ProcessExtrinsic stores the transfer calls of the extrinsic, nested ones included
+func (a *Transfer) ProcessExtrinsic(b *m.Block, e *m.Extrinsic, p []m.Event) error {
+	return srv.BalancesTransaction(b, e, p, a.calls)
 }
 
+// ProcessEvent stores the Balances.Transfer events no transfer call of the block has
 func (a *Transfer) ProcessEvent(block *m.Block, event *m.Event, fee decimal.Decimal) error {
-	return nil
+	return srv.EventTransfer(block, event, fee)
+}
+
+// Rollback drops the transfers of the blocks after blockNum
+func (a *Transfer) Rollback(blockNum int) error {
+	return a.d.Delete(&model.Transfer{}, fmt.Sprintf("block_num > %d", blockNum))
 }
 
 // Plugins version
 func (a *Transfer) Version() string {
-	return "0.1"
+	return "0.3"
 }
 
 func (a *Transfer) TransferList(page int, row int, address string) ([]model.Transfer, error) {
@@ -87,7 +94,7 @@ func (a *Transfer) TransferList(page int, row int, address string) ([]model.Tran
 
 // Subscribe Extrinsic with special module
 func (a *Transfer) SubscribeExtrinsic() []string {
-	return []string{"sudo", "system", "balances", "utility"}
+	return []string{"sudo", "system", "balances", "utility", "proxy", "multisig"}
 }
 
 // Subscribe Events with special module
@@ -101,10 +108,38 @@ func (a *Transfer) Migrate() {
 	if e = a.d.AutoMigration(&model.Transfer{}); e != nil {
 		log.Error(e)
 	}
-	if e = a.d.AddUniqueIndex(&model.Transfer{}, "extrinsic_index", "extrinsic_index"); e != nil {
+	if e = a.d.AddUniqueIndex(&model.Transfer{}, "extrinsic_batch_index", "extrinsic_index", "batch_index"); e != nil {
+		log.Error(e)
+	}
+	if e = a.d.AddUniqueIndex(&model.Transfer{}, "block_event_idx", "block_num", "event_idx"); e != nil {
 		log.Error(e)
 	}
 	if e = a.d.AddIndex(&model.Transfer{}, "idx_num_from_to", "block_num", "from_addr", "to_addr"); e != nil {
 		log.Error(e)
 	}
 }
+
+// Migrations of the transfers table
+func (a *Transfer) Migrations() []m.PluginMigration {
+	return []m.PluginMigration{
+		// an extrinsic has a transfer per nested call
+		{Version: 1, Name: "unique extrinsic_index and batch_index", Up: func(d m.Dao) error {
+			txn := d.DbBegin()
+			defer d.DbRollback(txn)
+			if err := d.Update(txn, &model.Transfer{}, []string{"batch_index IS NULL"}, map[string]interface{}{"batch_index": ""}).Error; err != nil {
+				return err
+			}
+			d.DbCommit(txn)
+			return d.RemoveIndex(&model.Transfer{}, "extrinsic_index")
+		}},
+		{Version: 2, Name: "source of the call transfers", Up: func(d m.Dao) error {
+			txn := d.DbBegin()
+			defer d.DbRollback(txn)
+			if err := d.Update(txn, &model.Transfer{}, []string{"source IS NULL"}, map[string]interface{}{"source": model.SourceCall}).Error; err != nil {
+				return err
+			}
+			d.DbCommit(txn)
+			return nil
+		}},
+	}
+}
@@ -10,8 +10,11 @@ var (
 	AddressType               = GetEnv("SUBSTRATE_ADDRESS_TYPE", "0")
 	BalanceAccuracy           = GetEnv("SUBSTRATE_ACCURACY", "9")
 	CommissionAccuracy        = GetEnv("COMMISSION_ACCURACY", "9")
-	WSEndPoint                = GetEnv("CHAIN_WS_ENDPOINT", "ws://localhost:9944") // "wss://rpc.polkadot.io/", wss://polkadot.elara.patract.io, wss://polkadot.api.onfinality.io
+	WSEndPoints               = wsEndPoints(os.Getenv("CHAIN_WS_ENDPOINT")) // comma separated, "wss://rpc.polkadot.io/,wss://polkadot.api.onfinality.io"
+	WSEndPoint                = WSEndPoints[0]
 	NetworkNode               = GetEnv("NETWORK_NODE", "polkadot")
+	ChainReplayDir            = os.Getenv("CHAIN_REPLAY_DIR") // replay recorded chain data instead of connecting a node
+	ChainRecordDir            = os.Getenv("CHAIN_RECORD_DIR") // record the chain data read from the node
 	IsProduction              = os.Getenv("DEPLOY_ENV") == "prod"
 )
 
@@ -22,3 +25,11 @@ func GetEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// wsEndPoints are the endpoints of a comma separated list, ws://localhost:9944 when it has none
+func wsEndPoints(value string) []string {
+	if endpoints := SplitAndTrim(value, ","); len(endpoints) > 0 {
+		return endpoints
+	}
+	return []string{"ws://localhost:9944"}
+}
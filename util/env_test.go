@@ -0,0 +1,13 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWsEndPoints(t *testing.T) {
+	assert.Equal(t, []string{"ws://localhost:9944"}, wsEndPoints(""))
+	assert.Equal(t, []string{"ws://localhost:9944"}, wsEndPoints(" , "))
+	assert.Equal(t, []string{"wss://a", "wss://b"}, wsEndPoints("wss://a, wss://b,"))
+}
@@ -42,6 +42,15 @@ func ToString(i interface{}) string {
 	return val
 }
 
+// RemarshalAny converts raw into r through JSON, reporting the error UnmarshalAny drops
+func RemarshalAny(r interface{}, raw interface{}) error {
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, r)
+}
+
 func UnmarshalAny(r interface{}, raw interface{}) {
 	switch raw := raw.(type) {
 	case string:
@@ -108,3 +108,14 @@ func TestUnmarshalAny(t *testing.T) {
 	}{31, 32}, p)
 
 }
+
+func TestRemarshalAny(t *testing.T) {
+	p := new(struct {
+		One int
+		Two []string
+	})
+	assert.NoError(t, RemarshalAny(p, map[string]interface{}{"one": int64(1), "two": []interface{}{"a", "b"}}))
+	assert.Equal(t, 1, p.One)
+	assert.Equal(t, []string{"a", "b"}, p.Two)
+	assert.Error(t, RemarshalAny(p, map[string]interface{}{"one": "1"}))
+}
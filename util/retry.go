@@ -0,0 +1,31 @@
+package util
+
+import (
+	"time"
+)
+
+// Retry calls fn up to attempts times until it succeeds, sleeping interval after the first failure
+// and doubling it after every further one up to max. The last error is returned
+func Retry(attempts int, interval, max time.Duration, fn func() error) (err error) {
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if i == attempts-1 {
+			break
+		}
+		time.Sleep(Backoff(i, interval, max))
+	}
+	return err
+}
+
+// Backoff is interval doubled attempt times, capped at max
+func Backoff(attempt int, interval, max time.Duration) time.Duration {
+	for i := 0; i < attempt && interval < max; i++ {
+		interval *= 2
+	}
+	if interval > max {
+		return max
+	}
+	return interval
+}
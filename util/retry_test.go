@@ -0,0 +1,35 @@
+package util
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	var calls int
+	err := Retry(5, time.Millisecond, 2*time.Millisecond, func() error {
+		if calls++; calls < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = Retry(3, time.Millisecond, time.Millisecond, func() error {
+		calls++
+		return errors.New("always")
+	})
+	assert.EqualError(t, err, "always")
+	assert.Equal(t, 3, calls)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, Backoff(0, time.Second, time.Minute))
+	assert.Equal(t, 8*time.Second, Backoff(3, time.Second, time.Minute))
+	assert.Equal(t, time.Minute, Backoff(10, time.Second, time.Minute))
+}
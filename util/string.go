@@ -40,3 +40,14 @@ func StringsIntersection(a []string, b []string) []string {
 	}
 	return refresh
 }
+
+// SplitAndTrim splits s by sep and drops blank items
+func SplitAndTrim(s, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -74,3 +74,9 @@ func TestSets(t *testing.T) {
 		}
 	}
 }
+
+func TestSplitAndTrim(t *testing.T) {
+	assert.Equal(t, []string{"ws://a:9944", "wss://b"}, SplitAndTrim(" ws://a:9944, ,wss://b ", ","))
+	assert.Equal(t, []string{"ws://a:9944"}, SplitAndTrim("ws://a:9944", ","))
+	assert.Nil(t, SplitAndTrim("", ","))
+}
@@ -24,3 +24,8 @@ func Validate(data interface{}, model interface{}) (err error) {
 	}
 	return validate.Struct(model)
 }
+
+// Struct checks the validate tags of an already bound model
+func Struct(model interface{}) error {
+	return validate.Struct(model)
+}